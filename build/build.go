@@ -6,6 +6,8 @@
 package build
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"go/ast"
 	"go/build"
@@ -13,6 +15,7 @@ import (
 	"go/scanner"
 	"go/token"
 	"go/types"
+	"io"
 	"io/fs"
 	"os"
 	"os/exec"
@@ -25,6 +28,7 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/neelance/sourcemap"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/tools/go/buildutil"
 
@@ -670,16 +674,47 @@ func finalizeRemovals(file *ast.File) {
 
 // Options controls build process behavior.
 type Options struct {
-	Verbose        bool
-	Quiet          bool
-	Watch          bool
-	CreateMapFile  bool
-	MapToLocalDisk bool
-	Minify         bool
-	Color          bool
-	BuildTags      []string
-	TestedPackage  string
-	NoCache        bool
+	Verbose         bool
+	Quiet           bool
+	Watch           bool
+	CreateMapFile   bool
+	MapToLocalDisk  bool
+	Minify          bool
+	Color           bool
+	BuildTags       []string
+	TestedPackage   string
+	NoCache         bool
+	NoInline        bool
+	SharedGenerics  bool
+	Generators      bool
+	Preempt         bool
+	PreemptBudget   time.Duration
+	Unchecked       bool
+	StripReflection bool
+	LazyTypes       bool
+	MangleProps     bool
+	Optimize        bool
+	StableNames     bool
+	DetectLeaks     bool
+}
+
+// compilerOptions returns options for translating individual packages.
+func (o *Options) compilerOptions() compiler.Options {
+	opts := compiler.Options{
+		Minify:          o.Minify,
+		NoInline:        o.NoInline,
+		SharedGenerics:  o.SharedGenerics,
+		Generators:      o.Generators,
+		Unchecked:       o.Unchecked,
+		StripReflection: o.StripReflection,
+		LazyTypes:       o.LazyTypes,
+		MangleProps:     o.MangleProps,
+		StableNames:     o.StableNames,
+	}
+	if o.Preempt {
+		opts.PreemptBudget = o.PreemptBudget
+	}
+	return opts
 }
 
 // PrintError message to the terminal.
@@ -1070,7 +1105,7 @@ func (s *Session) loadTestPackage(pkg *PackageData) (*sources.Sources, error) {
 
 	// Generate a synthetic testmain package.
 	fset := token.NewFileSet()
-	tests := testmain.TestMain{Package: pkg.Package, Context: pkg.bctx}
+	tests := testmain.TestMain{Package: pkg.Package, Context: pkg.bctx, DetectLeaks: s.options.DetectLeaks}
 	tests.Scan(fset)
 	mainPkg, mainFile, err := tests.Synthesize(fset)
 	if err != nil {
@@ -1252,6 +1287,10 @@ func (s *Session) LoadPackages(pkg *PackageData) (*sources.Sources, error) {
 		}
 	}
 
+	// The standard library and the GopherJS runtime packages are used from
+	// synchronous JavaScript code paths, so they are never preempted.
+	srcs.Preempt = s.options.Preempt && !pkg.Goroot && !isGopherJSImportPath(pkg.ImportPath)
+
 	// Add the sources to the session's sources map.
 	s.sources[pkg.ImportPath] = srcs
 
@@ -1297,7 +1336,7 @@ func (s *Session) compilePackage(srcs *sources.Sources, tContext *types.Context)
 		return archive, nil
 	}
 
-	archive, err := compiler.Compile(srcs, tContext, s.options.Minify)
+	archive, err := compiler.Compile(srcs, tContext, s.options.compilerOptions())
 	if err != nil {
 		return nil, err
 	}
@@ -1392,9 +1431,19 @@ func (s *Session) WriteCommandPackage(archive *compiler.Archive, pkgObj string)
 	}
 	defer codeFile.Close()
 
-	sourceMapFilter := &sourcemapx.Filter{Writer: codeFile}
+	// With Options.Optimize the program is collected in memory first, since the
+	// optimizer needs to see all of it.
+	var code bytes.Buffer
+	out := io.Writer(codeFile)
+	if s.options.Optimize {
+		out = &code
+	}
+
+	sourceMapFilter := &sourcemapx.Filter{Writer: out}
+	var sourceMap *sourcemap.Map
 	if s.options.CreateMapFile {
 		s.EnableMapping(sourceMapFilter, filepath.Base(pkgObj))
+		sourceMap = sourceMapFilter.SourceMap()
 
 		mapFile, err := os.Create(pkgObj + ".map")
 		if err != nil {
@@ -1402,7 +1451,7 @@ func (s *Session) WriteCommandPackage(archive *compiler.Archive, pkgObj string)
 		}
 
 		defer func() {
-			sourceMapFilter.WriteMappingTo(mapFile)
+			sourceMap.WriteTo(mapFile)
 			mapFile.Close()
 			fmt.Fprintf(codeFile, "//# sourceMappingURL=%s.map\n", filepath.Base(pkgObj))
 		}()
@@ -1412,7 +1461,36 @@ func (s *Session) WriteCommandPackage(archive *compiler.Archive, pkgObj string)
 	if err != nil {
 		return err
 	}
-	return compiler.WriteProgramCode(deps, sourceMapFilter, s.GoRelease(), s.TestBinary())
+	if err := compiler.WriteProgramCode(deps, sourceMapFilter, s.GoRelease(), s.TestBinary()); err != nil {
+		return err
+	}
+	if !s.options.Optimize {
+		return nil
+	}
+
+	optimized, optimizedMap, err := sourcemapx.Optimize(code.Bytes(), sourceMap)
+	if err != nil {
+		return err
+	}
+	sourceMap = optimizedMap
+	if _, err := codeFile.Write(optimized); err != nil {
+		return err
+	}
+	if !s.options.Quiet {
+		s.options.PrintSuccess("optimized %s: %d -> %d bytes, %d -> %d bytes gzipped\n", filepath.Base(pkgObj),
+			code.Len(), len(optimized), gzippedSize(code.Bytes()), gzippedSize(optimized))
+	}
+	return nil
+}
+
+// gzippedSize returns the size of the code compressed with gzip, which is how
+// it is typically served.
+func gzippedSize(code []byte) int {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(code)
+	w.Close()
+	return buf.Len()
 }
 
 // WaitForChange watches file system events and returns if either when one of
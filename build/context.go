@@ -253,7 +253,9 @@ func (sc simpleCtx) applyPostloadTweaks(pkg *build.Package) *build.Package {
 	case "runtime":
 		pkg.GoFiles = []string{} // Package sources are completely replaced in natives.
 	case "runtime/pprof":
-		pkg.GoFiles = nil
+		// Profiles are collected by the natives, which only reuse the protobuf
+		// encoder of the upstream package.
+		pkg.GoFiles = []string{"protobuf.go"}
 	case "sync":
 		// GopherJS completely replaces sync.Pool implementation with a simpler one,
 		// since it always executes in a single-threaded environment.
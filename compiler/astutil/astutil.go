@@ -210,6 +210,17 @@ func OverrideSignature(d *ast.FuncDecl) bool {
 	return hasDirective(d, `override-signature`)
 }
 
+// Unchecked returns true if gopherjs:unchecked directive is present in the
+// package clause documentation of a file.
+//
+// `//gopherjs:unchecked` is a GopherJS-specific directive, which instructs the
+// compiler to omit index bounds checks, nil pointer checks and integer division
+// by zero checks from the code generated for the whole package, as if it was
+// compiled with the --unchecked option.
+func Unchecked(file *ast.File) bool {
+	return hasDirective(file, `unchecked`)
+}
+
 // directiveMatcher is a regex which matches a GopherJS directive
 // and finds the directive action.
 var directiveMatcher = regexp.MustCompile(`^\/(?:\/|\*)gopherjs:([\w-]+)`)
@@ -11,6 +11,7 @@ import (
 	"go/types"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/gopherjs/gopherjs/compiler/incjs"
 	"github.com/gopherjs/gopherjs/compiler/internal/dce"
@@ -72,6 +73,15 @@ type Archive struct {
 	FileSet *token.FileSet
 	// Whether or not the package was compiled with minification enabled.
 	Minified bool
+	// Whether or not the names of unexported fields and methods were replaced
+	// with mangled property markers, see Options.MangleProps.
+	MangledProps bool
+	// Whether or not the package was compiled with generator code generation
+	// for blocking functions, see Options.Generators.
+	Generators bool
+	// The time budget for preempted loops of the program, see
+	// Options.PreemptBudget.
+	PreemptBudget time.Duration
 	// A list of go:linkname directives encountered in the package.
 	GoLinknames []linkname.GoLinkname
 }
@@ -138,21 +148,15 @@ func WriteProgramCode(pkgs []*Archive, w *sourcemapx.Filter, goVersion, testBina
 		gls.Add(pkg.GoLinknames)
 	}
 
-	sel := &dce.Selector[*Decl]{}
-	for _, pkg := range pkgs {
-		for _, d := range pkg.Declarations {
-			implementsLink := false
-			if gls.IsImplementation(d.LinkingName) {
-				// If a decl is referenced by a go:linkname directive, we just assume
-				// it's not dead.
-				// TODO(nevkontakte): This is a safe, but imprecise assumption. We should
-				// try and trace whether the referencing functions are actually live.
-				implementsLink = true
-			}
-			sel.Include(d, implementsLink)
-		}
+	dceSelection, reflected := selectDecls(pkgs)
+
+	if mainPkg.MangledProps {
+		// Replace mangled property markers in all code written from now on with
+		// short names allocated for the whole program.
+		rename := w.Rename
+		w.Rename = newPropMangler(pkgs, dceSelection, reflected).rename
+		defer func() { w.Rename = rename }()
 	}
-	dceSelection := sel.AliveDecls()
 
 	if _, err := writeF(w, false, "\"use strict\";\n(function() {\n\n"); err != nil {
 		return err
@@ -163,7 +167,11 @@ func WriteProgramCode(pkgs []*Archive, w *sourcemapx.Filter, goVersion, testBina
 	if _, err := writeF(w, false, "var $testBinary = %q;\n", testBinary); err != nil {
 		return err
 	}
-	for _, preludeFile := range prelude.PreludeFiles() {
+	preludeFiles := prelude.PreludeFiles()
+	if mainPkg.Generators {
+		preludeFiles = append(preludeFiles, prelude.GeneratorGoroutines())
+	}
+	for _, preludeFile := range preludeFiles {
 		if _, err := w.WriteJS(preludeFile.Source, preludeFile.Name, minify); err != nil {
 			return err
 		}
@@ -171,10 +179,15 @@ func WriteProgramCode(pkgs []*Archive, w *sourcemapx.Filter, goVersion, testBina
 	if _, err := writeF(w, false, "\n"); err != nil {
 		return err
 	}
+	if mainPkg.PreemptBudget != 0 {
+		if _, err := writeF(w, false, "$preemptBudget = %v;\n", float64(mainPkg.PreemptBudget)/float64(time.Millisecond)); err != nil {
+			return err
+		}
+	}
 
 	// write packages
 	for _, pkg := range pkgs {
-		if err := WritePkgCode(pkg, dceSelection, gls, minify, w); err != nil {
+		if err := WritePkgCode(pkg, dceSelection, reflected, gls, minify, w); err != nil {
 			return err
 		}
 	}
@@ -191,11 +204,17 @@ func WriteProgramCode(pkgs []*Archive, w *sourcemapx.Filter, goVersion, testBina
 	if _, err := writeF(w, false, "var $mainPkg = $packages[\"%s\"];\n", mainPkg.ImportPath); err != nil {
 		return err
 	}
-	if _, err := writeF(w, false, "$packages[\"runtime\"].$init();\n"); err != nil {
-		return err
-	}
-	if _, err := writeF(w, false, "$go($mainPkg.$init, []);\n"); err != nil {
-		return err
+	if mainPkg.Generators {
+		if _, err := writeF(w, false, "$go($initPackages, []);\n"); err != nil {
+			return err
+		}
+	} else {
+		if _, err := writeF(w, false, "$packages[\"runtime\"].$init();\n"); err != nil {
+			return err
+		}
+		if _, err := writeF(w, false, "$go($mainPkg.$init, []);\n"); err != nil {
+			return err
+		}
 	}
 	if _, err := writeF(w, false, "$flushConsole();\n"); err != nil {
 		return err
@@ -206,7 +225,20 @@ func WriteProgramCode(pkgs []*Archive, w *sourcemapx.Filter, goVersion, testBina
 	return nil
 }
 
-func WritePkgCode(pkg *Archive, dceSelection map[*Decl]struct{}, gls linkname.GoLinknameSet, minify bool, w *sourcemapx.Filter) error {
+// selectDecls performs dead-code elimination over all declarations of the
+// program. It returns the declarations that are alive, and those of them that
+// are reflected upon, see dce.Selector.
+func selectDecls(pkgs []*Archive) (alive, reflected map[*Decl]struct{}) {
+	sel := &dce.Selector[*Decl]{}
+	for _, pkg := range pkgs {
+		for _, d := range pkg.Declarations {
+			sel.Include(d)
+		}
+	}
+	return sel.AliveDecls(), sel.ReflectedDecls()
+}
+
+func WritePkgCode(pkg *Archive, dceSelection, reflected map[*Decl]struct{}, gls linkname.GoLinknameSet, minify bool, w *sourcemapx.Filter) error {
 	if w.IsMapping() && pkg.FileSet != nil {
 		w.FileSet = pkg.FileSet
 	}
@@ -284,13 +316,17 @@ func WritePkgCode(pkg *Archive, dceSelection map[*Decl]struct{}, gls linkname.Go
 	}
 	// Write reflection metadata for types' methods
 	for _, d := range filteredDecls {
-		if _, err := w.Write(d.MethodListCode); err != nil {
+		_, isReflected := reflected[d]
+		methodList, _ := d.metadataCode(isReflected)
+		if _, err := w.Write(methodList); err != nil {
 			return err
 		}
 	}
 	// Write the calls to finish initialization of types
 	for _, d := range filteredDecls {
-		if _, err := w.Write(d.TypeInitCode); err != nil {
+		_, isReflected := reflected[d]
+		_, typeInit := d.metadataCode(isReflected)
+		if _, err := w.Write(typeInit); err != nil {
 			return err
 		}
 	}
@@ -301,7 +337,10 @@ func WritePkgCode(pkg *Archive, dceSelection map[*Decl]struct{}, gls linkname.Go
 			// callers via $linkname object (declared in prelude). We are not using
 			// $pkg to avoid clashes with exported symbols.
 			if recv, method, ok := d.LinkingName.IsMethod(); ok {
-				if _, err := writeF(w, minify, "\t$linknames[%q] = $unsafeMethodToFunction(%v,%q,%t);\n", d.LinkingName.String(), d.NamedRecvType, method, strings.HasPrefix(recv, "*")); err != nil {
+				if pkg.MangledProps && !token.IsExported(method) && mangledPkg(pkg.ImportPath) {
+					method = mangledProp(pkg.ImportPath, method)
+				}
+				if _, err := writeF(w, minify, "\t$linknames[%q] = $unsafeMethodToFunction(%v,\"%s\",%t);\n", d.LinkingName.String(), d.NamedRecvType, method, strings.HasPrefix(recv, "*")); err != nil {
 					return err
 				}
 			} else {
@@ -341,13 +380,23 @@ func WritePkgCode(pkg *Archive, dceSelection map[*Decl]struct{}, gls linkname.Go
 
 	// Write the initialization function that will initialize this package
 	// (e.g. initialize package-level variable value).
-	if _, err := writeF(w, minify, "\t$init = function() {\n"); err != nil {
+	initKeyword := "function"
+	if pkg.Generators {
+		initKeyword = "function*"
+	}
+	if _, err := writeF(w, minify, "\t$init = %s%s() {\n", pkgInitHint(pkg), initKeyword); err != nil {
 		return err
 	}
 	if _, err := writeF(w, minify, "\t\t$pkg.$init = function() {};\n"); err != nil {
 		return err
 	}
-	if _, err := writeF(w, minify, "\t\t/* */ var $f, $c = false, $s = 0, $r; if (this !== undefined && this.$blk !== undefined) { $f = this; $c = true; $s = $f.$s; $r = $f.$r; } s: while (true) { switch ($s) { case 0:\n"); err != nil {
+	initPrefix := "\t\t/* */ var $f, $c = false, $s = 0, $r; if (this !== undefined && this.$blk !== undefined) { $f = this; $c = true; $s = $f.$s; $r = $f.$r; } s: while (true) { switch ($s) { case 0:\n"
+	initSuffix := "\t\t/* */ } return; } if ($f === undefined) { $f = { $blk: $init }; } $f.$s = $s; $f.$r = $r; return $f;\n"
+	if pkg.Generators {
+		// Blocking initialization code is delegated to in place.
+		initPrefix, initSuffix = "", ""
+	}
+	if _, err := writeF(w, minify, initPrefix); err != nil {
 		return err
 	}
 	for _, d := range filteredDecls {
@@ -355,7 +404,7 @@ func WritePkgCode(pkg *Archive, dceSelection map[*Decl]struct{}, gls linkname.Go
 			return err
 		}
 	}
-	if _, err := writeF(w, minify, "\t\t/* */ } return; } if ($f === undefined) { $f = { $blk: $init }; } $f.$s = $s; $f.$r = $r; return $f;\n"); err != nil {
+	if _, err := writeF(w, minify, initSuffix); err != nil {
 		return err
 	}
 	if _, err := writeF(w, minify, "\t};\n"); err != nil {
@@ -376,6 +425,25 @@ func WritePkgCode(pkg *Archive, dceSelection map[*Decl]struct{}, gls linkname.Go
 	return nil
 }
 
+// pkgInitHint returns a source map hint which maps the package initialization
+// function to the Go symbol of the package initialization, so that it's named
+// accordingly in stack traces. The function has no position of its own, so the
+// beginning of the package's first file is used.
+func pkgInitHint(pkg *Archive) string {
+	if pkg.FileSet == nil {
+		return ""
+	}
+	pos := token.NoPos
+	pkg.FileSet.Iterate(func(f *token.File) bool {
+		pos = token.Pos(f.Base())
+		return false
+	})
+	if !pos.IsValid() {
+		return ""
+	}
+	return sourcemapx.Identifier{Name: "$init", OriginalName: pkg.ImportPath + ".init", OriginalPos: pos}.EncodeHint()
+}
+
 func writeF(w io.Writer, minify bool, format string, args ...any) (n int, err error) {
 	return w.Write(removeWhitespace([]byte(fmt.Sprintf(format, args...)), minify))
 }
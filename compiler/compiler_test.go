@@ -3,8 +3,10 @@ package compiler
 import (
 	"bytes"
 	"go/types"
+	"io"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -968,6 +970,87 @@ func Test_IndexedSelectors(t *testing.T) {
 	)
 }
 
+func TestDeclSelection_RemoveUnusedSideEffectFreeVars(t *testing.T) {
+	src := `
+		package main
+
+		var table = map[string]int{"a": 1, "b": 2}
+		var handler = func() { println("handler") }
+		var sized = make([]int, 16)
+		var lengths = len("abc") + min(1, 2)
+		var dependent = []int{base}
+		var base = 42
+
+		var kept = compute()
+		var unsized = make([]int, base)
+		var used = 7
+
+		func compute() int {
+			println("side effect")
+			return 1
+		}
+
+		func main() {
+			println(used)
+		}`
+
+	srcFiles := []srctesting.Source{{Name: `main.go`, Contents: []byte(src)}}
+	sel := declSelection(t, srcFiles, nil)
+
+	sel.IsDead(`var:command-line-arguments.table`)
+	sel.IsDead(`var:command-line-arguments.handler`)
+	sel.IsDead(`var:command-line-arguments.sized`)
+	sel.IsDead(`var:command-line-arguments.lengths`)
+	sel.IsDead(`var:command-line-arguments.dependent`)
+
+	// Initializers that may have side effects or panic are kept,
+	// as well as the variables they depend on.
+	sel.IsAlive(`var:command-line-arguments.kept`)
+	sel.IsAlive(`func:command-line-arguments.compute`)
+	sel.IsAlive(`var:command-line-arguments.unsized`)
+	sel.IsAlive(`var:command-line-arguments.base`)
+	sel.IsAlive(`var:command-line-arguments.used`)
+}
+
+func TestDeclSelection_LinknameImplementations(t *testing.T) {
+	src1 := `
+		package main
+
+		import (
+			_ "unsafe"
+
+			"github.com/gopherjs/gopherjs/compiler/other"
+		)
+
+		//go:linkname used github.com/gopherjs/gopherjs/compiler/other.usedImpl
+		func used() int
+
+		//go:linkname unused github.com/gopherjs/gopherjs/compiler/other.unusedImpl
+		func unused() int
+
+		func main() {
+			println(used(), other.X)
+		}`
+	src2 := `
+		package other
+
+		var X = 42
+
+		func usedImpl() int { return 1 }
+
+		func unusedImpl() int { return 2 }`
+
+	sel := declSelection(t,
+		[]srctesting.Source{{Name: `main.go`, Contents: []byte(src1)}},
+		[]srctesting.Source{{Name: `other/other.go`, Contents: []byte(src2)}})
+
+	sel.IsAlive(`func:command-line-arguments.used`)
+	sel.IsAlive(`func:github.com/gopherjs/gopherjs/compiler/other.usedImpl`)
+
+	sel.IsDead(`func:command-line-arguments.unused`)
+	sel.IsDead(`func:github.com/gopherjs/gopherjs/compiler/other.unusedImpl`)
+}
+
 func TestNestedConcreteTypeInGenericFunc(t *testing.T) {
 	// This is a test of a type defined inside a generic function
 	// that uses the type parameter of the function as a field type.
@@ -1085,6 +1168,894 @@ func TestNestedGenericTypeInGenericFuncWithSharedTArgs(t *testing.T) {
 	}
 }
 
+func TestInlining(t *testing.T) {
+	src1 := `
+		package main
+		import "github.com/gopherjs/gopherjs/compiler/geom"
+
+		var counter int
+
+		func next() int {
+			counter++
+			return counter
+		}
+
+		func main() {
+			x, y := 1, 2
+			p := geom.Point{X: x, Y: y}
+			println(geom.Add(x, y))
+			println(p.Sum())
+			println(geom.Add(next(), y))
+			println(!geom.Positive(x, y))
+			println(geom.Describe(p))
+			println(new(geom.Point).GetX())
+		}`
+	src2 := `
+		package geom
+
+		type Point struct{ X, Y int }
+
+		func (p Point) Sum() int { return p.X + p.Y }
+
+		func (p *Point) GetX() int { return p.X }
+
+		func Add(a, b int) int { return a + b }
+
+		func Positive(a, b int) bool { return a > 0 || b > 0 }
+
+		func Describe(p Point) string {
+			println("not inlined")
+			return "point"
+		}`
+
+	tests := []struct {
+		name    string
+		opts    Options
+		want    []string
+		notWant []string
+	}{
+		{
+			name: `enabled`,
+			opts: Options{},
+			want: []string{
+				`x + y`,                          // geom.Add(x, y)
+				`p.X + p.Y`,                      // p.Sum()
+				`(_a = next(), _b = y, (_a + _b`, // geom.Add(next(), y)
+				`!(x > 0 || y > 0)`,              // !geom.Positive(x, y)
+				`geom.Describe(p)`,               // Multiple statements.
+				`.GetX()`,                        // May panic on nil receivers.
+			},
+			notWant: []string{`geom.Add(`, `.Sum()`, `geom.Positive(`},
+		}, {
+			name: `disabled`,
+			opts: Options{NoInline: true},
+			want: []string{`geom.Add(x, y)`, `.Sum()`, `geom.Add(next(), y)`, `!geom.Positive(x, y)`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Compilation modifies the parsed sources, so each subtest needs its own.
+			root := srctesting.ParseSources(t,
+				[]srctesting.Source{{Name: `main.go`, Contents: []byte(src1)}},
+				[]srctesting.Source{{Name: `geom/geom.go`, Contents: []byte(src2)}})
+			archives := compileProjectWithOptions(t, root, test.opts)
+			mainPkg := archives[root.PkgPath]
+			var code []byte
+			for _, d := range mainPkg.Declarations {
+				if d.FullName == `func:command-line-arguments.main` {
+					code = d.FuncDeclCode
+				}
+			}
+			if code == nil {
+				t.Fatal(`main function declaration not found`)
+			}
+			for _, want := range test.want {
+				if !bytes.Contains(code, []byte(want)) {
+					t.Errorf("Expected %q in the generated code, but it was not found.", want)
+				}
+			}
+			for _, notWant := range test.notWant {
+				if bytes.Contains(code, []byte(notWant)) {
+					t.Errorf("Expected no %q in the generated code, but it was found.", notWant)
+				}
+			}
+			if t.Failed() {
+				t.Logf("Generated code:\n%s", code)
+			}
+		})
+	}
+}
+
+func TestCloneElision(t *testing.T) {
+	src := `
+		package main
+
+		type Point struct{ X, Y int }
+
+		func (p Point) Len() int { return p.X*p.X + p.Y*p.Y }
+		func (p Point) Scaled(k int) Point {
+			p.X *= k
+			p.Y *= k
+			return p
+		}
+
+		func show(p Point) {
+			println("point", p.X, p.Y)
+		}
+
+		func keep(p Point) {
+			kept = p
+		}
+
+		func move(p Point) {
+			p.X++
+			println(p.X)
+		}
+
+		var kept any
+
+		func readOnly(p Point) {
+			q := p
+			show(q)
+			println(q.Len())
+		}
+
+		func mutated(p Point) {
+			show(p)
+			p.X = 1
+			show(p)
+		}
+
+		func exposed() *Point {
+			p := Point{1, 2}
+			show(p)
+			return &p
+		}
+
+		func calls(p Point) {
+			keep(p)
+			move(p)
+			println(p.Scaled(2).X)
+		}
+
+		func main() {
+			readOnly(Point{})
+			mutated(Point{})
+			exposed()
+			calls(Point{})
+		}`
+
+	root := srctesting.ParseSources(t,
+		[]srctesting.Source{{Name: `main.go`, Contents: []byte(src)}},
+		nil)
+	archives := compileProjectWithOptions(t, root, Options{NoInline: true})
+	mainPkg := archives[root.PkgPath]
+
+	tests := []struct {
+		name    string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    `readOnly`,
+			want:    []string{`q = p;`, `show(q);`, `q.Len()`},
+			notWant: []string{`$clone`},
+		}, {
+			name: `mutated`,
+			// The variable is modified while the value passed to show() is still
+			// in use, but it doesn't matter, since show() returns before that.
+			want:    []string{`show(p);`, `p.X = 1;`},
+			notWant: []string{`$clone`},
+		}, {
+			name: `exposed`,
+			// The variable is returned by pointer, so its value must be copied.
+			want:    []string{`show($clone(p, Point));`, `return p;`},
+			notWant: []string{`[p]`, `$ptr`},
+		}, {
+			name: `calls`,
+			want: []string{
+				`keep($clone(p, Point));`, // Retained by the callee.
+				`move($clone(p, Point));`, // Modified by the callee.
+				`$clone(p, Point).Scaled(2)`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var code []byte
+			for _, d := range mainPkg.Declarations {
+				if d.FullName == `func:command-line-arguments.`+test.name {
+					code = d.FuncDeclCode
+				}
+			}
+			if code == nil {
+				t.Fatalf(`%s function declaration not found`, test.name)
+			}
+			for _, want := range test.want {
+				if !bytes.Contains(code, []byte(want)) {
+					t.Errorf("Expected %q in the generated code, but it was not found.", want)
+				}
+			}
+			for _, notWant := range test.notWant {
+				if bytes.Contains(code, []byte(notWant)) {
+					t.Errorf("Expected no %q in the generated code, but it was found.", notWant)
+				}
+			}
+			if t.Failed() {
+				t.Logf("Generated code:\n%s", code)
+			}
+		})
+	}
+}
+
+func TestSharedGenerics(t *testing.T) {
+	src := `
+		package main
+
+		type Point struct{ X, Y int }
+
+		func Filter[T any](s []T, keep func(T) bool) []T {
+			var out []T
+			for _, v := range s {
+				if keep(v) {
+					out = append(out, v)
+				}
+			}
+			return out
+		}
+
+		func Contains[T comparable](s []T, v T) bool {
+			for _, x := range s {
+				if x == v {
+					return true
+				}
+			}
+			return false
+		}
+
+		func main() {
+			println(len(Filter([]int{1, 2}, func(int) bool { return true })))
+			println(len(Filter([]string{"a"}, func(string) bool { return true })))
+			println(len(Filter([]Point{{}}, func(Point) bool { return true })))
+			println(Contains([]int{1}, 1), Contains([]string{"a"}, "a"))
+		}`
+
+	tests := []struct {
+		name    string
+		opts    Options
+		want    []string
+		notWant []string
+	}{
+		{
+			name: `shared`,
+			opts: Options{NoInline: true, SharedGenerics: true},
+			want: []string{
+				`FilterShared = function($dict) {`,
+				`Filter[0 /* int */] = FilterShared([$Int]);`,
+				`Filter[1 /* string */] = FilterShared([$String]);`,
+				`$sliceType($dict[0]).nil`,
+				// Struct type arguments need copying semantics and get a dedicated copy.
+				`Filter[2 /* command-line-arguments.Point */] = function`,
+				// Comparisons depend on the type argument.
+				`Contains[3 /* int */] = function`,
+				`Contains[4 /* string */] = function`,
+			},
+			notWant: []string{`ContainsShared`},
+		}, {
+			name:    `disabled`,
+			opts:    Options{NoInline: true},
+			want:    []string{`Filter[0 /* int */] = function`},
+			notWant: []string{`FilterShared`, `$dict`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := srctesting.ParseSources(t,
+				[]srctesting.Source{{Name: `main.go`, Contents: []byte(src)}},
+				nil)
+			archives := compileProjectWithOptions(t, root, test.opts)
+			code := []byte(renderPackage(t, archives[root.PkgPath], false))
+			for _, want := range test.want {
+				if !bytes.Contains(code, []byte(want)) {
+					t.Errorf("Expected %q in the generated code, but it was not found.", want)
+				}
+			}
+			for _, notWant := range test.notWant {
+				if bytes.Contains(code, []byte(notWant)) {
+					t.Errorf("Expected no %q in the generated code, but it was found.", notWant)
+				}
+			}
+			if t.Failed() {
+				t.Logf("Generated code:\n%s", code)
+			}
+		})
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	src := `
+		package main
+
+		func recv(c chan int) int {
+			defer func() {
+				if r := recover(); r != nil {
+					println(r)
+				}
+			}()
+			return <-c + 1
+		}
+
+		func sum(a, b int) int { return a + b }
+
+		func main() {
+			c := make(chan int, 1)
+			c <- 1
+			println(sum(recv(c), 2))
+		}`
+
+	tests := []struct {
+		name    string
+		opts    Options
+		want    []string
+		notWant []string
+	}{
+		{
+			name: `generators`,
+			opts: Options{NoInline: true, Generators: true},
+			want: []string{
+				`recv = function* recv$1(c) {`,
+				`sum = function sum$1(a, b) {`,
+				`(yield* $await($recv(c)))`,
+				`console.log(sum((yield* $await(recv(c))), 2));`,
+				`yield* $deferredCalls($deferred, $err);`,
+				`var $canRecover = $isDeferredCall();`,
+				`$recover($canRecover)`,
+			},
+			notWant: []string{`$restore`, `$blk`, `$s = `},
+		}, {
+			name:    `disabled`,
+			opts:    Options{NoInline: true},
+			want:    []string{`$restore`, `$blk`},
+			notWant: []string{`function*`, `yield*`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := srctesting.ParseSources(t,
+				[]srctesting.Source{{Name: `main.go`, Contents: []byte(src)}},
+				nil)
+			archives := compileProjectWithOptions(t, root, test.opts)
+			code := []byte(renderPackage(t, archives[root.PkgPath], false))
+			for _, want := range test.want {
+				if !bytes.Contains(code, []byte(want)) {
+					t.Errorf("Expected %q in the generated code, but it was not found.", want)
+				}
+			}
+			for _, notWant := range test.notWant {
+				if bytes.Contains(code, []byte(notWant)) {
+					t.Errorf("Expected no %q in the generated code, but it was found.", notWant)
+				}
+			}
+			if t.Failed() {
+				t.Logf("Generated code:\n%s", code)
+			}
+		})
+	}
+}
+
+func TestUnchecked(t *testing.T) {
+	src := `
+		package main
+
+		type point struct{ X, Y int }
+
+		func main() {
+			s := []int{1, 2}
+			a := [2]int{}
+			p := &point{}
+			pa := &a
+			i, j := 1, 0
+			s[i] = a[i]
+			pa[i] = *&s[i]
+			q := *p
+			var k, l int64 = 1, 0
+			println(s[i]/j, s[i]%j, &p.Y, q.X, k/l)
+		}`
+
+	checks := []string{
+		`$throwRuntimeError("index out of range")`,
+		`$throwNilPointerError()`,
+		`.nilCheck`,
+		`$throwRuntimeError("integer divide by zero")`,
+	}
+
+	tests := []struct {
+		name      string
+		opts      Options
+		directive bool
+		want      bool
+	}{
+		{name: `checked`, want: true},
+		{name: `option`, opts: Options{Unchecked: true}},
+		{name: `directive`, directive: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code := src
+			if test.directive {
+				code = "//gopherjs:unchecked" + code
+			}
+			root := srctesting.ParseSources(t,
+				[]srctesting.Source{{Name: `main.go`, Contents: []byte(code)}},
+				nil)
+			test.opts.NoInline = true
+			archives := compileProjectWithOptions(t, root, test.opts)
+			out := []byte(renderPackage(t, archives[root.PkgPath], false))
+			for _, check := range checks {
+				if got := bytes.Contains(out, []byte(check)); got != test.want {
+					t.Errorf("Got %q in the generated code: %v, want: %v.", check, got, test.want)
+				}
+			}
+			// 64-bit division checks for division by zero by itself.
+			if !bytes.Contains(out, []byte(`$div64(k, l, false)`)) {
+				t.Errorf("Got no checked 64-bit division in the generated code.")
+			}
+			if t.Failed() {
+				t.Logf("Generated code:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestStripReflection(t *testing.T) {
+	src := `
+		package main
+
+		type arg struct{}
+
+		type hidden struct {
+			N int ` + "`json:\"hidden\"`" + `
+		}
+
+		func (h hidden) Get(a arg) int { return h.N }
+
+		type shown struct {
+			N int ` + "`json:\"shown\"`" + `
+		}
+
+		func (s shown) Get() int { return s.N }
+
+		func main() {
+			h := hidden{N: 1}
+			var x any = shown{N: 2}
+			println(h.N, x != nil)
+		}`
+
+	tests := []struct {
+		name    string
+		opts    Options
+		want    []string
+		notWant []string
+	}{
+		{
+			name: `full metadata`,
+			want: []string{
+				`hidden.methods = [{prop: "Get", name: "Get", pkg: "", typ: $funcType([arg], [$Int], false)}];`,
+				`json:\"hidden\"`,
+				`arg = $newType(`,
+				`shown.methods = [{prop: "Get", name: "Get", pkg: "", typ: $funcType([], [$Int], false)}];`,
+				`json:\"shown\"`,
+			},
+		},
+		{
+			name: `stripped metadata`,
+			opts: Options{StripReflection: true},
+			want: []string{
+				`hidden.methods = [{prop: "Get", name: "Get"}];`,
+				`shown.methods = [{prop: "Get", name: "Get", pkg: "", typ: $funcType([], [$Int], false)}];`,
+				`json:\"shown\"`,
+			},
+			notWant: []string{
+				`json:\"hidden\"`,
+				`arg = $newType(`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := srctesting.ParseSources(t,
+				[]srctesting.Source{{Name: `main.go`, Contents: []byte(src)}},
+				nil)
+			test.opts.NoInline = true
+			archives := compileProjectWithOptions(t, root, test.opts)
+			out := renderPackage(t, archives[root.PkgPath], false)
+			for _, want := range test.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("Generated code doesn't contain %q.", want)
+				}
+			}
+			for _, notWant := range test.notWant {
+				if strings.Contains(out, notWant) {
+					t.Errorf("Generated code contains %q.", notWant)
+				}
+			}
+			if t.Failed() {
+				t.Logf("Generated code:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestStaticCompositeLiterals(t *testing.T) {
+	src := `
+		package main
+
+		type P struct{ X, Y int }
+
+		var points = []P{{1, 2}, {X: 3}}
+		var nested = P{X: P{1, 2}.X}
+		var ints = [3]int{1, 2, 3}
+		var names = [2]string{"a", "b"}
+		var byName = map[string]int{"a": 1, "b": 2}
+		var byID = map[int]P{1: {1, 2}}
+		var byFloat = map[float64]int{1.5: 1}
+
+		func main() {
+			println(len(points), nested.X, ints[0], names[0], byName["a"], byID[1].X, byFloat[1.5])
+		}`
+
+	root := srctesting.ParseSources(t,
+		[]srctesting.Source{{Name: `main.go`, Contents: []byte(src)}},
+		nil)
+	archives := compileProjectWithOptions(t, root, Options{NoInline: true})
+	out := renderPackage(t, archives[root.PkgPath], false)
+
+	want := []string{
+		`new Int32Array([1, 2, 3])`,
+		`["a", "b"]`,
+		`$newMap([["$a", { k: "a", v: 1 }], ["$b", { k: "b", v: 2 }]])`,
+		`$newMap([[1, { k: 1, v: new P.ptr(1, 2) }]])`,
+		`$makeMap($Float64.keyFor, [`,
+	}
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("Generated code doesn't contain %q.", w)
+		}
+	}
+	if strings.Contains(out, `$clone(new P.ptr`) {
+		t.Errorf("Generated code clones freshly created composite literals.")
+	}
+	if t.Failed() {
+		t.Logf("Generated code:\n%s", out)
+	}
+}
+
+func TestLazyTypes(t *testing.T) {
+	src := `
+		package main
+
+		type T struct{ values []int }
+
+		func (t T) Len() int { return len(t.values) }
+
+		func main() {
+			t := T{values: []int{1, 2}}
+			println(t.Len())
+		}`
+
+	root := srctesting.ParseSources(t,
+		[]srctesting.Source{{Name: `main.go`, Contents: []byte(src)}},
+		nil)
+	archives := compileProjectWithOptions(t, root, Options{NoInline: true, LazyTypes: true})
+	out := renderPackage(t, archives[root.PkgPath], false)
+
+	want := []string{
+		`sliceTypeInit = () => sliceType = $sliceType($Int);`,
+		`T.init("command-line-arguments", [$lazyField({prop: "values", name: "values", embedded: false, exported: false, tag: ""}, () => (sliceType || sliceTypeInit()))], false);`,
+		`$lazyMethods(T, () => [{prop: "Len", name: "Len", pkg: "", typ: $funcType([], [$Int], false)}]);`,
+		`new (sliceType || sliceTypeInit())([1, 2])`,
+	}
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("Generated code doesn't contain %q.", w)
+		}
+	}
+	if t.Failed() {
+		t.Logf("Generated code:\n%s", out)
+	}
+}
+
+func TestOpenCodedDefers(t *testing.T) {
+	src := `
+		package main
+
+		var count int
+
+		func inc(n int) { count += n }
+
+		func simple() {
+			defer inc(1)
+			if count > 0 {
+				defer inc(2)
+			}
+		}
+
+		func inLoop() {
+			for i := 0; i < 2; i++ {
+				defer inc(i)
+			}
+		}
+
+		func blocking() {
+			defer inc(1)
+			<-make(chan int)
+		}
+
+		func main() {
+			simple()
+			inLoop()
+			blocking()
+		}`
+
+	root := srctesting.ParseSources(t,
+		[]srctesting.Source{{Name: `main.go`, Contents: []byte(src)}},
+		nil)
+	archives := compileProjectWithOptions(t, root, Options{NoInline: true})
+	mainPkg := archives[root.PkgPath]
+
+	tests := []struct {
+		funcName string
+		want     []string
+		notWant  []string
+	}{
+		{
+			funcName: `simple`,
+			want: []string{
+				`$curGoroutine.deferStack.push($openDeferMarker);`,
+				`_defer = inc; _deferArg = 1; $deferBits |= 1;`,
+				`_defer$1 = inc; _deferArg$1 = 2; $deferBits |= 2;`,
+				`if (($deferBits & 2) !== 0) { $deferBits &= ~2; _defer$1(_deferArg$1); } if (($deferBits & 1) !== 0) { $deferBits &= ~1; _defer(_deferArg); }`,
+				`$callOpenDeferred($deferBits, [[_defer, [_deferArg]], [_defer$1, [_deferArg$1]]], $err);`,
+			},
+			notWant: []string{`$deferred`},
+		}, {
+			funcName: `inLoop`,
+			want:     []string{`$deferred.push([inc, [i]]);`},
+			notWant:  []string{`$openDeferMarker`},
+		}, {
+			funcName: `blocking`,
+			want:     []string{`$deferred.push([inc, [1]]);`},
+			notWant:  []string{`$openDeferMarker`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.funcName, func(t *testing.T) {
+			var code string
+			for _, d := range mainPkg.Declarations {
+				if d.FullName == `func:command-line-arguments.`+test.funcName {
+					code = string(d.FuncDeclCode)
+				}
+			}
+			if code == "" {
+				t.Fatalf(`%s function declaration not found`, test.funcName)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(code, want) {
+					t.Errorf("Generated code doesn't contain %q.", want)
+				}
+			}
+			for _, notWant := range test.notWant {
+				if strings.Contains(code, notWant) {
+					t.Errorf("Generated code contains %q.", notWant)
+				}
+			}
+			if t.Failed() {
+				t.Logf("Generated code:\n%s", code)
+			}
+		})
+	}
+}
+
+func TestMangleProps(t *testing.T) {
+	src := `
+		package main
+
+		type T struct {
+			buf  []int
+			Size int
+		}
+
+		func (t *T) grow() { t.buf = append(t.buf, t.Size) }
+
+		func (t *T) Grow() { t.grow() }
+
+		func main() {
+			t := &T{}
+			t.Grow()
+			println(len(t.buf))
+		}`
+
+	root := srctesting.ParseSources(t,
+		[]srctesting.Source{{Name: `main.go`, Contents: []byte(src)}},
+		nil)
+	archives := compileProjectWithOptions(t, root, Options{NoInline: true, MangleProps: true})
+	out := renderPackage(t, archives[root.PkgPath], false)
+
+	buf := mangledProp(`command-line-arguments`, `buf`)
+	grow := mangledProp(`command-line-arguments`, `grow`)
+	want := []string{
+		`this.` + buf + ` = sliceType.nil;`,
+		`this.Size = 0;`,
+		`{prop: "` + buf + `", name: "buf", embedded: false, exported: false, typ: sliceType, tag: ""}`,
+		`{prop: "` + grow + `", name: "grow", pkg: "command-line-arguments", typ: $funcType([], [], false)}`,
+		`$ptrType(T).prototype.` + grow + ` = function grow() {`,
+		`t.` + grow + `();`,
+		`$ptrType(T).prototype.Grow = function Grow() {`,
+	}
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("Generated code doesn't contain %q.", w)
+		}
+	}
+	if t.Failed() {
+		t.Logf("Generated code:\n%s", out)
+	}
+}
+
+func TestPropMangler(t *testing.T) {
+	rare := mangledProp(`example.com/pkg`, `rare`)
+	common := mangledProp(`example.com/pkg`, `common`)
+	d := &Decl{FuncDeclCode: []byte(`x.` + common + ` = x.` + rare + ` + x.` + common + `;`)}
+	pkg := &Archive{Declarations: []*Decl{d}}
+
+	m := newPropMangler([]*Archive{pkg}, map[*Decl]struct{}{d: {}}, nil)
+	got := string(m.rename(d.FuncDeclCode))
+	if want := `x.$0 = x.$1 + x.$0;`; got != want {
+		t.Errorf("Got renamed code %q, want %q.", got, want)
+	}
+
+	unseen := mangledProp(`example.com/pkg`, `unseen`)
+	if got := string(m.rename([]byte(`"` + unseen + `"`))); got != `"$2"` {
+		t.Errorf("Got renamed unseen marker %q, want %q.", got, `"$2"`)
+	}
+
+	for i := 0; i < 100; i++ {
+		m.allocate(mangledProp(`example.com/pkg`, `p`+strconv.Itoa(i)))
+	}
+	seen := map[string]bool{}
+	for _, name := range m.names {
+		if seen[name] {
+			t.Errorf("Name %q allocated more than once.", name)
+		}
+		seen[name] = true
+	}
+}
+
+func TestStableNames(t *testing.T) {
+	src := `
+		package main
+
+		type point struct{ x, y int }
+
+		var origin = &point{}
+
+		func join(ps []*point) string {
+			var parts []string
+			for _, p := range ps {
+				parts = append(parts, string(rune(p.x)))
+			}
+			return parts[0]
+		}
+
+		func main() {
+			println(join([]*point{origin}))
+		}`
+	// The extra declarations are allocated names before the existing ones.
+	extra := strings.Replace(src, "type point", "func extra() map[int]bool { return nil }\n\n\t\ttype point", 1)
+
+	joinCode := func(src string, opts Options) string {
+		root := srctesting.ParseSources(t,
+			[]srctesting.Source{{Name: `main.go`, Contents: []byte(src)}},
+			nil)
+		archives := compileProjectWithOptions(t, root, opts)
+		for _, d := range archives[root.PkgPath].Declarations {
+			if d.FullName == `func:command-line-arguments.join` {
+				// Strip source map hints, which change with the positions.
+				buf := &bytes.Buffer{}
+				if _, err := (&sourcemapx.Filter{Writer: buf}).Write(d.FuncDeclCode); err != nil {
+					t.Fatal(err)
+				}
+				return buf.String()
+			}
+		}
+		t.Fatalf("Declaration of join not found.")
+		return ""
+	}
+
+	opts := Options{Minify: true, NoInline: true}
+	if joinCode(src, opts) == joinCode(extra, opts) {
+		t.Fatalf("Got identical code without stable names, want the test to exercise renaming.")
+	}
+
+	opts.StableNames = true
+	got, want := joinCode(extra, opts), joinCode(src, opts)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Code of an unrelated declaration changed (-want,+got):\n%s", diff)
+	}
+}
+
+func TestFuncNameMappings(t *testing.T) {
+	src := `
+		package main
+
+		func run(f func()) { f() }
+
+		func main() {
+			run(func() {
+				run(func() {})
+			})
+			run(nil)
+		}`
+	root := srctesting.ParseSources(t,
+		[]srctesting.Source{{Name: `main.go`, Contents: []byte(src)}},
+		nil)
+	archives := compileProjectWithOptions(t, root, Options{})
+	archive := archives[root.PkgPath]
+
+	var got []string
+	for _, d := range archive.Declarations {
+		if d.FullName != `func:command-line-arguments.main` {
+			continue
+		}
+		f := &sourcemapx.Filter{Writer: io.Discard, FileSet: archive.FileSet}
+		f.EnableMapping("main.js", "", "", false)
+		if _, err := f.Write(d.FuncDeclCode); err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range f.SourceMap().DecodedMappings() {
+			if m.OriginalName != "" {
+				got = append(got, m.OriginalName)
+			}
+		}
+	}
+
+	// The enclosing function is named again after each function literal ends.
+	want := []string{
+		"command-line-arguments.main",
+		"command-line-arguments.main.func1",
+		"command-line-arguments.main.func1.func1",
+		"command-line-arguments.main.func1",
+		"command-line-arguments.main",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Got named mappings diff (-want,+got):\n%s", diff)
+	}
+}
+
+func TestStableName(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		name := stableName(`key`, i)
+		if name != stableName(`key`, i) {
+			t.Fatalf("Got different names for the same key and attempt.")
+		}
+		if !regexp.MustCompile(`^[A-Z][0-9][0-9a-zA-Z]{2}$`).MatchString(name) {
+			t.Errorf("Got name %q, want an upper case letter, a digit and two alphanumeric characters.", name)
+		}
+		seen[name] = true
+	}
+	if len(seen) < 990 {
+		t.Errorf("Got %d distinct names for 1000 attempts, want collisions to be rare.", len(seen))
+	}
+}
+
 func collectDeclInstances(t *testing.T, pkg *Archive) []string {
 	t.Helper()
 
@@ -1142,6 +2113,13 @@ func compile(t *testing.T, sourceFiles []srctesting.Source, minify bool) string
 // compileProject compiles the given root package and all packages imported by the root.
 // This returns the compiled archives of all packages keyed by their import path.
 func compileProject(t *testing.T, root *packages.Package, minify bool) map[string]*Archive {
+	t.Helper()
+	return compileProjectWithOptions(t, root, Options{Minify: minify})
+}
+
+// compileProjectWithOptions is like compileProject, but allows to specify
+// all compiler options.
+func compileProjectWithOptions(t *testing.T, root *packages.Package, opts Options) map[string]*Archive {
 	t.Helper()
 	pkgMap := map[string]*packages.Package{}
 	packages.Visit([]*packages.Package{root}, nil, func(pkg *packages.Package) {
@@ -1178,7 +2156,7 @@ func compileProject(t *testing.T, root *packages.Package, minify bool) map[strin
 
 	archives := map[string]*Archive{}
 	for _, srcs := range allSrcs {
-		a, err := Compile(srcs, tContext, minify)
+		a, err := Compile(srcs, tContext, opts)
 		if err != nil {
 			t.Fatal(`failed to compile:`, err)
 		}
@@ -1192,13 +2170,13 @@ func renderPackage(t *testing.T, archive *Archive, minify bool) string {
 
 	sel := &dce.Selector[*Decl]{}
 	for _, d := range archive.Declarations {
-		sel.Include(d, false)
+		sel.Include(d)
 	}
 	selection := sel.AliveDecls()
 
 	buf := &bytes.Buffer{}
 
-	if err := WritePkgCode(archive, selection, linkname.GoLinknameSet{}, minify, &sourcemapx.Filter{Writer: buf}); err != nil {
+	if err := WritePkgCode(archive, selection, sel.ReflectedDecls(), linkname.GoLinknameSet{}, minify, &sourcemapx.Filter{Writer: buf}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -1233,13 +2211,7 @@ func declSelection(t *testing.T, sourceFiles []srctesting.Source, auxFiles []src
 		packages = append(packages, archives[path])
 	}
 
-	sel := &dce.Selector[*Decl]{}
-	for _, pkg := range packages {
-		for _, d := range pkg.Declarations {
-			sel.Include(d, false)
-		}
-	}
-	dceSelection := sel.AliveDecls()
+	dceSelection, _ := selectDecls(packages)
 
 	return &selectionTester{
 		t:            t,
@@ -41,6 +41,12 @@ func mainFuncDeclFullName() string {
 	return `init:main`
 }
 
+// sharedFuncDeclFullName returns a unique name for a package-level function
+// declaration that is shared by multiple instances of a generic function.
+func sharedFuncDeclFullName(o *types.Func) string {
+	return `sharedFunc:` + symbol.New(o).String()
+}
+
 // funcDeclFullName returns a name for a package-level function
 // declaration for the given instance of a function.
 // The name is unique unless the function is an `init` function.
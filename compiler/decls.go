@@ -69,6 +69,17 @@ type Decl struct {
 	// (e.g. struct fields, array type sizes, element types, etc.).
 	// This is added to the finish setup phase to have access to all packages.
 	TypeInitCode []byte
+	// JavaScript code that initializes a type's method list with only the
+	// method names needed to promote methods of embedded fields. It's used
+	// instead of MethodListCode if the type is never reflected upon.
+	MinimalMethodListCode []byte
+	// JavaScript code that initializes the rest of a type's metadata without
+	// struct field tags. It's used instead of TypeInitCode if the type is never
+	// reflected upon.
+	MinimalTypeInitCode []byte
+	// Set to true if the minimal reflection metadata variants above were
+	// generated, see Options.StripReflection.
+	MinimalMetadata bool
 	// JavaScript code that needs to be executed during the package init phase to
 	// set the symbol up (e.g. initialize package-level variable value).
 	InitCode []byte
@@ -92,6 +103,8 @@ func (d Decl) minify() Decl {
 	d.ExportFuncCode = removeWhitespace(d.ExportFuncCode, true)
 	d.MethodListCode = removeWhitespace(d.MethodListCode, true)
 	d.TypeInitCode = removeWhitespace(d.TypeInitCode, true)
+	d.MinimalMethodListCode = removeWhitespace(d.MinimalMethodListCode, true)
+	d.MinimalTypeInitCode = removeWhitespace(d.MinimalTypeInitCode, true)
 	d.InitCode = removeWhitespace(d.InitCode, true)
 	return d
 }
@@ -101,6 +114,16 @@ func (d *Decl) Dce() *dce.Info {
 	return &d.DCEInfo
 }
 
+// metadataCode returns the code that initializes reflection metadata about
+// the type's method list and the rest of the type's metadata. Unless the type
+// is reflected upon, the minimal metadata is returned if it's available.
+func (d *Decl) metadataCode(reflected bool) (methodList, typeInit []byte) {
+	if reflected || !d.MinimalMetadata {
+		return d.MethodListCode, d.TypeInitCode
+	}
+	return d.MinimalMethodListCode, d.MinimalTypeInitCode
+}
+
 // topLevelObjects extracts package-level variables, functions and named types
 // from the package AST.
 func (fc *funcContext) topLevelObjects(srcs *sources.Sources) (vars []*types.Var, functions []*ast.FuncDecl, typeNames typesutil.TypeNames) {
@@ -200,8 +223,10 @@ func (fc *funcContext) importInitializer(impPath string) ast.Stmt {
 	pkgVar := fc.pkgCtx.pkgVars[impPath]
 	id := fc.newIdent(fmt.Sprintf(`%s.$init`, pkgVar), types.NewSignatureType(nil, nil, nil, nil, nil, false))
 	call := &ast.CallExpr{Fun: id}
-	fc.Blocking[call] = true
-	fc.Flattened[call] = true
+	fc.markBlockingCall(call)
+	if !fc.pkgCtx.opts.Generators {
+		fc.Flattened[call] = true
+	}
 
 	return &ast.ExprStmt{X: call}
 }
@@ -330,6 +355,12 @@ func (fc *funcContext) funcDecls(functions []*ast.FuncDecl) ([]*Decl, error) {
 				})
 			}
 			funcDecls = append(funcDecls, varDecl)
+
+			if fc.pkgCtx.opts.SharedGenerics {
+				var sharedDecls []*Decl
+				sharedDecls, instances = fc.sharedFuncDecls(fun, instances)
+				funcDecls = append(funcDecls, sharedDecls...)
+			}
 		}
 
 		for _, inst := range instances {
@@ -364,6 +395,11 @@ func (fc *funcContext) newFuncDecl(fun *ast.FuncDecl, inst typeparams.Instance)
 		LinkingName: symbol.New(o),
 	}
 	d.Dce().SetName(o, inst.TNest, inst.TArgs)
+	if impl, found := fc.pkgCtx.linknames.FindImplementation(d.LinkingName); found {
+		// The decl is bound to the implementation at runtime (see
+		// $initLinknames), so the implementation is alive whenever the decl is.
+		d.Dce().AddLinkDep(impl)
+	}
 
 	if typesutil.IsMethod(o) {
 		recv := typesutil.RecvType(o.Type().(*types.Signature)).Obj()
@@ -387,13 +423,93 @@ func (fc *funcContext) newFuncDecl(fun *ast.FuncDecl, inst typeparams.Instance)
 	return d
 }
 
+// sharedFuncDecls returns Decls for instances of a generic function that share
+// a single copy of the function code, and the remaining instances that must be
+// compiled separately.
+//
+// The shared code is wrapped into a factory function, which accepts a runtime
+// dictionary of type arguments and returns the function for the given
+// instance. Instances may share code only if the function is shape-agnostic
+// (see analysis.Info.IsShapeAgnostic) and none of their type arguments are
+// structs or arrays, since copying of such values requires cloning.
+func (fc *funcContext) sharedFuncDecls(fun *ast.FuncDecl, instances []typeparams.Instance) ([]*Decl, []typeparams.Instance) {
+	o := fc.pkgCtx.Defs[fun.Name].(*types.Func)
+	if !fc.pkgCtx.IsShapeAgnostic(o) {
+		return nil, instances
+	}
+
+	var shared, rest []typeparams.Instance
+	for _, inst := range instances {
+		if isShareableInstance(inst) && (len(shared) == 0 || fc.pkgCtx.IsBlocking(inst) == fc.pkgCtx.IsBlocking(shared[0])) {
+			shared = append(shared, inst)
+		} else {
+			rest = append(rest, inst)
+		}
+	}
+	if len(shared) < 2 {
+		return nil, instances // Nothing to share.
+	}
+
+	factory := fc.newPkgVariable(o.Name()+"Shared", "shared:"+symbol.New(o).String())
+	factoryDecl := &Decl{
+		FullName: sharedFuncDeclFullName(o),
+		Vars:     []string{factory},
+	}
+	// The factory is needed whenever any instance of the function is.
+	factoryDecl.Dce().SetName(o, nil, nil)
+	fc.pkgCtx.CollectDCEDeps(factoryDecl, func() {
+		c := fc.nestedFunctionContext(fc.pkgCtx.FuncInfo(shared[0]), typeparams.Instance{Object: o})
+		c.dictParams = o.Type().(*types.Signature).TypeParams()
+		body := c.translateFunctionBody(fun.Type, nil, fun.Body)
+		factoryDecl.FuncDeclCode = []byte(fmt.Sprintf("\t\t%s = function($dict) { return %s; };\n", factory, body))
+	})
+
+	decls := []*Decl{factoryDecl}
+	for _, inst := range shared {
+		d := &Decl{
+			FullName:    funcDeclFullName(inst),
+			Blocking:    fc.pkgCtx.IsBlocking(inst),
+			LinkingName: symbol.New(o),
+			RefExpr:     fc.instName(inst),
+		}
+		d.Dce().SetName(o, inst.TNest, inst.TArgs)
+		fc.pkgCtx.CollectDCEDeps(d, func() {
+			dict := make([]string, len(inst.TArgs))
+			for i, t := range inst.TArgs {
+				dict[i] = fc.typeName(t)
+				// The shared code may expose values of any type argument to the
+				// reflection, since it can't be resolved at compile time.
+				fc.reflectType(t)
+			}
+			d.FuncDeclCode = []byte(fmt.Sprintf("\t\t%s = %s([%s]);\n", fc.instName(inst), factory, strings.Join(dict, ", ")))
+		})
+		decls = append(decls, d)
+	}
+	return decls, rest
+}
+
+// isShareableInstance returns true if the instance of a shape-agnostic generic
+// function may use the shared function code.
+func isShareableInstance(inst typeparams.Instance) bool {
+	if len(inst.TNest) != 0 {
+		return false
+	}
+	for _, t := range inst.TArgs {
+		switch t.Underlying().(type) {
+		case *types.Struct, *types.Array:
+			return false
+		}
+	}
+	return true
+}
+
 // callInitFunc returns an AST statement for calling the given instance of the
 // package's init() function.
 func (fc *funcContext) callInitFunc(init *types.Func) ast.Stmt {
 	id := fc.newIdentFor(init)
 	call := &ast.CallExpr{Fun: id}
 	if fc.pkgCtx.IsBlocking(typeparams.Instance{Object: init}) {
-		fc.Blocking[call] = true
+		fc.markBlockingCall(call)
 	}
 	return &ast.ExprStmt{X: call}
 }
@@ -417,8 +533,10 @@ func (fc *funcContext) callMainFunc(main *types.Func) ast.Stmt {
 		},
 	}
 	if fc.pkgCtx.IsBlocking(typeparams.Instance{Object: main}) {
-		fc.Blocking[call] = true
-		fc.Flattened[ifStmt] = true
+		fc.markBlockingCall(call)
+		if !fc.pkgCtx.opts.Generators {
+			fc.Flattened[ifStmt] = true
+		}
 	}
 
 	return ifStmt
@@ -504,6 +622,7 @@ func (fc *funcContext) newNamedTypeInstDecl(inst typeparams.Instance) (*Decl, er
 		FullName: typeDeclFullName(inst),
 	}
 	d.Dce().SetName(inst.Object, inst.TNest, inst.TArgs)
+	d.Dce().SetAsReflectable()
 	fc.pkgCtx.CollectDCEDeps(d, func() {
 		// Code that declares a JS type (i.e. prototype) for each Go type.
 		d.TypeDeclCode = fc.CatchOutput(0, func() {
@@ -528,40 +647,86 @@ func (fc *funcContext) newNamedTypeInstDecl(inst typeparams.Instance) (*Decl, er
 		})
 
 		// Reflection metadata about methods the type has.
-		d.MethodListCode = fc.CatchOutput(1, func() {
-			if _, ok := underlying.(*types.Interface); ok {
-				return
-			}
-			var methods []string
-			var ptrMethods []string
-			for i := 0; i < instanceType.NumMethods(); i++ {
-				entry, isPtr := fc.methodListEntry(instanceType.Method(i))
-				if isPtr {
-					ptrMethods = append(ptrMethods, entry)
-				} else {
-					methods = append(methods, entry)
-				}
-			}
-			if len(methods) > 0 {
-				fc.Printf("%s.methods = [%s];", fc.instName(inst), strings.Join(methods, ", "))
-			}
-			if len(ptrMethods) > 0 {
-				fc.Printf("%s.methods = [%s];", fc.typeName(types.NewPointer(instanceType)), strings.Join(ptrMethods, ", "))
-			}
-		})
+		d.MethodListCode = fc.methodListCode(inst, instanceType, false)
 
 		// Certain types need to run additional type-specific logic to fully
 		// initialize themselves.
+		// With Options.LazyTypes, the field types of structs are constructed on
+		// first use too.
+		fc.lazyFields = fc.pkgCtx.opts.LazyTypes
+		defer func() { fc.lazyFields = false }()
 		switch t := underlying.(type) {
 		case *types.Array, *types.Chan, *types.Interface, *types.Map, *types.Pointer, *types.Slice, *types.Signature, *types.Struct:
 			d.TypeInitCode = fc.CatchOutput(1, func() {
 				fc.Printf("%s.init(%s);", fc.instName(inst), fc.initArgs(t))
 			})
 		}
+
+		if fc.pkgCtx.opts.StripReflection {
+			d.MinimalMetadata = true
+			d.MinimalMethodListCode = fc.methodListCode(inst, instanceType, true)
+			d.MinimalTypeInitCode = d.TypeInitCode
+			if t, ok := underlying.(*types.Struct); ok {
+				fc.stripTags = true
+				d.MinimalTypeInitCode = fc.CatchOutput(1, func() {
+					fc.Printf("%s.init(%s);", fc.instName(inst), fc.initArgs(t))
+				})
+				fc.stripTags = false
+			}
+		}
 	})
 	return d, nil
 }
 
+// methodListCode returns JS code that initializes reflection metadata about
+// methods of the given named type instance and the pointer to it.
+//
+// If minimal is true, method entries only contain the method names, which the
+// runtime needs to promote methods of embedded fields. Otherwise, the entries
+// contain the method signatures, which are only needed by the reflection and
+// type assertions. With Options.StripReflection their dependencies are
+// collected separately, so that they are only alive if the type is reflected
+// upon.
+func (fc *funcContext) methodListCode(inst typeparams.Instance, instanceType *types.Named, minimal bool) []byte {
+	if _, ok := instanceType.Underlying().(*types.Interface); ok {
+		return nil
+	}
+	var methods []string
+	var ptrMethods []string
+	for i := 0; i < instanceType.NumMethods(); i++ {
+		method := instanceType.Method(i)
+		var entry string
+		var isPtr bool
+		switch {
+		case minimal:
+			entry, isPtr = fc.minimalMethodListEntry(method)
+		case fc.pkgCtx.opts.StripReflection:
+			fc.pkgCtx.CollectDCEMetadataDeps(func() {
+				entry, isPtr = fc.methodListEntry(method)
+			})
+		default:
+			entry, isPtr = fc.methodListEntry(method)
+		}
+		if isPtr {
+			ptrMethods = append(ptrMethods, entry)
+		} else {
+			methods = append(methods, entry)
+		}
+	}
+	setMethods := "%s.methods = [%s];"
+	if fc.pkgCtx.opts.LazyTypes {
+		setMethods = "$lazyMethods(%s, () => [%s]);"
+	}
+	return fc.CatchOutput(1, func() {
+		if len(methods) > 0 {
+			fc.Printf(setMethods, fc.instName(inst), strings.Join(methods, ", "))
+		}
+		if len(ptrMethods) > 0 {
+			fc.Printf(setMethods, fc.typeName(types.NewPointer(instanceType)), strings.Join(ptrMethods, ", "))
+		}
+	})
+}
+
 // structConstructor returns JS constructor function for a struct type.
 func (fc *funcContext) structConstructor(t *types.Struct) string {
 	if t.NumFields() == 0 {
@@ -572,24 +737,31 @@ func (fc *funcContext) structConstructor(t *types.Struct) string {
 
 	ctrArgs := make([]string, t.NumFields())
 	for i := 0; i < t.NumFields(); i++ {
-		ctrArgs[i] = fieldName(t, i) + "_"
+		ctrArgs[i] = fc.fieldName(t, i) + "_"
 	}
 
 	fmt.Fprintf(constructor, "function(%s) {\n", strings.Join(ctrArgs, ", "))
 	fmt.Fprintf(constructor, "\t\tthis.$val = this;\n")
+	// Count the allocation for runtime.ReadMemStats, see $mallocs. The size of
+	// types nested in generic functions depends on the type arguments.
+	if typeparams.ContainsTypeParams(t) {
+		fmt.Fprintf(constructor, "\t\tif ($countAllocs) { $mallocs++; }\n")
+	} else {
+		fmt.Fprintf(constructor, "\t\tif ($countAllocs) { $mallocs++; $allocBytes += %d; }\n", sizes32.Sizeof(t))
+	}
 
 	// If no arguments were passed, zero-initialize all fields.
 	fmt.Fprintf(constructor, "\t\tif (arguments.length === 0) {\n")
 	for i := 0; i < t.NumFields(); i++ {
 		zeroValue := fc.zeroValue(fc.fieldType(t, i))
-		fmt.Fprintf(constructor, "\t\t\tthis.%s = %s;\n", fieldName(t, i), fc.translateExpr(zeroValue).String())
+		fmt.Fprintf(constructor, "\t\t\tthis.%s = %s;\n", fc.fieldName(t, i), fc.translateExpr(zeroValue).String())
 	}
 	fmt.Fprintf(constructor, "\t\t\treturn;\n")
 	fmt.Fprintf(constructor, "\t\t}\n")
 
 	// Otherwise initialize fields with the provided values.
 	for i := 0; i < t.NumFields(); i++ {
-		fmt.Fprintf(constructor, "\t\tthis.%[1]s = %[1]s_;\n", fieldName(t, i))
+		fmt.Fprintf(constructor, "\t\tthis.%[1]s = %[1]s_;\n", fc.fieldName(t, i))
 	}
 	fmt.Fprintf(constructor, "\t}")
 	return constructor.String()
@@ -599,7 +771,7 @@ func (fc *funcContext) structConstructor(t *types.Struct) string {
 // function for runtime reflection. It returns isPtr=true if the method belongs
 // to the pointer-receiver method list.
 func (fc *funcContext) methodListEntry(method *types.Func) (entry string, isPtr bool) {
-	name := sanitizeName(method.Name())
+	name := fc.methodName(method)
 	pkgPath := ""
 	if !method.Exported() {
 		pkgPath = method.Pkg().Path()
@@ -611,6 +783,14 @@ func (fc *funcContext) methodListEntry(method *types.Func) (entry string, isPtr
 	return entry, isPtr
 }
 
+// minimalMethodListEntry is like methodListEntry, but the returned fragment
+// only describes the method's name for types that aren't reflected upon.
+func (fc *funcContext) minimalMethodListEntry(method *types.Func) (entry string, isPtr bool) {
+	entry = fmt.Sprintf(`{prop: "%s", name: %s}`, fc.methodName(method), encodeString(method.Name()))
+	_, isPtr = method.Type().(*types.Signature).Recv().Type().(*types.Pointer)
+	return entry, isPtr
+}
+
 // anonTypeDecls returns a list of Decls corresponding to anonymous Go types
 // encountered in the package.
 //
@@ -618,6 +798,10 @@ func (fc *funcContext) methodListEntry(method *types.Func) (entry string, isPtr
 // identifiers that were auto-assigned to them. They must be sorted in the
 // topological initialization order (e.g. `[]int` is before `struct{f []int}`).
 //
+// With Options.LazyTypes, the identifiers are unset until the first use of the
+// type, which calls a function that constructs the type and assigns it to the
+// identifier, see funcContext.typeName.
+//
 // See also typesutil.AnonymousTypes.
 func (fc *funcContext) anonTypeDecls(anonTypes []*types.TypeName) []*Decl {
 	if !fc.isRoot() {
@@ -630,8 +814,15 @@ func (fc *funcContext) anonTypeDecls(anonTypes []*types.TypeName) []*Decl {
 			Vars:     []string{t.Name()},
 		}
 		d.Dce().SetName(t, nil, nil)
+		d.Dce().SetAsReflectable()
 		fc.pkgCtx.CollectDCEDeps(d, func() {
-			d.AnonTypeDeclCode = []byte(fmt.Sprintf("\t\t%s = $%sType(%s);\n", t.Name(), strings.ToLower(typeKind(t.Type())[5:]), fc.initArgs(t.Type())))
+			constructor := fmt.Sprintf("$%sType(%s)", strings.ToLower(typeKind(t.Type())[5:]), fc.initArgs(t.Type()))
+			if init, ok := fc.pkgCtx.anonTypeInits[t]; ok {
+				d.Vars = append(d.Vars, init)
+				d.AnonTypeDeclCode = []byte(fmt.Sprintf("\t\t%s = () => %s = %s;\n", init, t.Name(), constructor))
+				return
+			}
+			d.AnonTypeDeclCode = []byte(fmt.Sprintf("\t\t%s = %s;\n", t.Name(), constructor))
 		})
 		decls = append(decls, d)
 	}
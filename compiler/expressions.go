@@ -144,7 +144,7 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 				for len(elements) <= i {
 					elements = append(elements, zero)
 				}
-				elements[i] = fc.translateImplicitConversionWithCloning(element, elementType).String()
+				elements[i] = fc.translateCompositeElement(element, elementType).String()
 				i++
 			}
 			return elements
@@ -160,14 +160,31 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 			for len(elements) < int(t.Len()) {
 				elements = append(elements, zero)
 			}
-			return fc.formatExpr(`$toNativeArray(%s, [%s])`, typeKind(t.Elem()), strings.Join(elements, ", "))
+			if native := nativeArrayType(t.Elem()); native != "" {
+				return fc.formatExpr(`new %s([%s])`, native, strings.Join(elements, ", "))
+			}
+			return fc.formatExpr(`[%s]`, strings.Join(elements, ", "))
 		case *types.Slice:
 			return fc.formatExpr("new %s([%s])", fc.typeName(exprType), strings.Join(collectIndexedElements(t.Elem()), ", "))
 		case *types.Map:
 			entries := make([]string, len(e.Elts))
+			keys := make([]string, len(e.Elts))
+			static := len(e.Elts) > 0
 			for i, element := range e.Elts {
 				kve := element.(*ast.KeyValueExpr)
-				entries[i] = fmt.Sprintf("{ k: %s, v: %s }", fc.translateImplicitConversionWithCloning(kve.Key, t.Key()), fc.translateImplicitConversionWithCloning(kve.Value, t.Elem()))
+				k := fc.translateCompositeElement(kve.Key, t.Key()).String()
+				entries[i] = fmt.Sprintf("{ k: %s, v: %s }", k, fc.translateCompositeElement(kve.Value, t.Elem()))
+				if static {
+					keys[i], static = fc.staticMapKey(kve.Key, k, t.Key())
+				}
+			}
+			if static {
+				// All keys are known at compile time, so the JS Map can be
+				// constructed directly, without calling keyFor for each entry.
+				for i := range entries {
+					entries[i] = fmt.Sprintf("[%s, %s]", keys[i], entries[i])
+				}
+				return fc.formatExpr("$newMap([%s])", strings.Join(entries, ", "))
 			}
 			return fc.formatExpr("$makeMap(%s.keyFor, [%s])", fc.typeName(t.Key()), strings.Join(entries, ", "))
 		case *types.Struct:
@@ -178,7 +195,7 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 			}
 			if !isKeyValue {
 				for i, element := range e.Elts {
-					elements[i] = fc.translateImplicitConversionWithCloning(element, fc.fieldType(t, i)).String()
+					elements[i] = fc.translateCompositeElement(element, fc.fieldType(t, i)).String()
 				}
 			}
 			if isKeyValue {
@@ -189,7 +206,7 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 					kve := element.(*ast.KeyValueExpr)
 					for j := range elements {
 						if kve.Key.(*ast.Ident).Name == t.Field(j).Name() {
-							elements[j] = fc.translateImplicitConversionWithCloning(kve.Value, fc.fieldType(t, j)).String()
+							elements[j] = fc.translateCompositeElement(kve.Value, fc.fieldType(t, j)).String()
 							break
 						}
 					}
@@ -271,7 +288,7 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 				newSel := &ast.SelectorExpr{X: fc.newIdent("this.$target", fc.typeOf(x.X)), Sel: x.Sel}
 				fc.setType(newSel, exprType)
 				fc.pkgCtx.additionalSelections[newSel] = sel
-				if _, ok := fc.typeOf(x.X).Underlying().(*types.Pointer); ok {
+				if _, ok := fc.typeOf(x.X).Underlying().(*types.Pointer); ok && !fc.pkgCtx.opts.Unchecked {
 					return fc.formatExpr("(%1e === %2s.nil && $throwNilPointerError(), (%1e.$ptr_%3s || (%1e.$ptr_%3s = new %4s(function() { return %5e; }, function($v) { %6s }, %1e))))",
 						x.X, fc.typeName(fc.typeOf(x.X)), x.Sel.Name, fc.typeName(exprType), newSel, fc.translateAssign(newSel, fc.newIdent("$v", exprType), false))
 				}
@@ -281,10 +298,10 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 				// To allow a slice to be recreated from a `&s[i]` via casting a pointer back into the slice or using `unsafe.Slice`,
 				// we have to create pointer objects via `$indexPtr` even if the element is a struct or array, meaning ignore the `opIsStructOrArray` case.
 				if _, ok := fc.typeOf(x.X).Underlying().(*types.Slice); ok {
-					pattern := rangeCheck("$indexPtr(%1e.$array, %1e.$offset + %2f, %3s)", fc.pkgCtx.Types[x.Index].Value != nil, false)
+					pattern := fc.rangeCheck("$indexPtr(%1e.$array, %1e.$offset + %2f, %3s)", fc.pkgCtx.Types[x.Index].Value != nil, false)
 					return fc.formatExpr(pattern, x.X, x.Index, fc.typeName(exprType))
 				}
-				pattern := rangeCheck("$indexPtr(%1e, %2f, %3s)", fc.pkgCtx.Types[x.Index].Value != nil, true)
+				pattern := fc.rangeCheck("$indexPtr(%1e, %2f, %3s)", fc.pkgCtx.Types[x.Index].Value != nil, true)
 				return fc.formatExpr(pattern, x.X, x.Index, fc.typeName(exprType))
 			case *ast.StarExpr:
 				return fc.translateExpr(x.X)
@@ -297,7 +314,7 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 				Fun:  fc.newIdent("$recv", types.NewSignatureType(nil, nil, nil, types.NewTuple(types.NewVar(0, nil, "", t)), types.NewTuple(types.NewVar(0, nil, "", exprType), types.NewVar(0, nil, "", types.Typ[types.Bool])), false)),
 				Args: []ast.Expr{e.X},
 			}
-			fc.Blocking[call] = true
+			fc.markBlockingCall(call)
 			if _, isTuple := exprType.(*types.Tuple); isTuple {
 				return fc.formatExpr("%e", call)
 			}
@@ -352,6 +369,8 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 				case token.MUL:
 					return fc.formatExpr("$mul64(%e, %e)", e.X, e.Y)
 				case token.QUO:
+					// $div64 checks for division by zero even with Options.Unchecked,
+					// since the check is cheap compared to the division itself.
 					return fc.formatExpr("$div64(%e, %e, false)", e.X, e.Y)
 				case token.REM:
 					return fc.formatExpr("$div64(%e, %e, true)", e.X, e.Y)
@@ -417,6 +436,9 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 					if isUnsigned(basic) {
 						shift = ">>>"
 					}
+					if fc.pkgCtx.opts.Unchecked {
+						return fc.formatParenExpr("%e / %e %s 0", e.X, e.Y, shift)
+					}
 					return fc.formatExpr(`(%1s = %2e / %3e, (%1s === %1s && %1s !== 1/0 && %1s !== -1/0) ? %1s %4s 0 : $throwRuntimeError("integer divide by zero"))`, fc.newLocalVariable("_q"), e.X, e.Y, shift)
 				}
 				if basic.Kind() == types.Float32 {
@@ -424,6 +446,9 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 				}
 				return fc.formatExpr("%e / %e", e.X, e.Y)
 			case token.REM:
+				if fc.pkgCtx.opts.Unchecked {
+					return fc.formatParenExpr("%1e %% %2e", e.X, e.Y)
+				}
 				return fc.formatExpr(`(%1s = %2e %% %3e, %1s === %1s ? %1s : $throwRuntimeError("integer divide by zero"))`, fc.newLocalVariable("_r"), e.X, e.Y)
 			case token.SHL, token.SHR:
 				op := e.Op.String()
@@ -460,7 +485,7 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 		case token.ADD, token.LSS, token.LEQ, token.GTR, token.GEQ:
 			return fc.formatExpr("%e %t %e", e.X, e.Op, e.Y)
 		case token.LAND:
-			if fc.Blocking[e.Y] {
+			if fc.Blocking[e.Y] && !fc.pkgCtx.opts.Generators {
 				skipCase := fc.caseCounter
 				fc.caseCounter++
 				resultVar := fc.newLocalVariable("_v")
@@ -470,7 +495,7 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 			}
 			return fc.formatExpr("%e && %e", e.X, e.Y)
 		case token.LOR:
-			if fc.Blocking[e.Y] {
+			if fc.Blocking[e.Y] && !fc.pkgCtx.opts.Generators {
 				skipCase := fc.caseCounter
 				fc.caseCounter++
 				resultVar := fc.newLocalVariable("_v")
@@ -520,10 +545,10 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 			e.X = x
 			return fc.translateExpr(e)
 		case *types.Array:
-			pattern := rangeCheck("%1e[%2f]", fc.pkgCtx.Types[e.Index].Value != nil, true)
+			pattern := fc.rangeCheck("%1e[%2f]", fc.pkgCtx.Types[e.Index].Value != nil, true)
 			return fc.formatExpr(pattern, e.X, e.Index)
 		case *types.Slice:
-			return fc.formatExpr(rangeCheck("%1e.$array[%1e.$offset + %2f]", fc.pkgCtx.Types[e.Index].Value != nil, false), e.X, e.Index)
+			return fc.formatExpr(fc.rangeCheck("%1e.$array[%1e.$offset + %2f]", fc.pkgCtx.Types[e.Index].Value != nil, false), e.X, e.Index)
 		case *types.Map:
 			if typesutil.IsJsObject(fc.typeOf(e.Index)) {
 				fc.pkgCtx.errList = append(fc.pkgCtx.errList, types.Error{Fset: fc.pkgCtx.fileSet, Pos: e.Index.Pos(), Msg: "cannot use js.Object as map key"})
@@ -622,13 +647,13 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 			}
 			return fc.formatExpr("%e.%s", e.X, strings.Join(fields, "."))
 		case types.MethodVal:
-			return fc.formatExpr(`$methodVal(%s, "%s")`, fc.makeReceiver(e), sel.Obj().(*types.Func).Name())
+			return fc.formatExpr(`$methodVal(%s, "%s")`, fc.makeReceiver(e, false), fc.methodName(sel.Obj().(*types.Func)))
 		case types.MethodExpr:
 			fc.pkgCtx.DeclareDCEDep(sel.Obj(), inst.TNest, inst.TArgs)
 			if _, ok := sel.Recv().Underlying().(*types.Interface); ok {
-				return fc.formatExpr(`$ifaceMethodExpr("%s")`, sel.Obj().(*types.Func).Name())
+				return fc.formatExpr(`$ifaceMethodExpr("%s")`, fc.methodName(sel.Obj().(*types.Func)))
 			}
-			return fc.formatExpr(`$methodExpr(%s, "%s")`, fc.typeName(sel.Recv()), sel.Obj().(*types.Func).Name())
+			return fc.formatExpr(`$methodExpr(%s, "%s")`, fc.typeName(sel.Recv()), fc.methodName(sel.Obj().(*types.Func)))
 		default:
 			panic(fmt.Sprintf("unexpected sel.Kind(): %T", sel.Kind()))
 		}
@@ -651,7 +676,13 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 			if typesutil.IsJsPackage(obj.Pkg()) && obj.Name() == "InternalObject" {
 				return fc.translateExpr(e.Args[0])
 			}
-			return fc.translateCall(e, sig, fc.translateExpr(f))
+			if fn, ok := obj.(*types.Func); ok {
+				if inlined := fc.inlineCall(e, fn, nil); inlined != nil {
+					return inlined
+				}
+			}
+			fn, _ := obj.(*types.Func)
+			return fc.translateCall(e, sig, fc.translateExpr(f), fn)
 
 		case *ast.SelectorExpr:
 			sel, ok := fc.selectionOf(f)
@@ -675,7 +706,13 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 						return fc.formatExpr("%e.$low", e.Args[0])
 					}
 				}
-				return fc.translateCall(e, sig, fc.translateExpr(f))
+				if fn, ok := obj.(*types.Func); ok {
+					if inlined := fc.inlineCall(e, fn, nil); inlined != nil {
+						return inlined
+					}
+				}
+				fn, _ := obj.(*types.Func)
+				return fc.translateCall(e, sig, fc.translateExpr(f), fn)
 			}
 
 			externalizeExpr := func(e ast.Expr) string {
@@ -695,7 +732,16 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 
 			switch sel.Kind() {
 			case types.MethodVal:
-				recv := fc.makeReceiver(f)
+				if len(sel.Index()) == 1 {
+					if inlined := fc.inlineCall(e, sel.Obj().(*types.Func), f.X); inlined != nil {
+						return inlined
+					}
+				}
+				method := sel.Obj().(*types.Func)
+				// The receiver may share its storage with the variable it's read from if
+				// the method is guaranteed to not modify it, see IsReadOnlyParam.
+				share := len(sel.Index()) == 1 && fc.pkgCtx.IsReadOnlyParam(method, -1) && fc.isStableValue(f.X)
+				recv := fc.makeReceiver(f, share)
 				declaredFuncRecv := sel.Obj().(*types.Func).Type().(*types.Signature).Recv().Type()
 				if typesutil.IsJsObject(declaredFuncRecv) {
 					globalRef := func(id string) string {
@@ -767,8 +813,8 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 					}
 				}
 
-				methodName := fc.methodName(sel.Obj().(*types.Func))
-				return fc.translateCall(e, sig, fc.formatExpr("%s.%s", recv, methodName))
+				methodName := fc.methodName(method)
+				return fc.translateCall(e, sig, fc.formatExpr("%s.%s", recv, methodName), method)
 
 			case types.FieldVal:
 				fields, jsTag := fc.translateSelection(sel, f.Pos())
@@ -783,16 +829,16 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 						fc.pkgCtx.errList = append(fc.pkgCtx.errList, types.Error{Fset: fc.pkgCtx.fileSet, Pos: f.Pos(), Msg: "field with js tag can not have func type with multiple results"})
 					}
 				}
-				return fc.translateCall(e, sig, fc.formatExpr("%e.%s", f.X, strings.Join(fields, ".")))
+				return fc.translateCall(e, sig, fc.formatExpr("%e.%s", f.X, strings.Join(fields, ".")), nil)
 
 			case types.MethodExpr:
-				return fc.translateCall(e, sig, fc.translateExpr(f))
+				return fc.translateCall(e, sig, fc.translateExpr(f), nil)
 
 			default:
 				panic(fmt.Sprintf("unexpected sel.Kind(): %T", sel.Kind()))
 			}
 		default:
-			return fc.translateCall(e, sig, fc.translateExpr(plainFun))
+			return fc.translateCall(e, sig, fc.translateExpr(plainFun), nil)
 		}
 
 	case *ast.StarExpr:
@@ -809,7 +855,7 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 		switch exprType.Underlying().(type) {
 		case *types.Struct, *types.Array:
 			innerTyp := fc.typeOf(e.X)
-			if _, ok := innerTyp.Underlying().(*types.Pointer); ok {
+			if _, ok := innerTyp.Underlying().(*types.Pointer); ok && !fc.pkgCtx.opts.Unchecked {
 				return fc.formatExpr("(%1e === %2s.nil && $throwNilPointerError(), %1e)", e.X, fc.typeName(innerTyp))
 			}
 			return fc.translateExpr(e.X)
@@ -873,9 +919,20 @@ func (fc *funcContext) translateExpr(expr ast.Expr) *expression {
 	}
 }
 
-func (fc *funcContext) translateCall(e *ast.CallExpr, sig *types.Signature, fun *expression) *expression {
-	args := fc.translateArgs(sig, e.Args, e.Ellipsis.IsValid())
-	if fc.Blocking[e] {
+// translateCall translates a call of the function expression fun. If the
+// called function is known statically, callee must be set to it, otherwise it
+// must be nil.
+func (fc *funcContext) translateCall(e *ast.CallExpr, sig *types.Signature, fun *expression, callee *types.Func) *expression {
+	args := fc.translateArgs(sig, e.Args, e.Ellipsis.IsValid(), callee)
+	if fc.pkgCtx.opts.Generators {
+		// Delegations to the called generator are evaluated in place, so the
+		// evaluation order of the surrounding expression is preserved without
+		// hoisting the call. Calls that are blocking only because of their
+		// arguments call non-blocking functions, so they aren't delegated to.
+		if fc.BlockingOps[e] {
+			return fc.formatExpr("(yield* $await(%s(%s)))", fun, strings.Join(args, ", "))
+		}
+	} else if fc.Blocking[e] {
 		resumeCase := fc.caseCounter
 		fc.caseCounter++
 		returnVar := "$r"
@@ -903,6 +960,13 @@ func (fc *funcContext) translateCall(e *ast.CallExpr, sig *types.Signature, fun
 // this function handles and returns JS expressions that are safe to delegate
 // and behave like a regular JS function and a list of its argument values.
 func (fc *funcContext) delegatedCall(expr *ast.CallExpr) (callable *expression, arglist *expression) {
+	callable, args := fc.delegatedCallArgs(expr)
+	return callable, fc.formatExpr("[%s]", strings.Join(args, ", "))
+}
+
+// delegatedCallArgs is like delegatedCall, but returns the argument values as
+// separate JS expressions.
+func (fc *funcContext) delegatedCallArgs(expr *ast.CallExpr) (callable *expression, args []string) {
 	isBuiltin := false
 	isJs := false
 	switch fun := expr.Fun.(type) {
@@ -912,13 +976,11 @@ func (fc *funcContext) delegatedCall(expr *ast.CallExpr) (callable *expression,
 		isJs = typesutil.IsJsPackage(fc.pkgCtx.Uses[fun.Sel].Pkg())
 	}
 	sig := typesutil.Signature{Sig: fc.typeOf(expr.Fun).Underlying().(*types.Signature)}
-	args := fc.translateArgs(sig.Sig, expr.Args, expr.Ellipsis.IsValid())
+	args = fc.translateArgs(sig.Sig, expr.Args, expr.Ellipsis.IsValid(), nil)
 
 	if !isBuiltin && !isJs {
 		// Normal function calls don't require wrappers.
-		callable = fc.translateExpr(expr.Fun)
-		arglist = fc.formatExpr("[%s]", strings.Join(args, ", "))
-		return callable, arglist
+		return fc.translateExpr(expr.Fun), args
 	}
 
 	// Since some builtins or js.Object methods may not transpile into
@@ -942,11 +1004,13 @@ func (fc *funcContext) delegatedCall(expr *ast.CallExpr) (callable *expression,
 		Ellipsis: expr.Ellipsis,
 	}
 	callable = fc.formatExpr("function(%s) { %e; }", strings.Join(vars, ", "), wrapper)
-	arglist = fc.formatExpr("[%s]", strings.Join(args, ", "))
-	return callable, arglist
+	return callable, args
 }
 
-func (fc *funcContext) makeReceiver(e *ast.SelectorExpr) *expression {
+// makeReceiver translates the receiver expression of a method selector. If
+// share is true, struct and array receivers are passed to the method without
+// cloning them.
+func (fc *funcContext) makeReceiver(e *ast.SelectorExpr, share bool) *expression {
 	sel, _ := fc.selectionOf(e)
 	if !sel.Obj().Exported() {
 		fc.pkgCtx.DeclareDCEDep(sel.Obj(), nil, nil)
@@ -979,7 +1043,12 @@ func (fc *funcContext) makeReceiver(e *ast.SelectorExpr) *expression {
 		x = fc.setType(x, methodsRecvType)
 	}
 
-	recv := fc.translateImplicitConversionWithCloning(x, methodsRecvType)
+	var recv *expression
+	if share {
+		recv = fc.translateImplicitConversion(x, methodsRecvType)
+	} else {
+		recv = fc.translateImplicitConversionWithCloning(x, methodsRecvType)
+	}
 	if isWrapped(recvType) {
 		// Wrap JS-native value to have access to the Go type's methods.
 		recv = fc.formatExpr("new %s(%s)", fc.typeName(methodsRecvType), recv)
@@ -1010,9 +1079,9 @@ func (fc *funcContext) translateBuiltin(name string, sig *types.Signature, args
 			return fc.formatExpr("$makeSlice(%s, %f)", t, args[1])
 		case *types.Map:
 			if len(args) == 2 && fc.pkgCtx.Types[args[1]].Value == nil {
-				return fc.formatExpr(`((%1f < 0 || %1f > 2147483647) ? $throwRuntimeError("makemap: size out of range") : new $global.Map())`, args[1])
+				return fc.formatExpr(`((%1f < 0 || %1f > 2147483647) ? $throwRuntimeError("makemap: size out of range") : $newMap())`, args[1])
 			}
-			return fc.formatExpr("new $global.Map()")
+			return fc.formatExpr("$newMap()")
 		case *types.Chan:
 			length := "0"
 			if len(args) == 2 {
@@ -1056,7 +1125,7 @@ func (fc *funcContext) translateBuiltin(name string, sig *types.Signature, args
 		return fc.formatExpr("$panic(%s)", fc.translateImplicitConversion(args[0], types.NewInterfaceType(nil, nil)))
 	case "append":
 		if ellipsis || len(args) == 1 {
-			argStr := fc.translateArgs(sig, args, ellipsis)
+			argStr := fc.translateArgs(sig, args, ellipsis, nil)
 			return fc.formatExpr("$appendSlice(%s, %s)", argStr[0], argStr[1])
 		}
 		sliceType := sig.Results().At(0).Type().Underlying().(*types.Slice)
@@ -1083,13 +1152,16 @@ func (fc *funcContext) translateBuiltin(name string, sig *types.Signature, args
 		args = fc.expandTupleArgs(args)
 		return fc.formatExpr("console.log(%s)", strings.Join(fc.translateExprSlice(args, nil), ", "))
 	case "complex":
-		argStr := fc.translateArgs(sig, args, ellipsis)
+		argStr := fc.translateArgs(sig, args, ellipsis, nil)
 		return fc.formatExpr("new %s(%s, %s)", fc.typeName(sig.Results().At(0).Type()), argStr[0], argStr[1])
 	case "real":
 		return fc.formatExpr("%e.$real", args[0])
 	case "imag":
 		return fc.formatExpr("%e.$imag", args[0])
 	case "recover":
+		if fc.pkgCtx.opts.Generators {
+			return fc.formatExpr("$recover($canRecover)")
+		}
 		return fc.formatExpr("$recover()")
 	case "close":
 		return fc.formatExpr(`$close(%e)`, args[0])
@@ -1371,6 +1443,37 @@ func (fc *funcContext) translateImplicitConversionWithCloning(expr ast.Expr, des
 	return fc.translateImplicitConversion(expr, desiredType)
 }
 
+// translateCompositeElement translates an element, key or field value of a
+// composite literal. Struct and array values are cloned as they are copied into
+// the literal, unless the value is a composite literal of the same type itself,
+// which creates a fresh value nothing else refers to.
+func (fc *funcContext) translateCompositeElement(expr ast.Expr, desiredType types.Type) *expression {
+	if _, isLit := astutil.RemoveParens(expr).(*ast.CompositeLit); isLit && types.Identical(fc.typeOf(expr), desiredType) {
+		return fc.translateImplicitConversion(expr, desiredType)
+	}
+	return fc.translateImplicitConversionWithCloning(expr, desiredType)
+}
+
+// staticMapKey returns a JS expression for the key under which the runtime
+// stores the map entry for the given constant key (see keyFor functions in
+// $newType), if it can be computed at compile time. The translated key value is
+// given as jsKey.
+func (fc *funcContext) staticMapKey(key ast.Expr, jsKey string, keyType types.Type) (string, bool) {
+	value := fc.pkgCtx.Types[key].Value
+	basic, isBasic := keyType.Underlying().(*types.Basic)
+	if value == nil || !isBasic {
+		return "", false
+	}
+	switch {
+	case isString(basic):
+		return encodeString("$" + constant.StringVal(value)), true
+	case isBoolean(basic), isInteger(basic) && !is64Bit(basic):
+		return jsKey, true // Keyed by the value itself.
+	default:
+		return "", false
+	}
+}
+
 func (fc *funcContext) translateImplicitConversion(expr ast.Expr, desiredType types.Type) *expression {
 	if desiredType == nil {
 		return fc.translateExpr(expr)
@@ -1391,6 +1494,7 @@ func (fc *funcContext) translateImplicitConversion(expr ast.Expr, desiredType ty
 		return fc.formatExpr("$convertSliceType(%1e, %2s)", expr, fc.typeName(desiredType))
 
 	case *types.Interface:
+		fc.reflectType(exprType)
 		if typesutil.IsJsObject(exprType) {
 			// wrap JS object into js.Object struct when converting to interface
 			return fc.formatExpr("new $jsObjectPtr(%e)", expr)
@@ -1433,7 +1537,7 @@ func (fc *funcContext) loadStruct(array, target string, s *types.Struct) string
 	var collectFields func(s *types.Struct, path string)
 	collectFields = func(s *types.Struct, path string) {
 		for i := 0; i < s.NumFields(); i++ {
-			fieldName := path + "." + fieldName(s, i)
+			fieldName := path + "." + fc.fieldName(s, i)
 			fieldType := fc.fieldType(s, i)
 			if fs, isStruct := fieldType.Underlying().(*types.Struct); isStruct {
 				collectFields(fs, fieldName)
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"go/ast"
 	"go/types"
+	"maps"
 	"sort"
 	"strings"
 
@@ -32,7 +33,7 @@ func (fc *funcContext) nestedFunctionContext(info *analysis.FuncInfo, inst typep
 	sig := o.Type().(*types.Signature)
 
 	c := &funcContext{
-		FuncInfo:     info,
+		FuncInfo:     fc.pkgCtx.adaptFuncInfo(info),
 		instance:     inst,
 		pkgCtx:       fc.pkgCtx,
 		parent:       fc,
@@ -45,6 +46,7 @@ func (fc *funcContext) nestedFunctionContext(info *analysis.FuncInfo, inst typep
 		typeResolver: fc.typeResolver,
 		objectNames:  map[types.Object]string{},
 		sig:          &typesutil.Signature{Sig: sig},
+		dictParams:   fc.dictParams,
 	}
 	for k, v := range fc.allVars {
 		c.allVars[k] = v
@@ -60,7 +62,7 @@ func (fc *funcContext) nestedFunctionContext(info *analysis.FuncInfo, inst typep
 	// it.
 	funcRef := strings.ReplaceAll(o.Name(), ".", midDot)
 	c.funcRef = sourcemapx.Identifier{
-		Name: c.newVariable(funcRef, true /*pkgLevel*/),
+		Name: c.newPkgVariable(funcRef, "ref:"+inst.String()),
 		// o.FullName() decorates pointer receivers as `(*T).method`, we want simply `T.method`.
 		OriginalName: strings.NewReplacer("(", "", ")", "", "*", "").Replace(o.FullName()),
 		OriginalPos:  o.Pos(),
@@ -69,6 +71,19 @@ func (fc *funcContext) nestedFunctionContext(info *analysis.FuncInfo, inst typep
 	return c
 }
 
+// adaptFuncInfo returns the analysis info to translate a function with.
+//
+// With generator code generation blocking calls don't need resumption
+// points, so only the nodes required to emulate goto statements are flattened.
+func (pc *pkgContext) adaptFuncInfo(info *analysis.FuncInfo) *analysis.FuncInfo {
+	if !pc.opts.Generators {
+		return info
+	}
+	adapted := *info
+	adapted.Flattened = maps.Clone(info.GotoFlattened)
+	return &adapted
+}
+
 // namedFuncContext creates a new funcContext for a named Go function
 // (standalone or method).
 func (fc *funcContext) namedFuncContext(inst typeparams.Instance) *funcContext {
@@ -236,6 +251,9 @@ func (fc *funcContext) translateFunctionBody(typ *ast.FuncType, recv *ast.Ident,
 		}
 	}
 
+	fc.openCodedDefers = fc.canOpenCodeDefers(body)
+	fc.openDefers = nil
+
 	bodyOutput := string(fc.CatchOutput(2, func() {
 		if fc.IsBlocking() {
 			fc.pkgCtx.Scopes[body] = fc.pkgCtx.Scopes[typ]
@@ -271,6 +289,14 @@ func (fc *funcContext) translateFunctionBody(typ *ast.FuncType, recv *ast.Ident,
 	sort.Strings(fc.localVars)
 
 	var prefix, suffix string
+	generators := fc.pkgCtx.opts.Generators
+	resumable := fc.IsBlocking() && !generators
+
+	if generators && fc.containsRecover(body) {
+		// The check whether the function has been called directly by the deferred
+		// call runner must happen before the function gets suspended by a yield.
+		prefix = prefix + " var $canRecover = $isDeferredCall();"
+	}
 
 	if len(fc.Flattened) != 0 {
 		// $s contains an index of the switch case a blocking function reached
@@ -281,16 +307,18 @@ func (fc *funcContext) translateFunctionBody(typ *ast.FuncType, recv *ast.Ident,
 	}
 
 	if fc.HasDefer {
-		fc.localVars = append(fc.localVars, "$deferred")
+		if !fc.openCodedDefers {
+			fc.localVars = append(fc.localVars, "$deferred")
+		}
 		suffix = " }" + suffix
-		if fc.IsBlocking() {
+		if resumable {
 			suffix = " }" + suffix
 		}
 	}
 
 	localVarDefs := "" // Function-local var declaration at the top.
 
-	if fc.IsBlocking() {
+	if resumable {
 		localVars := append([]string{}, fc.localVars...)
 		// There are several special variables involved in handling blocking functions:
 		// $r is sometimes used as a temporary variable to store blocking call result.
@@ -308,23 +336,46 @@ func (fc *funcContext) translateFunctionBody(typ *ast.FuncType, recv *ast.Ident,
 		suffix = " " + saveContext + "return $f;" + suffix
 	} else if len(fc.localVars) > 0 {
 		// Non-blocking functions simply declare local variables with no need for restore support.
+		// Neither do generator functions, which keep their state while suspended.
 		localVarDefs = fmt.Sprintf("var %s;\n", strings.Join(fc.localVars, ", "))
 	}
 
-	if fc.HasDefer {
+	if fc.openCodedDefers {
+		// The marker on the defer stack stops a panic from running deferred calls
+		// of the callers before the deferred calls of this function, see
+		// $callOpenDeferred.
+		prefix = prefix + " var $err = null, $thrown = false, $deferBits = 0; $curGoroutine.deferStack.push($openDeferMarker); try {"
+		suffix = fc.openDeferSuffix() + suffix
+	} else if fc.HasDefer {
 		prefix = prefix + " var $err = null; try {"
 		deferSuffix := " } catch(err) { $err = err;"
-		if fc.IsBlocking() {
+		if resumable {
 			deferSuffix += " $s = -1;"
 		}
 		if fc.resultNames == nil && fc.sig.HasResults() {
 			deferSuffix += fmt.Sprintf(" return%s;", fc.translateResults(nil))
 		}
-		deferSuffix += " } finally { $callDeferred($deferred, $err);"
+		// The deferred calls are run at the end of the function, which is where
+		// the function's stack frame points to while they run.
+		epilogue := string(fc.CatchOutput(0, func() {
+			fc.SetPos(body.Rbrace)
+			fc.writePos()
+		}))
+		switch {
+		case generators && fc.IsBlocking():
+			// Deferred calls of a blocking function may block, so they are delegated to.
+			deferSuffix += " } finally { " + epilogue + "yield* $deferredCalls($deferred, $err);"
+		default:
+			deferSuffix += " } finally { " + epilogue + "$callDeferred($deferred, $err);"
+		}
 		if fc.resultNames != nil {
-			deferSuffix += fmt.Sprintf(" if (!$curGoroutine.asleep) { return %s; }", fc.translateResults(fc.resultNames))
+			if generators {
+				deferSuffix += fmt.Sprintf(" return%s;", fc.translateResults(fc.resultNames))
+			} else {
+				deferSuffix += fmt.Sprintf(" if (!$curGoroutine.asleep) { return %s; }", fc.translateResults(fc.resultNames))
+			}
 		}
-		if fc.IsBlocking() {
+		if resumable {
 			deferSuffix += " if($curGoroutine.asleep) {"
 		}
 		suffix = deferSuffix + suffix
@@ -335,8 +386,14 @@ func (fc *funcContext) translateFunctionBody(typ *ast.FuncType, recv *ast.Ident,
 		suffix = " } return; }" + suffix
 	}
 
-	if fc.HasDefer {
-		prefix = prefix + " $deferred = []; $curGoroutine.deferStack.push($deferred);"
+	if fc.HasDefer && !fc.openCodedDefers {
+		if generators {
+			// Deferred calls are run by each function as it returns or unwinds, so
+			// they don't need to be tracked by the goroutine.
+			prefix = prefix + " $deferred = [];"
+		} else {
+			prefix = prefix + " $deferred = []; $curGoroutine.deferStack.push($deferred);"
+		}
 	}
 
 	if prefix != "" {
@@ -351,5 +408,148 @@ func (fc *funcContext) translateFunctionBody(typ *ast.FuncType, recv *ast.Ident,
 
 	fc.pkgCtx.escapingVars = prevEV
 
-	return fmt.Sprintf("%sfunction %s(%s) {\n%s%s}", fc.funcRef.EncodeHint(), fc.funcRef, strings.Join(args, ", "), bodyOutput, fc.Indentation(1))
+	keyword := "function"
+	if generators && fc.IsBlocking() {
+		keyword = "function*"
+	}
+
+	// The code of a function literal is embedded in the code of its parent, so
+	// the parent's name is mapped again where the literal ends, at the position
+	// of the parent's statement containing the literal. This way the function
+	// enclosing any position of the generated code is named by the nearest
+	// preceding named source map segment, which the runtime relies on to resolve
+	// the function names of stack frames (see $resolveCallFrame).
+	resumeParent := ""
+	if parent := fc.parent; parent != nil && parent.funcRef.Name != "" {
+		resumeParent = sourcemapx.Identifier{
+			Name:         parent.funcRef.Name,
+			OriginalName: parent.funcRef.OriginalName,
+			OriginalPos:  parent.pos,
+		}.EncodeHint()
+	}
+
+	return fmt.Sprintf("%s%s %s(%s) {\n%s%s}%s", fc.funcRef.EncodeHint(), keyword, fc.funcRef, strings.Join(args, ", "), bodyOutput, fc.Indentation(1), resumeParent)
+}
+
+// maxOpenDefers is the maximum number of defer statements in a function with
+// open-coded deferred calls.
+const maxOpenDefers = 8
+
+// openDefer describes an open-coded defer statement.
+type openDefer struct {
+	// Local variable holding the deferred function.
+	callable string
+	// Local variables holding the arguments of the deferred call.
+	args []string
+}
+
+// canOpenCodeDefers returns true if deferred calls of the function can be
+// open-coded, similar to Go's open-coded defers. Instead of pushing the calls
+// onto the goroutine's defer stack, the deferred function and its arguments are
+// kept in local variables and called directly when the function returns.
+//
+// This requires the function to be non-blocking, not flattened (e.g. due to a
+// goto statement) and to have a small number of defer statements, none of which
+// is in a loop, so that each of them runs at most once per call. The generator
+// mode has its own deferred call runner, which open-coded defers don't support.
+func (fc *funcContext) canOpenCodeDefers(body *ast.BlockStmt) bool {
+	if !fc.HasDefer || fc.IsBlocking() || len(fc.Flattened) != 0 || fc.pkgCtx.opts.Generators {
+		return false
+	}
+	defers := 0
+	inLoop := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.DeferStmt:
+			defers++
+		case *ast.ForStmt:
+			inLoop = inLoop || containsDefer(n.Body)
+		case *ast.RangeStmt:
+			inLoop = inLoop || containsDefer(n.Body)
+		}
+		return true
+	})
+	return !inLoop && defers <= maxOpenDefers
+}
+
+// containsDefer returns true if the statement contains a defer statement,
+// not counting nested function literals.
+func containsDefer(stmt ast.Stmt) bool {
+	found := false
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.DeferStmt:
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// translateOpenDefer translates an open-coded defer statement. The deferred
+// function and its arguments are evaluated into local variables, and the bit
+// corresponding to the statement is set in $deferBits to mark it as pending.
+func (fc *funcContext) translateOpenDefer(call *ast.CallExpr) {
+	callable, args := fc.delegatedCallArgs(call)
+	d := openDefer{callable: fc.newLocalVariable("_defer")}
+	assignments := []string{fmt.Sprintf("%s = %s;", d.callable, callable)}
+	for _, arg := range args {
+		v := fc.newLocalVariable("_deferArg")
+		d.args = append(d.args, v)
+		assignments = append(assignments, fmt.Sprintf("%s = %s;", v, arg))
+	}
+	fc.Printf("%s $deferBits |= %d;", strings.Join(assignments, " "), 1<<len(fc.openDefers))
+	fc.openDefers = append(fc.openDefers, d)
+}
+
+// openDeferSuffix returns the code that ends the body of a function with
+// open-coded deferred calls. When the function returns normally, the pending
+// deferred calls are made directly in the reverse order. If the function body
+// or one of the deferred calls throws, the calls that haven't been made yet are
+// handed over to the regular deferred call runner, which handles panics and
+// recover() like for any other function.
+func (fc *funcContext) openDeferSuffix() string {
+	calls := make([]string, len(fc.openDefers))
+	entries := make([]string, len(fc.openDefers))
+	for i, d := range fc.openDefers {
+		args := strings.Join(d.args, ", ")
+		calls[len(calls)-1-i] = fmt.Sprintf("if (($deferBits & %[1]d) !== 0) { $deferBits &= ~%[1]d; %[2]s(%[3]s); }", 1<<i, d.callable, args)
+		entries[i] = fmt.Sprintf("[%s, [%s]]", d.callable, args)
+	}
+
+	suffix := " } catch(err) { $err = err; $thrown = true;"
+	if fc.resultNames == nil && fc.sig.HasResults() {
+		suffix += fmt.Sprintf(" return%s;", fc.translateResults(nil))
+	}
+	suffix += " } finally {"
+	suffix += fmt.Sprintf(" if (!$thrown) { try { %s } catch(err) { $err = err; $thrown = true; } }", strings.Join(calls, " "))
+	suffix += fmt.Sprintf(" if ($thrown) { $callOpenDeferred($deferBits, [%s], $err); } else { $curGoroutine.deferStack.pop(); }", strings.Join(entries, ", "))
+	if fc.resultNames != nil {
+		suffix += fmt.Sprintf(" if (!$curGoroutine.asleep) { return %s; }", fc.translateResults(fc.resultNames))
+	}
+	return suffix
+}
+
+// containsRecover returns true if the function body calls the recover()
+// built-in directly, rather than from a nested function literal.
+func (fc *funcContext) containsRecover(body *ast.BlockStmt) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.CallExpr:
+			if id, ok := astutil.RemoveParens(n.Fun).(*ast.Ident); ok {
+				if b, ok := fc.pkgCtx.Uses[id].(*types.Builtin); ok && b.Name() == "recover" {
+					found = true
+				}
+			}
+		}
+		return !found
+	})
+	return found
 }
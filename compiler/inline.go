@@ -0,0 +1,116 @@
+package compiler
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"github.com/gopherjs/gopherjs/compiler/astutil"
+	"github.com/gopherjs/gopherjs/compiler/internal/analysis"
+)
+
+// inlineCall attempts to replace a call to the given function with the
+// function's body. It returns nil if the call can't be inlined, in which case
+// the call must be translated normally.
+//
+// For method calls, recv is the receiver expression, otherwise it must be nil.
+// See analysis.Inlinable for the kinds of functions that may be inlined.
+//
+// Arguments are evaluated in order into temporary variables before the
+// function body, preserving Go evaluation semantics. Plain local variables are
+// substituted into the body directly when none of the arguments have side
+// effects, which covers the most common case of accessor calls. Since the
+// inlined body is translated in place of the call, all of its source map
+// positions refer to the call site.
+func (fc *funcContext) inlineCall(e *ast.CallExpr, fn *types.Func, recv ast.Expr) *expression {
+	if fc.pkgCtx.opts.NoInline || e.Ellipsis.IsValid() || fc.Blocking[e] {
+		return nil
+	}
+	in := fc.pkgCtx.Inlinable(fn)
+	if in == nil {
+		return nil
+	}
+
+	args := e.Args
+	if recv != nil {
+		// Calls that implicitly take the address of the receiver or dereference
+		// it are translated normally.
+		if !types.Identical(fc.typeOf(recv), in.Params[0].Type()) {
+			return nil
+		}
+		args = append([]ast.Expr{recv}, args...)
+	}
+	if len(args) != len(in.Params) {
+		return nil // For example, f(g()) where g() returns multiple values.
+	}
+
+	sideEffects := false
+	for _, arg := range args {
+		if analysis.HasSideEffect(arg, fc.pkgCtx.Info.Info) {
+			sideEffects = true
+			break
+		}
+	}
+
+	bindings := []string{}
+	for i, param := range in.Params {
+		arg := args[i]
+		if v, ok := fc.localVarOf(arg); ok && !sideEffects && types.Identical(v.Type(), param.Type()) {
+			fc.objectNames[param] = fc.objectName(v)
+			continue
+		}
+		tmp := fc.newLocalVariable("_" + param.Name())
+		bindings = append(bindings, tmp+" = "+fc.translateImplicitConversion(arg, param.Type()).String())
+		fc.objectNames[param] = tmp
+	}
+	defer func() {
+		// Parameter names are only valid within this particular call site.
+		for _, param := range in.Params {
+			delete(fc.objectNames, param)
+		}
+	}()
+
+	resultType := in.Func.Type().(*types.Signature).Results().At(0).Type()
+	result := fc.translateInlineBody(in, resultType)
+	if len(bindings) == 0 {
+		if _, isOp := astutil.RemoveParens(in.Result).(*ast.BinaryExpr); isOp {
+			// Not all operators are parenthesized by translateExpr, since their
+			// precedence is the same in Go and JS. Within the caller expression this
+			// may no longer be true.
+			return fc.formatParenExpr("%s", result.String())
+		}
+		return result
+	}
+	return fc.formatExpr("(%s, %s)", strings.Join(bindings, ", "), result)
+}
+
+// localVarOf returns the function-level variable the expression refers to, if
+// the expression is a plain identifier.
+func (fc *funcContext) localVarOf(e ast.Expr) (*types.Var, bool) {
+	id, ok := astutil.RemoveParens(e).(*ast.Ident)
+	if !ok {
+		return nil, false
+	}
+	v, ok := fc.pkgCtx.Uses[id].(*types.Var)
+	if !ok || isPkgLevel(v) {
+		return nil, false
+	}
+	return v, true
+}
+
+// translateInlineBody translates the body of the inlined function. The body
+// belongs to the package that declares the function, so it's translated with
+// the type information of that package instead of the current one. Only the
+// parameters of the function refer to values of the current package, and they
+// have been bound to its variables already.
+func (fc *funcContext) translateInlineBody(in *analysis.Inlinable, resultType types.Type) *expression {
+	if in.Info == fc.pkgCtx.Info.Info {
+		return fc.translateImplicitConversion(in.Result, resultType)
+	}
+	callerInfo := fc.pkgCtx.Info
+	calleeInfo := *callerInfo
+	calleeInfo.Info = in.Info
+	fc.pkgCtx.Info = &calleeInfo
+	defer func() { fc.pkgCtx.Info = callerInfo }()
+	return fc.translateImplicitConversion(in.Result, resultType)
+}
@@ -30,7 +30,12 @@ func (v *escapeAnalysis) Visit(node ast.Node) (w ast.Visitor) {
 	switch n := node.(type) {
 	case *ast.UnaryExpr:
 		if n.Op == token.AND {
-			if _, ok := n.X.(*ast.Ident); ok {
+			if id, ok := n.X.(*ast.Ident); ok {
+				if isStructOrArray(v.info.TypeOf(id)) {
+					// Structs and arrays are represented by JS objects, which can be used
+					// as pointers directly, so the variable doesn't need to be boxed.
+					return nil
+				}
 				return &escapingObjectCollector{v}
 			}
 		}
@@ -68,3 +73,13 @@ func (v *escapingObjectCollector) Visit(node ast.Node) (w ast.Visitor) {
 	}
 	return v
 }
+
+// isStructOrArray returns true if the type's underlying type is a struct or an
+// array. Unlike isAggregate, type parameters are not included.
+func isStructOrArray(t types.Type) bool {
+	switch t.Underlying().(type) {
+	case *types.Struct, *types.Array:
+		return true
+	}
+	return false
+}
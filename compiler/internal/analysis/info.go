@@ -60,6 +60,12 @@ type Info struct {
 
 	infoImporter InfoImporter // To get `Info` for other packages.
 	allInfos     []*FuncInfo
+
+	funcDecls  map[*types.Func]*ast.FuncDecl // Declarations of package-level functions and methods.
+	inlinables map[*types.Func]*Inlinable    // Cache of inlining candidates, see Inlinable().
+	varUsage   map[*types.Var]varUsage       // Usage of function-level variables, see analyzeVarUsage().
+
+	shapeAgnostic map[*types.Func]bool // Cache of generic functions that may be shared, see IsShapeAgnostic().
 }
 
 // InfoImporter is used to get the `Info` for another package.
@@ -70,7 +76,10 @@ func (info *Info) newFuncInfo(n ast.Node, obj types.Object, typeArgs typesutil.T
 	funcInfo := &FuncInfo{
 		pkgInfo:            info,
 		Flattened:          make(map[ast.Node]bool),
+		GotoFlattened:      make(map[ast.Node]bool),
 		Blocking:           make(map[ast.Node]bool),
+		BlockingOps:        make(map[ast.Node]bool),
+		Preempted:          make(map[ast.Node]bool),
 		GotoLabel:          make(map[*types.Label]bool),
 		loopReturnIndex:    -1,
 		instCallees:        new(typeparams.InstanceMap[[]astPath]),
@@ -194,6 +203,10 @@ func AnalyzePkg(files []*ast.File, fileSet *token.FileSet, typesInfo *types.Info
 		infoImporter:  infoImporter,
 		funcInstInfos: new(typeparams.InstanceMap[*FuncInfo]),
 		funcLitInfos:  make(map[*ast.FuncLit][]*FuncInfo),
+		funcDecls:     make(map[*types.Func]*ast.FuncDecl),
+		inlinables:    make(map[*types.Func]*Inlinable),
+		varUsage:      make(map[*types.Var]varUsage),
+		shapeAgnostic: make(map[*types.Func]bool),
 	}
 	info.InitFuncInfo = info.newFuncInfo(nil, nil, nil, nil)
 
@@ -202,6 +215,7 @@ func AnalyzePkg(files []*ast.File, fileSet *token.FileSet, typesInfo *types.Info
 	for _, file := range files {
 		ast.Walk(info.InitFuncInfo, file)
 	}
+	info.analyzeVarUsage(files)
 
 	return info
 }
@@ -257,6 +271,19 @@ func (info *Info) propagateFunctionBlocking() bool {
 	return done
 }
 
+// EnablePreemption marks loops that may run for a long time, that is all
+// loops except range loops over arrays and channels, as preempted. Their
+// iterations may yield to other goroutines, so the loops and the functions
+// containing them become blocking. It must be called before PropagateAnalysis.
+func (info *Info) EnablePreemption() {
+	for _, fi := range info.allInfos {
+		for _, loop := range fi.longLoops {
+			fi.markBlocking(loop)
+			fi.Preempted[loop[len(loop)-1]] = true
+		}
+	}
+}
+
 // propagateControlStatementBlocking is called after all function blocking
 // information was propagated, mark flow control statements as blocking
 // whenever they may lead to a blocking function call.
@@ -274,12 +301,24 @@ type FuncInfo struct {
 	// to jump into an arbitrary position in the code with a GOTO statement, or
 	// resume a goroutine after a blocking call unblocks.
 	Flattened map[ast.Node]bool
+	// GotoFlattened is the subset of Flattened nodes that must be flattened to
+	// emulate GOTO statements, regardless of whether they are blocking.
+	GotoFlattened map[ast.Node]bool
 	// Blocking indicates that either the AST node itself or its descendant may
 	// block goroutine execution (for example, a channel operation).
 	Blocking map[ast.Node]bool
+	// BlockingOps is the subset of Blocking nodes that may block by themselves,
+	// rather than because of a blocking descendant (for example, a call of a
+	// blocking function, but not a call with a blocking argument).
+	BlockingOps map[ast.Node]bool
+	// Preempted indicates loops that must periodically yield to other goroutines
+	// and the event loop, see Info.EnablePreemption.
+	Preempted map[ast.Node]bool
 	// GotoLabel indicates a label referenced by a goto statement, rather than a
 	// named loop.
 	GotoLabel map[*types.Label]bool
+	// Paths to the loops in the function that may run for a long time.
+	longLoops []astPath
 	// List of continue statements in the function.
 	continueStmts []continueStmt
 	// List of return statements in the function.
@@ -380,13 +419,26 @@ func (fi *FuncInfo) Visit(node ast.Node) ast.Visitor {
 		}
 		return nil
 	}
+	depth := len(fi.visitorStack)
 	fi.visitorStack = append(fi.visitorStack, node)
+	w := fi.visit(node)
+	if w != fi {
+		// ast.Walk only notifies the visitor returned for the node about the end
+		// of its subtree, so the node must be popped here.
+		fi.visitorStack = fi.visitorStack[:depth]
+	}
+	return w
+}
 
+func (fi *FuncInfo) visit(node ast.Node) ast.Visitor {
 	switch n := node.(type) {
 	case *ast.FuncDecl:
 		// Analyze all the instances of the function declarations
 		// in their own context with their own type arguments.
 		fis := fi.pkgInfo.newFuncInfoInstances(n)
+		if obj, ok := fi.pkgInfo.Defs[n.Name].(*types.Func); ok {
+			fi.pkgInfo.funcDecls[obj] = n
+		}
 		if n.Body != nil {
 			for _, fi := range fis {
 				ast.Walk(fi, n.Body)
@@ -431,9 +483,14 @@ func (fi *FuncInfo) Visit(node ast.Node) ast.Visitor {
 		}
 		return fi
 	case *ast.RangeStmt:
-		if _, ok := fi.pkgInfo.TypeOf(n.X).Underlying().(*types.Chan); ok {
+		switch fi.pkgInfo.TypeOf(n.X).Underlying().(type) {
+		case *types.Chan:
 			// for-range loop over a channel is blocking.
 			fi.markBlocking(fi.visitorStack)
+		case *types.Array, *types.Pointer:
+			// The number of iterations is bounded by the (pointed to) array length.
+		default:
+			fi.longLoops = append(fi.longLoops, fi.visitorStack.copy())
 		}
 		if fi.loopReturnIndex >= 0 {
 			// Already in a loop so just continue walking.
@@ -453,6 +510,7 @@ func (fi *FuncInfo) Visit(node ast.Node) ast.Visitor {
 		fi.loopReturnIndex = -1
 		return nil
 	case *ast.ForStmt:
+		fi.longLoops = append(fi.longLoops, fi.visitorStack.copy())
 		if fi.loopReturnIndex >= 0 {
 			// Already in a loop so just continue walking.
 			return fi
@@ -718,11 +776,15 @@ func (fi *FuncInfo) markBlocking(stack astPath) {
 		fi.Blocking[n] = true
 		fi.Flattened[n] = true
 	}
+	if len(stack) != 0 {
+		fi.BlockingOps[stack[len(stack)-1]] = true
+	}
 }
 
 func (fi *FuncInfo) markFlattened(stack astPath) {
 	for _, n := range stack {
 		fi.Flattened[n] = true
+		fi.GotoFlattened[n] = true
 	}
 }
 
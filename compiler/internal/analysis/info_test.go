@@ -1621,6 +1621,62 @@ func TestBlocking_IsImportBlocking_BackwardInstances(t *testing.T) {
 	bt.assertNotBlocking(`notBlocking`)
 }
 
+func TestBlocking_Preemption(t *testing.T) {
+	src := `package test
+
+		func forLoop() {
+			for i := 0; i < 10; i++ {
+				println(i)
+			}
+		}
+
+		func rangeSlice(s []int) {
+			for range s {}
+		}
+
+		func rangeString(s string) {
+			for range s {}
+		}
+
+		func rangeArray(a [4]int) {
+			for range a {}
+		}
+
+		func rangeArrayPtr(a *[4]int) {
+			for range a {}
+		}
+
+		func noLoop() {
+			println("hi")
+		}
+
+		func caller() {
+			rangeSlice(nil)
+		}
+
+		func closure() {
+			_ = func() {
+				for {}
+			}
+		}`
+
+	bt := newBlockingTestWithPreemption(t, src, true)
+	bt.assertBlocking(`forLoop`)
+	bt.assertBlocking(`rangeSlice`)
+	bt.assertBlocking(`rangeString`)
+	bt.assertBlocking(`caller`)
+	bt.assertBlockingLit(34, ``)
+	bt.assertNotBlocking(`rangeArray`)
+	bt.assertNotBlocking(`rangeArrayPtr`)
+	bt.assertNotBlocking(`noLoop`)
+	bt.assertNotBlocking(`closure`)
+
+	bt = newBlockingTest(t, src)
+	bt.assertNotBlocking(`forLoop`)
+	bt.assertNotBlocking(`caller`)
+	bt.assertNotBlockingLit(34, ``)
+}
+
 type blockingTest struct {
 	f       *srctesting.Fixture
 	file    *ast.File
@@ -1628,6 +1684,10 @@ type blockingTest struct {
 }
 
 func newBlockingTest(t *testing.T, src string) *blockingTest {
+	return newBlockingTestWithPreemption(t, src, false)
+}
+
+func newBlockingTestWithPreemption(t *testing.T, src string, preempt bool) *blockingTest {
 	f := srctesting.New(t)
 	tContext := types.NewContext()
 	tc := typeparams.Collector{
@@ -1644,6 +1704,9 @@ func newBlockingTest(t *testing.T, src string) *blockingTest {
 		return nil, fmt.Errorf(`getImportInfo should not be called in this test, called with %v`, path)
 	}
 	pkgInfo := AnalyzePkg([]*ast.File{file}, f.FileSet, testInfo, tContext, testPkg, tc.Instances, getImportInfo)
+	if preempt {
+		pkgInfo.EnablePreemption()
+	}
 	PropagateAnalysis([]*Info{pkgInfo})
 
 	return &blockingTest{
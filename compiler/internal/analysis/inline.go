@@ -0,0 +1,209 @@
+package analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/gopherjs/gopherjs/compiler/internal/typeparams"
+	"github.com/gopherjs/gopherjs/compiler/typesutil"
+)
+
+// Inlinable describes a function that may be inlined at its call sites.
+//
+// Only a very narrow class of functions is considered for inlining: the
+// function body must consist of a single return statement with a single,
+// side-effect-free expression that doesn't call any other functions (except
+// for `len` and `cap` built-ins) and can't panic. This guarantees that the
+// function is not recursive, never blocks, never defers and its stack frame
+// can never be observed, neither via `runtime.Caller()` nor in the traceback
+// of a panic, so replacing a call with the function's body is not observable
+// by the program.
+type Inlinable struct {
+	// Func is the function that can be inlined.
+	Func *types.Func
+	// Params is the list of the function parameters in the order arguments are
+	// passed. For methods the receiver is the first parameter.
+	Params []*types.Var
+	// Result is the expression returned by the function.
+	Result ast.Expr
+	// Info is the type information for the package that declares the function.
+	// It may be different from the package where the function is inlined into.
+	Info *types.Info
+}
+
+// Inlinable returns information for inlining the given function, or nil if
+// the function is not a suitable candidate for inlining.
+//
+// If the function is from a different package, this will use the info
+// importer to lookup the information from the other package.
+func (info *Info) Inlinable(fn *types.Func) *Inlinable {
+	if fn.Pkg() == nil {
+		return nil // Universe scope.
+	}
+	if fn.Pkg() != info.Pkg {
+		otherInfo, err := info.infoImporter(fn.Pkg().Path())
+		if err != nil {
+			panic(fmt.Errorf(`failed to get info for package %q: %v`, fn.Pkg().Path(), err))
+		}
+		return otherInfo.Inlinable(fn)
+	}
+
+	if in, ok := info.inlinables[fn]; ok {
+		return in
+	}
+	in := info.newInlinable(fn)
+	info.inlinables[fn] = in
+	return in
+}
+
+func (info *Info) newInlinable(fn *types.Func) *Inlinable {
+	decl := info.funcDecls[fn]
+	if decl == nil || decl.Body == nil || len(decl.Body.List) != 1 {
+		return nil
+	}
+	if typesutil.IsJsPackage(fn.Pkg()) || typeparams.HasTypeParams(fn.Type()) {
+		return nil
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.Variadic() || sig.Results().Len() != 1 {
+		return nil
+	}
+	if recv := sig.Recv(); recv != nil {
+		if _, isIface := recv.Type().Underlying().(*types.Interface); isIface {
+			return nil
+		}
+		if typesutil.IsJsObject(recv.Type()) {
+			return nil
+		}
+	}
+	if info.IsBlocking(typeparams.Instance{Object: fn}) {
+		return nil
+	}
+	ret, ok := decl.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return nil
+	}
+
+	params := []*types.Var{}
+	if recv := sig.Recv(); recv != nil {
+		params = append(params, recv)
+	}
+	for i := 0; i < sig.Params().Len(); i++ {
+		params = append(params, sig.Params().At(i))
+	}
+
+	v := inlineChecker{info: info.Info, params: map[*types.Var]bool{}, ok: true}
+	for _, p := range params {
+		if p.Name() == "" || p.Name() == "_" {
+			// Unnamed parameters can't be referenced by the body, but their
+			// arguments still have to be evaluated, which is not worth the trouble.
+			return nil
+		}
+		v.params[p] = true
+	}
+	ast.Walk(&v, ret.Results[0])
+	if !v.ok {
+		return nil
+	}
+
+	return &Inlinable{
+		Func:   fn,
+		Params: params,
+		Result: ret.Results[0],
+		Info:   info.Info,
+	}
+}
+
+// inlineChecker verifies that an expression is simple enough to be inlined.
+type inlineChecker struct {
+	info   *types.Info
+	params map[*types.Var]bool
+	ok     bool
+}
+
+func (v *inlineChecker) Visit(node ast.Node) ast.Visitor {
+	if !v.ok || node == nil {
+		return nil
+	}
+	if e, isExpr := node.(ast.Expr); isExpr {
+		tv := v.info.Types[e]
+		if tv.Value != nil {
+			return nil // Constant expressions are evaluated at compile time.
+		}
+		if tv.Type != nil && typesutil.IsJsObject(tv.Type) {
+			v.ok = false // js.Object values have special translation rules.
+			return nil
+		}
+	}
+
+	switch n := node.(type) {
+	case *ast.Ident:
+		switch obj := v.info.Uses[n].(type) {
+		case *types.Var:
+			v.ok = v.params[obj]
+		case *types.Nil:
+		default:
+			v.ok = false
+		}
+	case *ast.ParenExpr:
+	case *ast.BinaryExpr:
+		v.ok = !mayPanic(n, v.info)
+	case *ast.UnaryExpr:
+		v.ok = n.Op != token.AND && n.Op != token.ARROW
+	case *ast.SelectorExpr:
+		sel := v.info.Selections[n]
+		if sel == nil || sel.Kind() != types.FieldVal || sel.Indirect() {
+			v.ok = false // Indirect field access panics on nil pointers.
+			return nil
+		}
+		if wrapsJsObject(sel.Recv()) {
+			v.ok = false // Fields of js.Object wrappers may have js tags.
+			return nil
+		}
+		ast.Walk(v, n.X)
+		return nil // Don't visit the field identifier.
+	case *ast.CallExpr:
+		id, isIdent := n.Fun.(*ast.Ident)
+		if !isIdent {
+			v.ok = false
+			return nil
+		}
+		b, isBuiltin := v.info.Uses[id].(*types.Builtin)
+		v.ok = isBuiltin && (b.Name() == "len" || b.Name() == "cap")
+		if v.ok {
+			for _, arg := range n.Args {
+				ast.Walk(v, arg)
+			}
+		}
+		return nil
+	default:
+		v.ok = false
+	}
+	return v
+}
+
+// mayPanic returns true if the binary operation panics for some operands:
+// integer division by zero and shifts by a negative count.
+func mayPanic(e *ast.BinaryExpr, info *types.Info) bool {
+	switch e.Op {
+	case token.QUO, token.REM:
+		t, ok := info.TypeOf(e).Underlying().(*types.Basic)
+		return ok && t.Info()&types.IsInteger != 0 && info.Types[e.Y].Value == nil
+	case token.SHL, token.SHR:
+		t, ok := info.TypeOf(e.Y).Underlying().(*types.Basic)
+		return ok && t.Info()&types.IsUnsigned == 0 && info.Types[e.Y].Value == nil
+	}
+	return false
+}
+
+// wrapsJsObject returns true if t is a struct, or a pointer to a struct, that
+// embeds *js.Object as its first field.
+func wrapsJsObject(t types.Type) bool {
+	if p, isPtr := t.Underlying().(*types.Pointer); isPtr {
+		t = p.Elem()
+	}
+	s, isStruct := t.Underlying().(*types.Struct)
+	return isStruct && s.NumFields() > 0 && typesutil.IsJsObject(s.Field(0).Type())
+}
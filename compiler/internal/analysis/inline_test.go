@@ -0,0 +1,83 @@
+package analysis
+
+import (
+	"go/types"
+	"testing"
+)
+
+func TestInlinable(t *testing.T) {
+	bt := newBlockingTest(t,
+		`package test
+
+		type Point struct{ X, Y int }
+
+		func (p Point) Sum() int { return p.X + p.Y }
+		func (p *Point) GetX() int { return p.X }
+		func first(s []int) int { return s[0] }
+		func size(s string) int { return len(s) + 1 }
+		func isNil(p *Point) bool { return p == nil }
+		func ratio(a, b float64) float64 { return a / b }
+		func half(n int) int { return n / 2 }
+		func quotient(a, b int) int { return a / b }
+		func shift(n int, s uint) int { return n << s }
+		func signedShift(n, s int) int { return n << s }
+		func constant(int) int { return 42 }
+
+		func recursive(n int) int { return recursive(n - 1) }
+		func calls(n int) int { return first([]int{n}) }
+		func receive(c chan int) int { return <-c }
+		func address(p Point) *int { return &p.X }
+		func literal(n int) Point { return Point{X: n} }
+		func global() int { return counter }
+		func generic[T any](v T) T { return v }
+		func noResult(n int) { println(n) }
+		func statements(n int) int {
+			n++
+			return n
+		}
+
+		var counter int`)
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: `Sum`, want: true},
+		{name: `GetX`, want: false},  // Panics on nil receivers.
+		{name: `first`, want: false}, // Panics if out of range.
+		{name: `size`, want: true},
+		{name: `isNil`, want: true},
+		{name: `ratio`, want: true},
+		{name: `half`, want: true},
+		{name: `quotient`, want: false}, // Panics on division by zero.
+		{name: `shift`, want: true},
+		{name: `signedShift`, want: false}, // Panics on negative shift counts.
+		{name: `constant`, want: false},    // Unnamed parameter.
+		{name: `recursive`, want: false},
+		{name: `calls`, want: false},
+		{name: `receive`, want: false},
+		{name: `address`, want: false},
+		{name: `literal`, want: false},
+		{name: `global`, want: false},
+		{name: `generic`, want: false},
+		{name: `noResult`, want: false},
+		{name: `statements`, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var fn *types.Func
+			for _, obj := range bt.pkgInfo.Defs {
+				if f, ok := obj.(*types.Func); ok && f.Name() == test.name {
+					fn = f
+				}
+			}
+			if fn == nil {
+				t.Fatalf(`Function %q not found.`, test.name)
+			}
+			if got := bt.pkgInfo.Inlinable(fn) != nil; got != test.want {
+				t.Errorf(`Got Inlinable(%q) = %v, want %v.`, test.name, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/gopherjs/gopherjs/compiler/astutil"
+	"github.com/gopherjs/gopherjs/compiler/internal/typeparams"
+	"github.com/gopherjs/gopherjs/compiler/typesutil"
+)
+
+// varUsage is a set of flags describing how a function-level variable is used
+// within the function that declares it.
+//
+// The flags are primarily concerned with struct and array variables, which
+// are represented by mutable JS objects. Go's value semantics requires these
+// objects to be cloned whenever the value is copied, unless we can prove that
+// neither copy is going to be modified while both are in use.
+type varUsage uint8
+
+const (
+	// varMutated means that the variable itself, or any of its fields or
+	// elements is assigned to after the variable declaration.
+	varMutated varUsage = 1 << iota
+	// varExposed means that the variable storage may be accessed by code other
+	// than the function that declares it: its address was taken (explicitly or
+	// implicitly by calling a pointer method), it was sliced, or it was
+	// captured by a function literal.
+	varExposed
+	// varRetained means that the variable storage may be referenced after the
+	// function returns or by other variables: for example, it was returned,
+	// converted to an interface or stored in a composite literal.
+	varRetained
+)
+
+// analyzeVarUsage collects usage information for all function-level
+// variables declared in the given files.
+func (info *Info) analyzeVarUsage(files []*ast.File) {
+	v := &varUsageVisitor{info: info, litDepth: map[*types.Var]int{}}
+	for _, file := range files {
+		ast.Inspect(file, v.visit)
+	}
+}
+
+// IsExposed returns true if the storage of a function-level variable may be
+// accessed by anything other than the function that declares it, for example
+// via a pointer, a closure or a reference that was retained elsewhere.
+//
+// Package-level variables are always considered exposed.
+func (info *Info) IsExposed(v *types.Var) bool {
+	usage, tracked := info.varUsage[v]
+	return !tracked || usage&(varExposed|varRetained) != 0
+}
+
+// IsReadOnly returns true if a function-level variable is never modified,
+// exposed or retained by the function that declares it. Such variable may
+// safely share its storage with another value without cloning it.
+//
+// Package-level variables are never read-only.
+func (info *Info) IsReadOnly(v *types.Var) bool {
+	usage, tracked := info.varUsage[v]
+	return tracked && usage == 0
+}
+
+// IsReadOnlyParam returns true if the function never modifies, exposes or
+// retains the parameter with the given index. Index -1 refers to the method
+// receiver.
+//
+// Struct and array arguments for read-only parameters don't need to be cloned
+// at the call site, as long as the caller itself guarantees that the argument
+// storage is not modified while the call is in progress.
+//
+// If the function is from a different package, this will use the info
+// importer to lookup the information from the other package.
+func (info *Info) IsReadOnlyParam(fn *types.Func, index int) bool {
+	if fn.Pkg() == nil {
+		return false // Universe scope.
+	}
+	if fn.Pkg() != info.Pkg {
+		otherInfo, err := info.infoImporter(fn.Pkg().Path())
+		if err != nil {
+			panic(fmt.Errorf(`failed to get info for package %q: %v`, fn.Pkg().Path(), err))
+		}
+		return otherInfo.IsReadOnlyParam(fn, index)
+	}
+
+	decl := info.funcDecls[fn]
+	if decl == nil || decl.Body == nil {
+		return false // Implemented elsewhere, nothing is known about it.
+	}
+	if typesutil.IsJsPackage(fn.Pkg()) || typeparams.HasTypeParams(fn.Type()) {
+		return false
+	}
+	sig := fn.Type().(*types.Signature)
+	var param *types.Var
+	switch {
+	case index == -1:
+		param = sig.Recv()
+	case index >= 0 && index < sig.Params().Len():
+		if sig.Variadic() && index == sig.Params().Len()-1 {
+			return false
+		}
+		param = sig.Params().At(index)
+	}
+	if param == nil || param.Name() == "" || param.Name() == "_" {
+		// Blank parameters are never used by the function, but they are not
+		// declared either, so we don't have any information about them.
+		return param != nil
+	}
+	return info.IsReadOnly(param)
+}
+
+type varUsageVisitor struct {
+	info *Info
+	// Stack of the AST nodes leading to the currently visited node.
+	stack []ast.Node
+	// Number of function literals the currently visited node is nested in.
+	depth int
+	// Function literal nesting depth at which each variable was declared.
+	litDepth map[*types.Var]int
+}
+
+func (v *varUsageVisitor) visit(n ast.Node) bool {
+	if n == nil {
+		if _, ok := v.stack[len(v.stack)-1].(*ast.FuncLit); ok {
+			v.depth--
+		}
+		v.stack = v.stack[:len(v.stack)-1]
+		return false
+	}
+	v.stack = append(v.stack, n)
+
+	switch n := n.(type) {
+	case *ast.FuncLit:
+		v.depth++
+	case *ast.Ident:
+		if obj, ok := v.info.Defs[n].(*types.Var); ok && !obj.IsField() && obj.Parent() != v.info.Pkg.Scope() {
+			v.info.varUsage[obj] = 0
+			v.litDepth[obj] = v.depth
+			return true
+		}
+		obj, ok := v.info.Uses[n].(*types.Var)
+		if !ok {
+			return true
+		}
+		if _, tracked := v.info.varUsage[obj]; !tracked {
+			return true
+		}
+		if v.depth > v.litDepth[obj] {
+			// Captured by a closure, which can do anything with the variable.
+			v.info.varUsage[obj] |= varMutated | varExposed | varRetained
+			return true
+		}
+		v.info.varUsage[obj] |= v.classify(n)
+	}
+	return true
+}
+
+// classify determines how the value of the variable referenced by the
+// identifier at the top of the stack is used.
+func (v *varUsageVisitor) classify(id *ast.Ident) varUsage {
+	// Find the outermost expression that still refers to the variable's own
+	// storage, e.g. `x.a.b[1]` for an array field of a struct variable `x`.
+	var cur ast.Expr = id
+	i := len(v.stack) - 2
+climb:
+	for ; i >= 0; i-- {
+		switch p := v.stack[i].(type) {
+		case *ast.ParenExpr:
+			cur = p
+			continue
+		case *ast.SelectorExpr:
+			if sel := v.info.Selections[p]; sel != nil && sel.Kind() == types.FieldVal && !sel.Indirect() && p.X == cur {
+				cur = p
+				continue
+			}
+		case *ast.IndexExpr:
+			if _, isArray := v.info.TypeOf(p.X).Underlying().(*types.Array); isArray && p.X == cur {
+				cur = p
+				continue
+			}
+		}
+		break climb
+	}
+	if i < 0 {
+		return 0
+	}
+
+	const all = varMutated | varExposed | varRetained
+	aggregate := isAggregate(v.info.TypeOf(cur))
+
+	switch p := v.stack[i].(type) {
+	case *ast.AssignStmt:
+		for j, lhs := range p.Lhs {
+			if lhs == cur {
+				return varMutated
+			}
+			if len(p.Lhs) == len(p.Rhs) && p.Rhs[j] == cur && aggregate && !isAggregate(v.info.TypeOf(lhs)) {
+				return varRetained // Converted to an interface.
+			}
+		}
+		if len(p.Lhs) != len(p.Rhs) && aggregate {
+			return varRetained
+		}
+		return 0
+	case *ast.IncDecStmt:
+		return varMutated
+	case *ast.RangeStmt:
+		if p.Key == cur || p.Value == cur {
+			return varMutated
+		}
+		return 0
+	case *ast.UnaryExpr:
+		if p.Op == token.AND {
+			return all
+		}
+		return 0
+	case *ast.SliceExpr:
+		if p.X == cur {
+			return all
+		}
+		return 0
+	case *ast.SelectorExpr:
+		sel := v.info.Selections[p]
+		if sel == nil || sel.Kind() != types.MethodVal {
+			return 0
+		}
+		if typesutil.IsJsObject(sel.Recv()) {
+			return all
+		}
+		recv := sel.Obj().Type().(*types.Signature).Recv().Type()
+		if _, ptrRecv := recv.(*types.Pointer); ptrRecv {
+			if _, isPtr := v.info.TypeOf(cur).Underlying().(*types.Pointer); !isPtr {
+				return all // Address is taken implicitly.
+			}
+		}
+		return 0 // Value receivers are copied.
+	case *ast.CallExpr:
+		if p.Fun == cur {
+			return 0
+		}
+		if !aggregate {
+			return 0
+		}
+		return v.classifyArg(p, cur)
+	case *ast.BinaryExpr, *ast.IndexExpr, *ast.ExprStmt:
+		return 0
+	case *ast.ValueSpec:
+		for j, val := range p.Values {
+			if val == cur && len(p.Names) == len(p.Values) && aggregate {
+				if obj, ok := v.info.Defs[p.Names[j]]; ok && !isAggregate(obj.Type()) {
+					return varRetained
+				}
+			}
+		}
+		return 0
+	}
+
+	if aggregate {
+		return varRetained
+	}
+	return 0
+}
+
+// classifyArg determines how an aggregate value passed as a call argument
+// may be used by the callee.
+func (v *varUsageVisitor) classifyArg(call *ast.CallExpr, arg ast.Expr) varUsage {
+	fun := astutil.RemoveParens(call.Fun)
+	if astutil.IsTypeExpr(fun, v.info.Info) {
+		return varRetained // Conversions don't necessarily copy the value.
+	}
+	switch f := fun.(type) {
+	case *ast.Ident:
+		if b, ok := v.info.Uses[f].(*types.Builtin); ok {
+			if b.Name() == "len" || b.Name() == "cap" {
+				return 0
+			}
+			return varRetained
+		}
+		if obj := v.info.Uses[f]; obj != nil && typesutil.IsJsPackage(obj.Pkg()) {
+			return varMutated | varExposed | varRetained
+		}
+	case *ast.SelectorExpr:
+		if obj := v.info.Uses[f.Sel]; obj != nil && typesutil.IsJsPackage(obj.Pkg()) {
+			return varMutated | varExposed | varRetained
+		}
+	}
+
+	sig, ok := v.info.TypeOf(fun).Underlying().(*types.Signature)
+	if !ok {
+		return varRetained
+	}
+	for j, a := range call.Args {
+		if a != arg {
+			continue
+		}
+		if call.Ellipsis.IsValid() || (sig.Variadic() && j >= sig.Params().Len()-1) || j >= sig.Params().Len() {
+			return varRetained
+		}
+		if !isAggregate(sig.Params().At(j).Type()) {
+			return varRetained // Converted to an interface.
+		}
+	}
+	return 0 // Arguments are copied, or passed to a read-only parameter.
+}
+
+// isAggregate returns true if values of the type are represented by mutable
+// JS objects that must be cloned when the value is copied. Type parameters are
+// conservatively assumed to be aggregates.
+func isAggregate(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	if _, ok := t.(*types.TypeParam); ok {
+		return true
+	}
+	switch t.Underlying().(type) {
+	case *types.Struct, *types.Array:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package analysis
+
+import (
+	"go/types"
+	"testing"
+)
+
+func TestVarUsage(t *testing.T) {
+	bt := newBlockingTest(t,
+		`package test
+
+		type Point struct{ X, Y int }
+		type Line struct{ A, B Point }
+		type Vec [3]int
+
+		func (p Point) Sum() int { return p.X + p.Y }
+		func (p *Point) Scale(k int) { p.X *= k; p.Y *= k }
+
+		var sink any
+		var ptr *Point
+
+		func use(p Point) {}
+
+		func f(readParam, mutParam Point) Point {
+			read := Point{1, 2}
+			_ = read.X + read.Sum()
+			use(read)
+
+			field := Line{}
+			field.A.X = 1
+
+			elem := Vec{}
+			elem[1]++
+
+			whole := Point{}
+			whole = read
+
+			addr := Point{}
+			ptr = &addr
+
+			method := Point{}
+			method.Scale(2)
+
+			iface := Point{}
+			sink = iface
+
+			returned := Point{}
+
+			captured := Point{}
+			func() { println(captured.X) }()
+
+			sliced := Vec{}
+			_ = sliced[:]
+
+			converted := Point{}
+			var i any = converted
+			_ = i
+
+			mutParam.Y = 3
+			_ = readParam.X + mutParam.Y + whole.X
+
+			return returned
+		}`)
+
+	tests := []struct {
+		name     string
+		readOnly bool
+		exposed  bool
+	}{
+		{name: `readParam`, readOnly: true},
+		{name: `mutParam`},
+		{name: `read`, readOnly: true},
+		{name: `field`},
+		{name: `elem`},
+		{name: `whole`},
+		{name: `addr`, exposed: true},
+		{name: `method`, exposed: true},
+		{name: `iface`, exposed: true},
+		{name: `returned`, exposed: true},
+		{name: `captured`, exposed: true},
+		{name: `sliced`, exposed: true},
+		{name: `converted`, exposed: true},
+		{name: `sink`, exposed: true}, // Package-level.
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var v *types.Var
+			for id, obj := range bt.pkgInfo.Defs {
+				if o, ok := obj.(*types.Var); ok && id.Name == test.name {
+					v = o
+				}
+			}
+			if v == nil {
+				t.Fatalf(`Variable %q not found.`, test.name)
+			}
+			if got := bt.pkgInfo.IsReadOnly(v); got != test.readOnly {
+				t.Errorf(`Got IsReadOnly(%q) = %v, want %v.`, test.name, got, test.readOnly)
+			}
+			if got := bt.pkgInfo.IsExposed(v); got != test.exposed {
+				t.Errorf(`Got IsExposed(%q) = %v, want %v.`, test.name, got, test.exposed)
+			}
+		})
+	}
+}
+
+func TestIsReadOnlyParam(t *testing.T) {
+	bt := newBlockingTest(t,
+		`package test
+
+		type Point struct{ X, Y int }
+
+		func (p Point) Sum() int { return p.X + p.Y }
+		func (p Point) Moved() Point { p.X++; return p }
+		func read(a Point, b Point) int { b.X = 0; return a.X + b.X }
+		func blank(_ Point) {}
+		func variadic(ps ...Point) {}
+		func external(p Point)`)
+
+	tests := []struct {
+		name  string
+		index int
+		want  bool
+	}{
+		{name: `Sum`, index: -1, want: true},
+		{name: `Moved`, index: -1, want: false},
+		{name: `read`, index: 0, want: true},
+		{name: `read`, index: 1, want: false},
+		{name: `blank`, index: 0, want: true},
+		{name: `variadic`, index: 0, want: false},
+		{name: `external`, index: 0, want: false},
+	}
+
+	for _, test := range tests {
+		var fn *types.Func
+		for _, obj := range bt.pkgInfo.Defs {
+			if f, ok := obj.(*types.Func); ok && f.Name() == test.name {
+				fn = f
+			}
+		}
+		if fn == nil {
+			t.Fatalf(`Function %q not found.`, test.name)
+		}
+		if got := bt.pkgInfo.IsReadOnlyParam(fn, test.index); got != test.want {
+			t.Errorf(`Got IsReadOnlyParam(%q, %d) = %v, want %v.`, test.name, test.index, got, test.want)
+		}
+	}
+}
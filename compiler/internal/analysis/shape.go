@@ -0,0 +1,399 @@
+package analysis
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/gopherjs/gopherjs/compiler/astutil"
+)
+
+// IsShapeAgnostic returns true if the given generic function only uses values
+// of its type parameter types in ways that are translated into the same
+// JavaScript code regardless of the type arguments, as long as none of the type
+// arguments are structs or arrays, which require cloning when copied.
+//
+// Such function may be compiled once and shared by all instances with a
+// runtime dictionary of type arguments, which is only needed to construct
+// zero values and composite types like `[]T`.
+//
+// Values of type parameter types may only be copied between variables,
+// parameters and results of the identical types, stored into and loaded from
+// slices, and passed to or returned from function values. Any other operation,
+// such as a comparison, an arithmetic operation, a method call or a conversion
+// to an interface makes the function dependent on its type arguments.
+func (info *Info) IsShapeAgnostic(fn *types.Func) bool {
+	if agnostic, ok := info.shapeAgnostic[fn]; ok {
+		return agnostic
+	}
+	agnostic := info.checkShapeAgnostic(fn)
+	info.shapeAgnostic[fn] = agnostic
+	return agnostic
+}
+
+func (info *Info) checkShapeAgnostic(fn *types.Func) bool {
+	decl := info.funcDecls[fn]
+	if decl == nil || decl.Body == nil || decl.Recv != nil {
+		return false
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.TypeParams().Len() == 0 {
+		return false
+	}
+
+	c := &shapeChecker{info: info.Info, agnostic: true}
+	if !c.allowed(sig.Params()) || !c.allowed(sig.Results()) {
+		return false
+	}
+	c.sigs = []*types.Signature{sig}
+	ast.Inspect(decl.Body, c.visit)
+	return c.agnostic
+}
+
+// shapeChecker verifies that the function body only performs type-agnostic
+// operations with values of type parameter types, see IsShapeAgnostic.
+type shapeChecker struct {
+	info     *types.Info
+	agnostic bool
+	// Stack of function signatures, the innermost function last. Function
+	// literals are popped when the visitor leaves them.
+	sigs   []*types.Signature
+	lits   []*ast.FuncLit
+	parent []ast.Node
+}
+
+func (c *shapeChecker) visit(n ast.Node) bool {
+	if !c.agnostic {
+		return false
+	}
+	if n == nil {
+		top := c.parent[len(c.parent)-1]
+		if len(c.lits) > 0 && top == c.lits[len(c.lits)-1] {
+			c.lits = c.lits[:len(c.lits)-1]
+			c.sigs = c.sigs[:len(c.sigs)-1]
+		}
+		c.parent = c.parent[:len(c.parent)-1]
+		return false
+	}
+	c.parent = append(c.parent, n)
+
+	if e, ok := n.(ast.Expr); ok && !c.checkExpr(e) {
+		c.agnostic = false
+		return false
+	}
+	if !c.checkNode(n) {
+		c.agnostic = false
+		return false
+	}
+	return true
+}
+
+// checkExpr checks that an expression of a type that refers to type
+// parameters is one of the type-agnostic kinds.
+func (c *shapeChecker) checkExpr(e ast.Expr) bool {
+	if id, ok := e.(*ast.Ident); ok {
+		if inst, ok := c.info.Instances[id]; ok {
+			for i := 0; i < inst.TypeArgs.Len(); i++ {
+				if c.mentions(inst.TypeArgs.At(i)) {
+					return false // Instantiated with our type parameters.
+				}
+			}
+		}
+	}
+
+	tv, ok := c.info.Types[e]
+	if !ok || tv.IsType() || !c.mentions(tv.Type) {
+		return true
+	}
+	if !c.allowed(tv.Type) {
+		return false
+	}
+	switch e := e.(type) {
+	case *ast.Ident, *ast.ParenExpr, *ast.FuncLit, *ast.CallExpr:
+		return true
+	case *ast.IndexExpr:
+		_, isSlice := c.info.TypeOf(e.X).Underlying().(*types.Slice)
+		return isSlice
+	case *ast.SliceExpr:
+		_, isSlice := c.info.TypeOf(e.X).Underlying().(*types.Slice)
+		return isSlice
+	default:
+		return false
+	}
+}
+
+// checkNode checks that values of type parameter types are used in a
+// type-agnostic way by the node.
+func (c *shapeChecker) checkNode(n ast.Node) bool {
+	switch n := n.(type) {
+	case *ast.FuncLit:
+		c.lits = append(c.lits, n)
+		c.sigs = append(c.sigs, c.info.TypeOf(n).(*types.Signature))
+	case *ast.DeclStmt:
+		if d, ok := n.Decl.(*ast.GenDecl); ok && d.Tok == token.TYPE {
+			return false // Local types may depend on type parameters.
+		}
+	case *ast.ValueSpec:
+		for i, val := range n.Values {
+			if len(n.Names) == len(n.Values) && !c.assignable(c.info.TypeOf(n.Names[i]), val) {
+				return false
+			}
+		}
+	case *ast.AssignStmt:
+		if n.Tok != token.ASSIGN && n.Tok != token.DEFINE {
+			return !c.mentionsAny(n.Lhs...)
+		}
+		if len(n.Lhs) == len(n.Rhs) {
+			for i, lhs := range n.Lhs {
+				if !isBlankExpr(lhs) && !c.assignable(c.info.TypeOf(lhs), n.Rhs[i]) {
+					return false
+				}
+			}
+			return true
+		}
+		return c.tupleAssignable(n.Lhs, n.Rhs[0])
+	case *ast.ReturnStmt:
+		results := c.sigs[len(c.sigs)-1].Results()
+		if len(n.Results) == 1 && results.Len() > 1 {
+			return c.tupleAssignable(nil, n.Results[0])
+		}
+		for i, r := range n.Results {
+			if !c.assignable(results.At(i).Type(), r) {
+				return false
+			}
+		}
+	case *ast.CallExpr:
+		return c.checkCall(n)
+	case *ast.SelectorExpr:
+		// Field and method selections on type parameters depend on the type.
+		return !c.mentionsAny(n.X)
+	case *ast.BinaryExpr:
+		if !c.mentionsAny(n.X, n.Y) {
+			return true
+		}
+		// Only nil checks for slices and functions are type-agnostic.
+		return (n.Op == token.EQL || n.Op == token.NEQ) && (c.isNil(n.X) || c.isNil(n.Y))
+	case *ast.UnaryExpr:
+		return !c.mentionsAny(n.X)
+	case *ast.IncDecStmt:
+		return !c.mentionsAny(n.X)
+	case *ast.SendStmt:
+		return !c.mentionsAny(n.Value)
+	case *ast.SwitchStmt:
+		return n.Tag == nil || !c.mentionsAny(n.Tag)
+	case *ast.TypeAssertExpr:
+		return !c.mentionsAny(n.X)
+	case *ast.CompositeLit:
+		for _, elt := range n.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				elt = kv.Value
+			}
+			if c.mentionsAny(elt) {
+				return false // Possibly converted to an interface or copied.
+			}
+		}
+	case *ast.RangeStmt:
+		if c.mentionsAny(n.X) {
+			_, isSlice := c.info.TypeOf(n.X).Underlying().(*types.Slice)
+			return isSlice
+		}
+	}
+	return true
+}
+
+// checkCall checks that arguments of type parameter types are passed without
+// any implicit conversions.
+func (c *shapeChecker) checkCall(call *ast.CallExpr) bool {
+	fun := astutil.RemoveParens(call.Fun)
+	if astutil.IsTypeExpr(fun, c.info) {
+		return !c.mentionsAny(call.Args...) && !c.mentions(c.info.TypeOf(call))
+	}
+	if id, ok := fun.(*ast.Ident); ok {
+		if b, ok := c.info.Uses[id].(*types.Builtin); ok {
+			switch b.Name() {
+			case "len", "cap", "copy", "make", "append":
+				// Arguments may be slices of type parameters, but not type parameters
+				// themselves, which may be constrained to types with a different
+				// implementation of the builtin, like strings.
+				args := call.Args
+				if b.Name() == "append" && !call.Ellipsis.IsValid() {
+					args = args[:1] // Elements are checked below.
+				}
+				for _, arg := range args {
+					if t := c.info.TypeOf(arg); c.mentions(t) && !isSlice(t) {
+						return false
+					}
+				}
+				if b.Name() != "append" || call.Ellipsis.IsValid() {
+					return true
+				}
+				slice := c.info.TypeOf(call).Underlying().(*types.Slice)
+				for _, arg := range call.Args[1:] {
+					if !c.assignable(slice.Elem(), arg) {
+						return false
+					}
+				}
+				return true
+			}
+			return !c.mentionsAny(call.Args...) && !c.mentions(c.info.TypeOf(call))
+		}
+	}
+
+	sig, ok := c.info.TypeOf(fun).Underlying().(*types.Signature)
+	if !ok {
+		return false
+	}
+	if len(call.Args) == 1 && sig.Params().Len() > 1 {
+		return !c.mentionsAny(call.Args...) // f(g()) with multiple results.
+	}
+	params := sig.Params()
+	for i, arg := range call.Args {
+		var param types.Type
+		switch {
+		case sig.Variadic() && i >= params.Len()-1:
+			param = params.At(params.Len() - 1).Type()
+			if !call.Ellipsis.IsValid() {
+				param = param.(*types.Slice).Elem()
+			}
+		default:
+			param = params.At(i).Type()
+		}
+		if !c.assignable(param, arg) {
+			return false
+		}
+	}
+	return true
+}
+
+// assignable returns true if the value of the expression may be assigned to
+// the given type without a conversion that depends on type parameters.
+func (c *shapeChecker) assignable(to types.Type, e ast.Expr) bool {
+	from := c.info.TypeOf(e)
+	if !c.mentions(to) && !c.mentions(from) {
+		return true
+	}
+	return c.isNil(e) || types.Identical(to, from)
+}
+
+// tupleAssignable is the same as assignable for a multi-value expression. If
+// lhs is nil, the values are returned from the innermost function.
+func (c *shapeChecker) tupleAssignable(lhs []ast.Expr, rhs ast.Expr) bool {
+	tuple, ok := c.info.TypeOf(rhs).(*types.Tuple)
+	if !ok {
+		// Comma-ok expressions, which never involve allowed generic types.
+		return !c.mentionsAny(lhs...) && !c.mentionsAny(rhs)
+	}
+	for i := 0; i < tuple.Len(); i++ {
+		var to types.Type
+		if lhs == nil {
+			to = c.sigs[len(c.sigs)-1].Results().At(i).Type()
+		} else if !isBlankExpr(lhs[i]) {
+			to = c.info.TypeOf(lhs[i])
+		}
+		if to != nil && (c.mentions(to) || c.mentions(tuple.At(i).Type())) && !types.Identical(to, tuple.At(i).Type()) {
+			return false
+		}
+	}
+	return true
+}
+
+func isSlice(t types.Type) bool {
+	_, ok := t.(*types.Slice)
+	return ok
+}
+
+func isBlankExpr(e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == "_"
+}
+
+func (c *shapeChecker) isNil(e ast.Expr) bool {
+	id, ok := astutil.RemoveParens(e).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	_, isNil := c.info.Uses[id].(*types.Nil)
+	return isNil
+}
+
+func (c *shapeChecker) mentionsAny(exprs ...ast.Expr) bool {
+	for _, e := range exprs {
+		if c.mentions(c.info.TypeOf(e)) {
+			return true
+		}
+	}
+	return false
+}
+
+// mentions returns true if the type refers to any type parameters.
+func (c *shapeChecker) mentions(t types.Type) bool {
+	switch t := t.(type) {
+	case nil:
+		return false
+	case *types.TypeParam:
+		return true
+	case *types.Named:
+		for i := 0; i < t.TypeArgs().Len(); i++ {
+			if c.mentions(t.TypeArgs().At(i)) {
+				return true
+			}
+		}
+		return false
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			if c.mentions(t.At(i).Type()) {
+				return true
+			}
+		}
+		return false
+	case *types.Signature:
+		return c.mentions(t.Params()) || c.mentions(t.Results())
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if c.mentions(t.Field(i).Type()) {
+				return true
+			}
+		}
+		return false
+	case *types.Interface:
+		for i := 0; i < t.NumMethods(); i++ {
+			if c.mentions(t.Method(i).Type()) {
+				return true
+			}
+		}
+		return false
+	case *types.Map:
+		return c.mentions(t.Key()) || c.mentions(t.Elem())
+	case interface{ Elem() types.Type }:
+		return c.mentions(t.Elem())
+	default:
+		return false
+	}
+}
+
+// allowed returns true if the type is either independent from type
+// parameters, or is one of the types the values of which are represented and
+// copied the same way regardless of type arguments: a type parameter itself,
+// a slice or a function type.
+func (c *shapeChecker) allowed(t types.Type) bool {
+	if !c.mentions(t) {
+		return true
+	}
+	switch t := t.(type) {
+	case *types.TypeParam:
+		return true
+	case *types.Slice:
+		return c.allowed(t.Elem())
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			if !c.allowed(t.At(i).Type()) {
+				return false
+			}
+		}
+		return true
+	case *types.Signature:
+		return c.allowed(t.Params()) && c.allowed(t.Results())
+	default:
+		return false
+	}
+}
@@ -0,0 +1,118 @@
+package analysis
+
+import (
+	"go/types"
+	"testing"
+)
+
+func TestIsShapeAgnostic(t *testing.T) {
+	bt := newBlockingTest(t,
+		`package test
+
+		type Stringer interface{ String() string }
+		type List[T any] struct{ items []T }
+
+		func filter[T any](s []T, keep func(T) bool) []T {
+			var out []T
+			for _, v := range s {
+				if keep(v) {
+					out = append(out, v)
+				}
+			}
+			return out
+		}
+		func mapSlice[T, U any](s []T, f func(T) U) []U {
+			out := make([]U, 0, len(s))
+			for i := range s {
+				out = append(out, f(s[i]))
+			}
+			return out
+		}
+		func last[T any](s []T) (T, bool) {
+			var zero T
+			if len(s) == 0 {
+				return zero, false
+			}
+			return s[len(s)-1], true
+		}
+		func reverse[T any](s []T) {
+			for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+				s[i], s[j] = s[j], s[i]
+			}
+		}
+		func apply[T any](v T, fs ...func(T) T) T {
+			for _, f := range fs {
+				v = f(v)
+			}
+			return v
+		}
+
+		func contains[T comparable](s []T, v T) bool {
+			for _, x := range s {
+				if x == v {
+					return true
+				}
+			}
+			return false
+		}
+		func sum[T int | float64](s []T) T {
+			var total T
+			for _, v := range s {
+				total += v
+			}
+			return total
+		}
+		func boxed[T any](v T) any { return v }
+		func printed[T any](v T) { println(v) }
+		func stringer[T Stringer](v T) string { return v.String() }
+		func size[S []byte | string](s S) int { return len(s) }
+		func pointer[T any](v T) *T { return &v }
+		func instantiates[T any](s []T) []T { return filter(s, nil) }
+		func generic[T any]() List[T] { return List[T]{} }
+		func literal[T any](v T) []T { return []T{v} }
+		func local[T any](v T) {
+			type pair struct{ a, b T }
+			_ = pair{v, v}
+		}
+		func notGeneric(s []int) []int { return s }`)
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: `filter`, want: true},
+		{name: `mapSlice`, want: true},
+		{name: `last`, want: true},
+		{name: `reverse`, want: true},
+		{name: `apply`, want: true},
+		{name: `contains`, want: false},
+		{name: `sum`, want: false},
+		{name: `boxed`, want: false},
+		{name: `printed`, want: false},
+		{name: `stringer`, want: false},
+		{name: `size`, want: false},
+		{name: `pointer`, want: false},
+		{name: `instantiates`, want: false},
+		{name: `generic`, want: false},
+		{name: `literal`, want: false},
+		{name: `local`, want: false},
+		{name: `notGeneric`, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var fn *types.Func
+			for _, obj := range bt.pkgInfo.Defs {
+				if f, ok := obj.(*types.Func); ok && f.Name() == test.name {
+					fn = f
+				}
+			}
+			if fn == nil {
+				t.Fatalf(`Function %q not found.`, test.name)
+			}
+			if got := bt.pkgInfo.IsShapeAgnostic(fn); got != test.want {
+				t.Errorf(`Got IsShapeAgnostic(%q) = %v, want %v.`, test.name, got, test.want)
+			}
+		})
+	}
+}
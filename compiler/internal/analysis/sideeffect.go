@@ -4,14 +4,40 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+
+	"github.com/gopherjs/gopherjs/compiler/astutil"
 )
 
+// HasSideEffect returns true if evaluating the given expression may have
+// side effects, i.e. it may change the program state or block.
+//
+// The check is conservative: calls are assumed to have side effects, except
+// for the calls to pure built-in functions and known pure functions from the
+// standard library (see pureFuncs). Defining a function literal doesn't have
+// side effects, no matter what its body does.
 func HasSideEffect(n ast.Node, info *types.Info) bool {
 	v := hasSideEffectVisitor{info: info}
 	ast.Walk(&v, n)
 	return v.hasSideEffect
 }
 
+// pureFuncs is a set of full names of the standard library functions, which
+// don't have side effects and can't panic, so calls to them may be skipped
+// if their results are not used.
+var pureFuncs = map[string]bool{
+	"bytes.NewBuffer":       true,
+	"bytes.NewBufferString": true,
+	"bytes.NewReader":       true,
+	"errors.New":            true,
+	"math.Float32frombits":  true,
+	"math.Float64frombits":  true,
+	"math.Inf":              true,
+	"math.NaN":              true,
+	"math/big.NewInt":       true,
+	"reflect.TypeOf":        true,
+	"strings.NewReader":     true,
+}
+
 type hasSideEffectVisitor struct {
 	info          *types.Info
 	hasSideEffect bool
@@ -24,14 +50,50 @@ func (v *hasSideEffectVisitor) Visit(node ast.Node) (w ast.Visitor) {
 	switch n := node.(type) {
 	case *ast.CallExpr:
 		if _, isSig := v.info.TypeOf(n.Fun).(*types.Signature); isSig { // skip conversions
-			v.hasSideEffect = true
-			return nil
+			if !v.isPureCall(n) {
+				v.hasSideEffect = true
+				return nil
+			}
 		}
 	case *ast.UnaryExpr:
 		if n.Op == token.ARROW {
 			v.hasSideEffect = true
 			return nil
 		}
+	case *ast.FuncLit:
+		return nil // The body is only executed when the function is called.
 	}
 	return v
 }
+
+// isPureCall returns true if the call itself has no side effects. The call
+// arguments still need to be checked separately.
+func (v *hasSideEffectVisitor) isPureCall(call *ast.CallExpr) bool {
+	var id *ast.Ident
+	switch fun := astutil.RemoveParens(call.Fun).(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return false
+	}
+	switch o := v.info.Uses[id].(type) {
+	case *types.Builtin:
+		switch o.Name() {
+		case "cap", "complex", "imag", "len", "max", "min", "new", "real":
+			return true
+		case "make":
+			// Make panics if the size arguments are out of range at runtime.
+			for _, arg := range call.Args[1:] {
+				if tv, ok := v.info.Types[arg]; !ok || tv.Value == nil {
+					return false
+				}
+			}
+			return true
+		}
+	case *types.Func:
+		return pureFuncs[o.FullName()]
+	}
+	return false
+}
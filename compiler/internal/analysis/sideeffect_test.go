@@ -0,0 +1,58 @@
+package analysis
+
+import (
+	"go/ast"
+	"testing"
+
+	"github.com/gopherjs/gopherjs/internal/srctesting"
+)
+
+func TestHasSideEffect(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want bool
+	}{
+		{name: `constant`, expr: `1 + 2`, want: false},
+		{name: `variable`, expr: `n`, want: false},
+		{name: `composite literal`, expr: `map[string][]int{"a": {1, 2}}`, want: false},
+		{name: `address of composite literal`, expr: `&struct{ x int }{n}`, want: false},
+		{name: `conversion`, expr: `float64(n)`, want: false},
+		{name: `function literal`, expr: `func() { println(g()) }`, want: false},
+		{name: `pure builtin`, expr: `len("abc") + min(n, 2)`, want: false},
+		{name: `make with constant size`, expr: `make([]int, 2, 4)`, want: false},
+		{name: `make without size`, expr: `make(map[int]int)`, want: false},
+		{name: `make with variable size`, expr: `make([]int, n)`, want: true},
+		{name: `known pure function`, expr: `errors.New("foo")`, want: false},
+		{name: `pure function with impure argument`, expr: `errors.New(g())`, want: true},
+		{name: `function call`, expr: `g()`, want: true},
+		{name: `function call in composite literal`, expr: `[]int{g2()}`, want: true},
+		{name: `called function literal`, expr: `func() int { return 1 }()`, want: true},
+		{name: `impure builtin`, expr: `append([]int(nil), 1)`, want: true},
+		{name: `channel receive`, expr: `<-ch`, want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := srctesting.New(t)
+			errors := f.Parse(`errors.go`, `package errors
+				func New(text string) error { return nil }`)
+			f.Check(`errors`, errors)
+
+			file := f.Parse(`test.go`, `package test
+				import "errors"
+				var n = 1
+				var ch chan int
+				func g() string { return "" }
+				func g2() int { return 0 }
+				var x = `+test.expr+`
+				var _, _ = errors.New, ch`)
+			info, _ := f.Check(`pkg/test`, file)
+
+			spec := file.Decls[len(file.Decls)-2].(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+			if got := HasSideEffect(spec.Values[0], info); got != test.want {
+				t.Errorf("HasSideEffect(%s) returned %t, want %t", test.expr, got, test.want)
+			}
+		})
+	}
+}
@@ -15,8 +15,21 @@ type Decl interface {
 // that'll be used in dead-code elimination (DCE).
 type Collector struct {
 	dce *Info
+
+	// kind determines which set of the declaration's dependencies
+	// the declared dependencies are added to.
+	kind depKind
 }
 
+// depKind is the kind of dependencies currently being collected.
+type depKind int
+
+const (
+	codeDeps    depKind = iota // Dependencies of the declaration's code.
+	reflectDeps                // Types exposed to reflection.
+	metaDeps                   // Dependencies of the full reflection metadata.
+)
+
 // CollectDCEDeps captures a list of Go objects (types, functions, etc.)
 // the code translated inside f() depends on. Then sets those objects
 // as dependencies of the given dead-code elimination info.
@@ -33,6 +46,32 @@ func (c *Collector) CollectDCEDeps(decl Decl, f func()) {
 	f()
 }
 
+// CollectDCEReflectDeps captures the types the code translated inside f()
+// refers to as types whose values the declaration being collected may expose
+// to reflection, instead of as its dependencies.
+//
+// Outside of a CollectDCEDeps call, f() is run and nothing is captured.
+func (c *Collector) CollectDCEReflectDeps(f func()) {
+	c.collectKind(reflectDeps, f)
+}
+
+// CollectDCEMetadataDeps captures the Go objects the full reflection metadata
+// translated inside f() depends on. These are only alive if the declaration
+// being collected is reflected upon, see Selector.AliveDecls.
+//
+// Outside of a CollectDCEDeps call, f() is run and nothing is captured.
+func (c *Collector) CollectDCEMetadataDeps(f func()) {
+	c.collectKind(metaDeps, f)
+}
+
+func (c *Collector) collectKind(kind depKind, f func()) {
+	prev := c.kind
+	c.kind = kind
+	defer func() { c.kind = prev }()
+
+	f()
+}
+
 // DeclareDCEDep records that the code that is currently being transpiled
 // depends on a given Go object with optional type arguments.
 //
@@ -40,7 +79,15 @@ func (c *Collector) CollectDCEDeps(decl Decl, f func()) {
 // function with type parameters or anytime the object doesn't carry them.
 // If not given, this attempts to get the type arguments from the object.
 func (c *Collector) DeclareDCEDep(o types.Object, tNest, tArgs []types.Type) {
-	if c.dce != nil {
+	if c.dce == nil {
+		return
+	}
+	switch c.kind {
+	case reflectDeps:
+		c.dce.addReflectDep(o, tNest, tArgs)
+	case metaDeps:
+		c.dce.addMetaDep(o, tNest, tArgs)
+	default:
 		c.dce.addDep(o, tNest, tArgs)
 	}
 }
@@ -11,6 +11,7 @@ import (
 	"sort"
 	"testing"
 
+	"github.com/gopherjs/gopherjs/compiler/internal/symbol"
 	"github.com/gopherjs/gopherjs/compiler/typesutil"
 )
 
@@ -1064,7 +1065,7 @@ func Test_Selector_JustVars(t *testing.T) {
 
 			s := &Selector[*testDecl]{}
 			for _, decl := range fellowship {
-				s.Include(decl, false)
+				s.Include(decl)
 			}
 
 			selected := s.AliveDecls()
@@ -1162,7 +1163,7 @@ func Test_Selector_SpecificMethods(t *testing.T) {
 
 			s := Selector[*testDecl]{}
 			for _, decl := range allDecls {
-				s.Include(decl, false)
+				s.Include(decl)
 			}
 			selected := s.AliveDecls()
 			for _, decl := range tt.want {
@@ -1178,6 +1179,162 @@ func Test_Selector_SpecificMethods(t *testing.T) {
 	}
 }
 
+func Test_Selector_Reflection(t *testing.T) {
+	objects := parseObjects(t,
+		`package discworld
+
+		type Luggage struct{ p Pear }
+		type Pear struct{}
+		type Octavo struct{}
+		type Hex struct{}
+		func Rincewind() {}`)
+
+	var (
+		luggage   = quickTestDecl(objects[0])
+		pear      = quickTestDecl(objects[2])
+		octavo    = quickTestDecl(objects[3])
+		hex       = quickTestDecl(objects[4])
+		rincewind = quickTestDecl(objects[5])
+	)
+	allDecls := []*testDecl{luggage, pear, octavo, hex, rincewind}
+	for _, decl := range []*testDecl{luggage, pear, octavo, hex} {
+		decl.Dce().SetAsReflectable()
+	}
+
+	c := Collector{}
+	c.CollectDCEDeps(luggage, func() {
+		c.DeclareDCEDep(pear.obj, nil, nil)
+		c.CollectDCEMetadataDeps(func() {
+			c.DeclareDCEDep(octavo.obj, nil, nil)
+		})
+	})
+	rincewind.Dce().SetAsAlive()
+
+	tests := []struct {
+		name          string
+		reflects      []*testDecl // which decls rincewind exposes to reflection
+		wantAlive     []*testDecl
+		wantReflected []*testDecl
+	}{
+		{
+			name:          `no reflection`,
+			reflects:      []*testDecl{},
+			wantAlive:     []*testDecl{rincewind, luggage, pear, hex},
+			wantReflected: []*testDecl{},
+		},
+		{
+			name:          `reflected with dependencies`,
+			reflects:      []*testDecl{luggage},
+			wantAlive:     []*testDecl{rincewind, luggage, pear, octavo, hex},
+			wantReflected: []*testDecl{luggage, pear, octavo},
+		},
+		{
+			name:          `reflected dependency only`,
+			reflects:      []*testDecl{pear, hex},
+			wantAlive:     []*testDecl{rincewind, luggage, pear, hex},
+			wantReflected: []*testDecl{pear, hex},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rincewind.Dce().deps = nil // reset deps
+			rincewind.Dce().reflectDeps = nil
+			c.CollectDCEDeps(rincewind, func() {
+				c.DeclareDCEDep(luggage.obj, nil, nil)
+				c.DeclareDCEDep(hex.obj, nil, nil)
+				c.CollectDCEReflectDeps(func() {
+					for _, decl := range tt.reflects {
+						c.DeclareDCEDep(decl.obj, nil, nil)
+					}
+				})
+			})
+
+			s := Selector[*testDecl]{}
+			for _, decl := range allDecls {
+				s.Include(decl)
+			}
+			checkSelected(t, `alive`, s.AliveDecls(), tt.wantAlive)
+			checkSelected(t, `reflected`, s.ReflectedDecls(), tt.wantReflected)
+		})
+	}
+}
+
+func Test_Selector_Linknames(t *testing.T) {
+	objects := parseObjects(t,
+		`package discworld
+
+		type Clacks struct{}
+		func Vetinari() {}
+		func Drumknott() {}
+		func (Clacks) send() {}
+		func Moist()`)
+
+	var (
+		clacks    = quickTestDecl(objects[0])
+		vetinari  = quickTestDecl(objects[1])
+		drumknott = quickTestDecl(objects[2])
+		send      = quickTestDecl(objects[3])
+		moist     = quickTestDecl(objects[4])
+	)
+	allDecls := []*testDecl{clacks, vetinari, drumknott, send, moist}
+	vetinari.Dce().SetAsAlive()
+	moist.Dce().AddLinkDep(symbol.New(drumknott.obj))
+	moist.Dce().AddLinkDep(symbol.New(send.obj))
+
+	tests := []struct {
+		name      string
+		deps      []*testDecl // which decls vetinari depends on
+		wantAlive []*testDecl
+	}{
+		{
+			name:      `unreferenced link`,
+			deps:      []*testDecl{},
+			wantAlive: []*testDecl{vetinari},
+		},
+		{
+			name:      `referenced link`,
+			deps:      []*testDecl{moist},
+			wantAlive: []*testDecl{vetinari, moist, drumknott, clacks, send},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vetinari.Dce().deps = nil // reset deps
+			c := Collector{}
+			c.CollectDCEDeps(vetinari, func() {
+				for _, decl := range tt.deps {
+					c.DeclareDCEDep(decl.obj, nil, nil)
+				}
+			})
+
+			s := Selector[*testDecl]{}
+			for _, decl := range allDecls {
+				s.Include(decl)
+			}
+			checkSelected(t, `alive`, s.AliveDecls(), tt.wantAlive)
+		})
+	}
+}
+
+func checkSelected(t *testing.T, kind string, selected map[*testDecl]struct{}, want []*testDecl) {
+	t.Helper()
+	got := make(map[*testDecl]struct{}, len(selected))
+	for decl := range selected {
+		got[decl] = struct{}{}
+	}
+	for _, decl := range want {
+		if _, ok := got[decl]; !ok {
+			t.Errorf(`expected %q to be %s`, decl.obj.String(), kind)
+		}
+		delete(got, decl)
+	}
+	for decl := range got {
+		t.Errorf(`expected %q not to be %s`, decl.obj.String(), kind)
+	}
+}
+
 type testDecl struct {
 	obj types.Object // should match the object used in Dce.SetName when set
 	dce Info
@@ -5,6 +5,8 @@ import (
 	"go/types"
 	"sort"
 	"strings"
+
+	"github.com/gopherjs/gopherjs/compiler/internal/symbol"
 )
 
 // Info contains information used by the dead-code elimination (DCE) logic to
@@ -30,6 +32,27 @@ type Info struct {
 	// Set of fully qualified (including package path) DCE symbol
 	// and/or method names that this DCE declaration depends on.
 	deps map[string]struct{}
+
+	// reflectable indicates the declaration is a type which carries
+	// reflection metadata. See ./README.md#reflection for more information.
+	reflectable bool
+
+	// Set of DCE names of types whose values this declaration may expose to
+	// reflection, e.g. by converting them into an interface.
+	reflectDeps map[string]struct{}
+
+	// Set of DCE names that only the full reflection metadata of this
+	// declaration depends on. These are only alive when the declaration
+	// is reflected upon.
+	metaDeps map[string]struct{}
+
+	// linkingName is the symbol name of a function declaration, by which
+	// go:linkname directives may reference it as an implementation.
+	linkingName string
+
+	// Set of symbol names of go:linkname implementations that this declaration
+	// references. See ./README.md#links for more information.
+	linkDeps map[string]struct{}
 }
 
 // String gets a human-readable representation of the DCE info.
@@ -41,6 +64,9 @@ func (d *Info) String() string {
 	if d.unnamed() {
 		tags += `[unnamed] `
 	}
+	if d.reflectable {
+		tags += `[reflectable] `
+	}
 	names := []string{}
 	if len(d.objectFilter) > 0 {
 		names = append(names, d.objectFilter+` `)
@@ -48,7 +74,17 @@ func (d *Info) String() string {
 	if len(d.methodFilter) > 0 {
 		names = append(names, d.methodFilter+` `)
 	}
-	return tags + strings.Join(names, `& `) + `-> [` + strings.Join(d.getDeps(), `, `) + `]`
+	str := tags + strings.Join(names, `& `) + `-> [` + strings.Join(d.getDeps(), `, `) + `]`
+	if len(d.reflectDeps) > 0 {
+		str += ` reflects [` + strings.Join(sortedNames(d.reflectDeps), `, `) + `]`
+	}
+	if len(d.metaDeps) > 0 {
+		str += ` metadata [` + strings.Join(sortedNames(d.metaDeps), `, `) + `]`
+	}
+	if len(d.linkDeps) > 0 {
+		str += ` links [` + strings.Join(sortedNames(d.linkDeps), `, `) + `]`
+	}
+	return str
 }
 
 // unnamed returns true if SetName has not been called for this declaration.
@@ -73,6 +109,12 @@ func (d *Info) SetAsAlive() {
 	d.alive = true
 }
 
+// SetAsReflectable marks the declaration as a type that carries reflection
+// metadata. When the type is reflected upon, so are all types it depends on.
+func (d *Info) SetAsReflectable() {
+	d.reflectable = true
+}
+
 // SetName sets the name used by DCE to represent the declaration
 // this DCE info is attached to.
 //
@@ -86,6 +128,16 @@ func (d *Info) SetName(o types.Object, tNest, tArgs []types.Type) {
 
 	// Determine name(s) for DCE.
 	d.objectFilter, d.methodFilter = getFilters(o, tNest, tArgs)
+	if f, ok := o.(*types.Func); ok && len(tArgs) == 0 {
+		d.linkingName = symbol.New(f).String()
+	}
+}
+
+// AddLinkDep records that the declaration references the implementation with
+// the given symbol name via a go:linkname directive, so the implementation is
+// alive whenever this declaration is.
+func (d *Info) AddLinkDep(impl symbol.Name) {
+	addName(&d.linkDeps, impl.String())
 }
 
 // addDep add a declaration dependencies used by DCE
@@ -98,22 +150,47 @@ func (d *Info) addDep(o types.Object, tNest, tArgs []types.Type) {
 
 // addDepName adds a declaration dependency by name.
 func (d *Info) addDepName(depName string) {
-	if len(depName) > 0 {
-		if d.deps == nil {
-			d.deps = make(map[string]struct{})
+	addName(&d.deps, depName)
+}
+
+// addReflectDep adds a type, which values the declaration may expose to
+// reflection, to the reflection dependencies.
+func (d *Info) addReflectDep(o types.Object, tNest, tArgs []types.Type) {
+	objectFilter, _ := getFilters(o, tNest, tArgs)
+	addName(&d.reflectDeps, objectFilter)
+}
+
+// addMetaDep adds a dependency of the full reflection metadata
+// of the declaration.
+func (d *Info) addMetaDep(o types.Object, tNest, tArgs []types.Type) {
+	objectFilter, methodFilter := getFilters(o, tNest, tArgs)
+	addName(&d.metaDeps, objectFilter)
+	addName(&d.metaDeps, methodFilter)
+}
+
+// addName adds the given name to the set, creating the set if needed.
+func addName(set *map[string]struct{}, name string) {
+	if len(name) > 0 {
+		if *set == nil {
+			*set = make(map[string]struct{})
 		}
-		d.deps[depName] = struct{}{}
+		(*set)[name] = struct{}{}
 	}
 }
 
 // getDeps gets the dependencies for the declaration sorted by name.
 func (id *Info) getDeps() []string {
-	deps := make([]string, len(id.deps))
+	return sortedNames(id.deps)
+}
+
+// sortedNames returns the names in the given set sorted.
+func sortedNames(set map[string]struct{}) []string {
+	names := make([]string, len(set))
 	i := 0
-	for dep := range id.deps {
-		deps[i] = dep
+	for name := range set {
+		names[i] = name
 		i++
 	}
-	sort.Strings(deps)
-	return deps
+	sort.Strings(names)
+	return names
 }
@@ -9,11 +9,30 @@ type DeclConstraint interface {
 }
 
 // Selector gathers all declarations that are still alive after dead-code elimination.
+//
+// It also determines which of the alive types are reflected upon, i.e. may be
+// inspected by the reflection at runtime, so that the full reflection metadata
+// is only kept for those types. See ./README.md#reflection for more information.
 type Selector[D DeclConstraint] struct {
 	byFilter map[string][]*declInfo[D]
 
 	// A queue of live decls to find other live decls.
 	pendingDecls []D
+
+	// Reflectable decls by their object filter.
+	reflectables map[string][]D
+	// Names of types known to be reflected upon.
+	reflectedNames map[string]struct{}
+	// A queue of live decls that are reflected upon to find other
+	// reflected upon types and live decls.
+	pendingReflected []D
+
+	// Function decls by their symbol names, which go:linkname directives
+	// may reference as implementations.
+	byLinkingName map[string][]D
+
+	alive     map[D]struct{}
+	reflected map[D]struct{}
 }
 
 type declInfo[D DeclConstraint] struct {
@@ -23,20 +42,30 @@ type declInfo[D DeclConstraint] struct {
 }
 
 // Include will add a new declaration to be checked as alive or not.
-func (s *Selector[D]) Include(decl D, implementsLink bool) {
+func (s *Selector[D]) Include(decl D) {
 	if s.byFilter == nil {
 		s.byFilter = make(map[string][]*declInfo[D])
 	}
 
 	dce := decl.Dce()
 
-	if dce.isAlive() {
-		s.pendingDecls = append(s.pendingDecls, decl)
-		return
+	if dce.reflectable && dce.objectFilter != `` {
+		if s.reflectables == nil {
+			s.reflectables = make(map[string][]D)
+		}
+		s.reflectables[dce.objectFilter] = append(s.reflectables[dce.objectFilter], decl)
 	}
 
-	if implementsLink {
+	if dce.linkingName != `` {
+		if s.byLinkingName == nil {
+			s.byLinkingName = make(map[string][]D)
+		}
+		s.byLinkingName[dce.linkingName] = append(s.byLinkingName[dce.linkingName], decl)
+	}
+
+	if dce.isAlive() {
 		s.pendingDecls = append(s.pendingDecls, decl)
+		return
 	}
 
 	info := &declInfo[D]{decl: decl}
@@ -63,31 +92,103 @@ func (s *Selector[D]) popPending() D {
 // after dead-code elimination.
 // This should only be called once all declarations have been included.
 func (s *Selector[D]) AliveDecls() map[D]struct{} {
-	dceSelection := make(map[D]struct{}) // Known live decls.
-	for len(s.pendingDecls) != 0 {
+	s.alive = make(map[D]struct{})     // Known live decls.
+	s.reflected = make(map[D]struct{}) // Known reflected upon live decls.
+	for len(s.pendingDecls) != 0 || len(s.pendingReflected) != 0 {
+		if len(s.pendingReflected) != 0 {
+			d := s.pendingReflected[len(s.pendingReflected)-1]
+			s.pendingReflected = s.pendingReflected[:len(s.pendingReflected)-1]
+			dce := d.Dce()
+
+			// The full metadata is kept for the decl, so everything it depends
+			// on is alive and every type it refers to is reflected upon as well.
+			for _, dep := range sortedNames(dce.metaDeps) {
+				s.markAlive(dep)
+				s.markReflected(dep)
+			}
+			for _, dep := range dce.getDeps() {
+				s.markReflected(dep)
+			}
+			continue
+		}
+
 		d := s.popPending()
 		dce := d.Dce()
 
-		dceSelection[d] = struct{}{} // Mark the decl as live.
+		s.alive[d] = struct{}{} // Mark the decl as live.
 
 		// Consider all decls the current one is known to depend on and possible add
 		// them to the live queue.
 		for _, dep := range dce.getDeps() {
-			if infos, ok := s.byFilter[dep]; ok {
-				delete(s.byFilter, dep)
-				for _, info := range infos {
-					if info.objectFilter == dep {
-						info.objectFilter = ``
-					}
-					if info.methodFilter == dep {
-						info.methodFilter = ``
-					}
-					if info.objectFilter == `` && info.methodFilter == `` {
-						s.pendingDecls = append(s.pendingDecls, info.decl)
-					}
-				}
+			s.markAlive(dep)
+		}
+		for _, link := range sortedNames(dce.linkDeps) {
+			for _, impl := range s.byLinkingName[link] {
+				s.markAlive(impl.Dce().objectFilter)
+				s.markAlive(impl.Dce().methodFilter)
 			}
 		}
+		for _, dep := range sortedNames(dce.reflectDeps) {
+			s.markReflected(dep)
+		}
+		if _, ok := s.reflectedNames[dce.objectFilter]; ok && dce.reflectable {
+			s.addReflected(d)
+		}
+	}
+	return s.alive
+}
+
+// ReflectedDecls returns a set of declarations, which are alive after
+// dead-code elimination and reflected upon, so they need the full
+// reflection metadata.
+// This should only be called after AliveDecls.
+func (s *Selector[D]) ReflectedDecls() map[D]struct{} {
+	return s.reflected
+}
+
+// markAlive adds the decls, which are waiting on the given name
+// and have no other unresolved names, to the live queue.
+func (s *Selector[D]) markAlive(dep string) {
+	infos, ok := s.byFilter[dep]
+	if !ok {
+		return
+	}
+	delete(s.byFilter, dep)
+	for _, info := range infos {
+		if info.objectFilter == dep {
+			info.objectFilter = ``
+		}
+		if info.methodFilter == dep {
+			info.methodFilter = ``
+		}
+		if info.objectFilter == `` && info.methodFilter == `` {
+			s.pendingDecls = append(s.pendingDecls, info.decl)
+		}
+	}
+}
+
+// markReflected records that the type with the given name is reflected upon
+// and adds its decls, which are already alive, to the reflected queue.
+// Decls which become alive later are added when they are visited.
+func (s *Selector[D]) markReflected(name string) {
+	if _, ok := s.reflectedNames[name]; ok {
+		return
+	}
+	if s.reflectedNames == nil {
+		s.reflectedNames = make(map[string]struct{})
+	}
+	s.reflectedNames[name] = struct{}{}
+	for _, d := range s.reflectables[name] {
+		if _, ok := s.alive[d]; ok {
+			s.addReflected(d)
+		}
+	}
+}
+
+func (s *Selector[D]) addReflected(d D) {
+	if _, ok := s.reflected[d]; ok {
+		return
 	}
-	return dceSelection
+	s.reflected[d] = struct{}{}
+	s.pendingReflected = append(s.pendingReflected, d)
 }
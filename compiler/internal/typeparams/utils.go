@@ -105,6 +105,12 @@ func HasTypeParams(typ types.Type) bool {
 	}
 }
 
+// ContainsTypeParams returns true if the type refers to any type parameters,
+// which haven't been substituted with type arguments.
+func ContainsTypeParams(typ types.Type) bool {
+	return isGeneric(nil, []types.Type{typ})
+}
+
 // isGeneric will search all the given types in `typ` and their subtypes for a
 // *types.TypeParam. This will not check if a type could be generic,
 // but if each instantiation is not completely concrete yet.
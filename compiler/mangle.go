@@ -0,0 +1,151 @@
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"go/types"
+	"hash/fnv"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/gopherjs/gopherjs/compiler/natives"
+)
+
+// propMarkerPrefix starts a placeholder for a mangled property name in the
+// generated code. The escape character can't appear in the code verbatim
+// otherwise, because encodeString escapes it in string literals, so markers
+// are found reliably regardless of where the property name is used.
+const propMarkerPrefix = "$\x1b"
+
+// propMarkerLen is the length of a mangled property marker, which consists of
+// propMarkerPrefix and a 16-digit hex hash of the qualified property name.
+const propMarkerLen = len(propMarkerPrefix) + 16
+
+// mangledPkg returns true if names of unexported fields and methods declared
+// in the package with the given import path are mangled with
+// Options.MangleProps.
+//
+// GopherJS's own packages and packages augmented with natives are excluded,
+// because the natives, .inc.js files and the runtime access their unexported
+// fields by name. The decision depends on the import path alone, so that code
+// referencing a field of another package (e.g. inlined from it) agrees with
+// the declaring package.
+func mangledPkg(path string) bool {
+	if path == "github.com/gopherjs/gopherjs" || strings.HasPrefix(path, "github.com/gopherjs/gopherjs/") {
+		return false
+	}
+	_, err := fs.Stat(natives.FS, "src/"+path)
+	return err != nil
+}
+
+// mangledProp returns the marker for the property name of an unexported field
+// or method name declared in the package with the given import path. Markers
+// are replaced with short names when the program is linked, see propMangler.
+func mangledProp(pkgPath, name string) string {
+	h := fnv.New64a()
+	h.Write([]byte(pkgPath))
+	h.Write([]byte{'.'})
+	h.Write([]byte(name))
+	return fmt.Sprintf("%s%016x", propMarkerPrefix, h.Sum64())
+}
+
+// mangles returns true if the property name of a field or method with the
+// given name declared in pkg must be replaced with a marker.
+func (fc *funcContext) mangles(pkg *types.Package, name string) bool {
+	return fc.pkgCtx.opts.MangleProps && pkg != nil && name != "_" && !token.IsExported(name) && mangledPkg(pkg.Path())
+}
+
+// propMangler allocates short names for mangled property markers across the
+// whole program and substitutes them into the generated code.
+//
+// The names are allocated in the order of decreasing number of occurrences of
+// a marker, so that the most used properties get the shortest names. All names
+// start with a '$' followed by a digit, which never occurs in the names of
+// unmangled properties.
+type propMangler struct {
+	names map[string]string
+}
+
+// newPropMangler allocates names for markers found in the code of the given
+// declarations.
+func newPropMangler(pkgs []*Archive, alive, reflected map[*Decl]struct{}) *propMangler {
+	counts := map[string]int{}
+	count := func(code []byte) {
+		for {
+			i := bytes.Index(code, []byte(propMarkerPrefix))
+			if i == -1 || len(code) < i+propMarkerLen {
+				return
+			}
+			counts[string(code[i:i+propMarkerLen])]++
+			code = code[i+propMarkerLen:]
+		}
+	}
+	for _, pkg := range pkgs {
+		for _, d := range pkg.Declarations {
+			if _, ok := alive[d]; !ok {
+				continue
+			}
+			_, isReflected := reflected[d]
+			methodList, typeInit := d.metadataCode(isReflected)
+			for _, code := range [][]byte{d.TypeDeclCode, d.AnonTypeDeclCode, d.FuncDeclCode, methodList, typeInit, d.InitCode} {
+				count(code)
+			}
+		}
+	}
+
+	markers := make([]string, 0, len(counts))
+	for marker := range counts {
+		markers = append(markers, marker)
+	}
+	sort.Slice(markers, func(i, j int) bool {
+		if counts[markers[i]] != counts[markers[j]] {
+			return counts[markers[i]] > counts[markers[j]]
+		}
+		return markers[i] < markers[j]
+	})
+
+	m := &propMangler{names: make(map[string]string, len(markers))}
+	for _, marker := range markers {
+		m.allocate(marker)
+	}
+	return m
+}
+
+// allocate assigns the next unused short name to the marker.
+func (m *propMangler) allocate(marker string) string {
+	const digits = "0123456789"
+	const chars = digits + "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	n := len(m.names)
+	name := []byte{'$', digits[n%len(digits)]}
+	for n /= len(digits); n > 0; n /= len(chars) {
+		n--
+		name = append(name, chars[n%len(chars)])
+	}
+	m.names[marker] = string(name)
+	return string(name)
+}
+
+// rename returns the code with all markers replaced by their allocated names.
+// Markers that weren't seen by newPropMangler (e.g. in go:linkname glue code)
+// get the next unused name.
+func (m *propMangler) rename(code []byte) []byte {
+	i := bytes.Index(code, []byte(propMarkerPrefix))
+	if i == -1 {
+		return code
+	}
+	out := make([]byte, 0, len(code))
+	for i != -1 && len(code) >= i+propMarkerLen {
+		marker := string(code[i : i+propMarkerLen])
+		name, ok := m.names[marker]
+		if !ok {
+			name = m.allocate(marker)
+		}
+		out = append(append(out, code[:i]...), name...)
+		code = code[i+propMarkerLen:]
+		i = bytes.Index(code, []byte(propMarkerPrefix))
+	}
+	return append(out, code...)
+}
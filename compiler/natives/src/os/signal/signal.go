@@ -2,11 +2,141 @@
 
 package signal
 
-// Package signal is not implemented for GOARCH=js.
+import (
+	"runtime"
+	"syscall"
 
-func signal_disable(uint32) {}
-func signal_enable(uint32)  {}
-func signal_ignore(uint32)  {}
-func signal_recv() uint32   { return 0 }
+	"github.com/gopherjs/gopherjs/js"
+)
 
-func loop() {}
+// Signals are delivered by listeners of the signal events of the process object
+// in Node.js. Elsewhere there are no signals, and the functions below do
+// nothing.
+
+// nodeSignals maps signals to the names of their events in Node.js. SIGKILL
+// can't be caught or ignored.
+var nodeSignals = map[syscall.Signal]string{
+	syscall.SIGCHLD:  "SIGCHLD",
+	syscall.SIGINT:   "SIGINT",
+	syscall.SIGTRAP:  "SIGTRAP",
+	syscall.SIGQUIT:  "SIGQUIT",
+	syscall.SIGTERM:  "SIGTERM",
+	syscall.SIGHUP:   "SIGHUP",
+	syscall.SIGUSR1:  "SIGUSR1",
+	syscall.SIGUSR2:  "SIGUSR2",
+	syscall.SIGPIPE:  "SIGPIPE",
+	syscall.SIGALRM:  "SIGALRM",
+	syscall.SIGWINCH: "SIGWINCH",
+}
+
+var (
+	// listeners holds the listener registered for each signal that is caught
+	// or ignored, which replaces the default action of Node.js.
+	listeners = make(map[uint32]*js.Object)
+	ignored   [numSig]bool
+	caught    [numSig]bool
+
+	// While signals are caught, a timer keeps Node.js running and deadlock
+	// detection is suppressed, since a signal may arrive at any time.
+	numCaught int
+	keepAlive *js.Object
+
+	// received queues caught signals for signal_recv. Like in Go, a signal
+	// that arrives again before it's delivered is delivered once.
+	received   = make(chan uint32, numSig)
+	pending    [numSig]bool
+	delivering bool
+)
+
+// setListener replaces the listener of the signal, or removes it if listener
+// is nil. It reports whether the signal can be handled.
+func setListener(sig uint32, listener *js.Object) bool {
+	name, ok := nodeSignals[syscall.Signal(sig)]
+	if !ok {
+		return false
+	}
+	process := js.Global.Get("process")
+	if process == js.Undefined || process.Get("on") == js.Undefined {
+		return false
+	}
+	if old, ok := listeners[sig]; ok {
+		process.Call("removeListener", name, old)
+		delete(listeners, sig)
+	}
+	if listener != nil {
+		listeners[sig] = listener
+		process.Call("on", name, listener)
+	}
+	return true
+}
+
+func setCaught(sig uint32, c bool) {
+	if caught[sig] == c {
+		return
+	}
+	caught[sig] = c
+	exported := js.Global.Get("$exportedFunctions").Int()
+	if c {
+		numCaught++
+		if numCaught == 1 {
+			keepAlive = js.Global.Call("setInterval", js.MakeFunc(ignore), 1<<30)
+			js.Global.Set("$exportedFunctions", exported+1)
+		}
+		return
+	}
+	numCaught--
+	if numCaught == 0 {
+		js.Global.Call("clearInterval", keepAlive)
+		keepAlive = nil
+		js.Global.Set("$exportedFunctions", exported-1)
+	}
+}
+
+func ignore(this *js.Object, args []*js.Object) any { return nil }
+
+func signal_enable(sig uint32) {
+	listener := js.MakeFunc(func(this *js.Object, args []*js.Object) any {
+		if !pending[sig] {
+			pending[sig] = true
+			received <- sig
+		}
+		return nil
+	})
+	if setListener(sig, listener) {
+		ignored[sig] = false
+		setCaught(sig, true)
+	}
+}
+
+func signal_disable(sig uint32) {
+	if setListener(sig, nil) {
+		ignored[sig] = false
+		setCaught(sig, false)
+	}
+}
+
+func signal_ignore(sig uint32) {
+	if setListener(sig, js.MakeFunc(ignore)) {
+		ignored[sig] = true
+		setCaught(sig, false)
+	}
+}
+
+func signal_ignored(sig uint32) bool {
+	return ignored[sig]
+}
+
+func signal_recv() uint32 {
+	delivering = false
+	js.Global.Set("$waitReason", "syscall")
+	sig := <-received
+	pending[sig] = false
+	delivering = true
+	return sig
+}
+
+func signalWaitUntilIdle() {
+	for delivering || len(received) != 0 {
+		runtime.Gosched()
+	}
+}
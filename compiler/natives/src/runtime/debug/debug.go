@@ -2,7 +2,10 @@
 
 package debug
 
-import "time"
+import (
+	"time"
+	_ "unsafe" // For go:linkname
+)
 
 func setGCPercent(int32) int32 {
 	// Not implemented. Return initial setting.
@@ -37,3 +40,10 @@ func setMemoryLimit(int64) int64 {
 	// Not implemented.
 	return 0
 }
+
+func SetTraceback(level string) {
+	setTraceback(level)
+}
+
+//go:linkname setTraceback runtime.setTraceback
+func setTraceback(level string)
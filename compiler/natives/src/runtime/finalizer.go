@@ -0,0 +1,166 @@
+//go:build js
+
+package runtime
+
+import "github.com/gopherjs/gopherjs/js"
+
+// finalizers is the JavaScript FinalizationRegistry that queues the finalizers
+// of collected objects, or nil if the environment doesn't support it.
+var finalizers *js.Object
+
+// finalizerTargets is the WeakSet of objects that have a finalizer. Collected
+// objects are removed from it by the JavaScript engine.
+var finalizerTargets *js.Object
+
+func init() {
+	if js.Global.Get("FinalizationRegistry") == js.Undefined || js.Global.Get("WeakSet") == js.Undefined {
+		return
+	}
+	finalizers = js.Global.Get("FinalizationRegistry").New(js.InternalObject(queueFinalizer))
+	finalizerTargets = js.Global.Get("WeakSet").New()
+}
+
+// finalizer is a finalizer of a collected object.
+type finalizer struct {
+	fn  *js.Object // The function of the finalizer.
+	arg *js.Object // The argument to call it with.
+}
+
+var (
+	finalizerQueue   []finalizer
+	finalizerRunning bool
+)
+
+// queueFinalizer is called by the FinalizationRegistry after an object was
+// collected. Finalizers run one by one in a goroutine, which is started when
+// they are queued and exits when there are none left.
+func queueFinalizer(held *js.Object) {
+	finalizerQueue = append(finalizerQueue, finalizer{fn: held.Get("fn"), arg: held.Get("arg")})
+	if !finalizerRunning {
+		finalizerRunning = true
+		go runFinalizers()
+	}
+}
+
+func runFinalizers() {
+	for len(finalizerQueue) > 0 {
+		f := finalizerQueue[0]
+		finalizerQueue[0] = finalizer{}
+		finalizerQueue = finalizerQueue[1:]
+
+		// The finalizer is called like a Go function, so that it may block.
+		var call func(arg *js.Object)
+		js.InternalObject(&call).Call("$set", f.fn)
+		call(f.arg)
+	}
+	finalizerRunning = false
+}
+
+// SetFinalizer sets the finalizer associated with obj to the provided
+// finalizer function. When the garbage collector finds an unreachable block
+// with an associated finalizer, it clears the association and runs
+// finalizer(obj) in a separate goroutine. See the upstream SetFinalizer for
+// the complete description.
+//
+// GopherJS implements finalizers with the JavaScript FinalizationRegistry and
+// SetFinalizer does nothing in environments without it. JavaScript doesn't
+// resurrect collected objects, so the finalizer is called with a pointer to a
+// different object that shares the fields of a struct, or the variable, with
+// the collected one. Elements of arrays, except numeric ones, are copied when
+// the finalizer is set. As in Go, an object isn't collected if it's reachable
+// from its finalizer or, in GopherJS, from its fields.
+func SetFinalizer(obj any, finalizer any) {
+	if finalizers == nil {
+		return
+	}
+	if obj == nil {
+		throw("runtime.SetFinalizer: first argument is nil")
+	}
+	x := js.InternalObject(obj)
+	xt := x.Get("constructor")
+	if xt.Get("kind").Int() != js.Global.Get("$kindPtr").Int() {
+		throw("runtime.SetFinalizer: first argument is " + xt.Get("string").String() + ", not pointer")
+	}
+	target := x.Get("$val")
+	if target == xt.Get("nil") {
+		throw("runtime.SetFinalizer: first argument is nil")
+	}
+
+	if finalizer == nil {
+		if finalizerTargets.Call("delete", target).Bool() {
+			finalizers.Call("unregister", target)
+		}
+		return
+	}
+	f := js.InternalObject(finalizer)
+	ft := f.Get("constructor")
+	if ft.Get("kind").Int() != js.Global.Get("$kindFunc").Int() {
+		throw("runtime.SetFinalizer: second argument is " + ft.Get("string").String() + ", not a function")
+	}
+	params := ft.Get("params")
+	if params.Length() != 1 {
+		throw("runtime.SetFinalizer: cannot pass " + xt.Get("string").String() + " to finalizer " + ft.Get("string").String())
+	}
+	pt := params.Index(0)
+	if pt != xt && (pt.Get("kind").Int() != js.Global.Get("$kindInterface").Int() || !js.Global.Call("$assertType", x, pt, true).Index(1).Bool()) {
+		throw("runtime.SetFinalizer: cannot pass " + xt.Get("string").String() + " to finalizer " + ft.Get("string").String())
+	}
+	if finalizerTargets.Call("has", target).Bool() {
+		throw("runtime.SetFinalizer: finalizer already set")
+	}
+
+	arg, boxed := finalizerArg(target, xt)
+	if pt != xt {
+		arg = boxed
+	}
+	finalizerTargets.Call("add", target)
+	// The held value must not reference the target, or it would never be
+	// collected.
+	held := js.Global.Get("Object").New()
+	held.Set("fn", f.Get("$val"))
+	held.Set("arg", arg)
+	finalizers.Call("register", target, held, target)
+}
+
+// finalizerArg returns the argument of the finalizer of the object that the
+// pointer target of type typ points to, as a pointer and as an interface value.
+func finalizerArg(target, typ *js.Object) (arg, boxed *js.Object) {
+	elem := typ.Get("elem")
+	switch elem.Get("kind").Int() {
+	case js.Global.Get("$kindStruct").Int():
+		arg = shareStructFields(target, elem)
+		return arg, arg
+	case js.Global.Get("$kindArray").Int():
+		if target.Get("buffer") != js.Undefined {
+			arg = target.Get("constructor").New(target.Get("buffer"), target.Get("byteOffset"), target.Length())
+		} else {
+			arg = target.Call("slice")
+		}
+		return arg, typ.New(arg)
+	default:
+		arg = typ.New(target.Get("$get"), target.Get("$set"), target.Get("$target"), target.Get("$index"))
+		return arg, arg
+	}
+}
+
+// shareStructFields replaces the fields of the struct object target with
+// accessors of a separate storage, and returns a new object of the same type
+// whose fields are accessors of the same storage.
+func shareStructFields(target, typ *js.Object) *js.Object {
+	object := js.Global.Get("Object")
+	storage := object.New()
+	shared := object.Call("create", typ.Get("ptr").Get("prototype"))
+	shared.Set("$val", shared)
+	fields := typ.Get("fields")
+	for i := 0; i < fields.Length(); i++ {
+		prop := fields.Index(i).Get("prop").String()
+		storage.Set(prop, target.Get(prop))
+		descriptor := object.New()
+		descriptor.Set("get", js.InternalObject(func() *js.Object { return storage.Get(prop) }))
+		descriptor.Set("set", js.InternalObject(func(v *js.Object) { storage.Set(prop, v) }))
+		descriptor.Set("enumerable", true)
+		object.Call("defineProperty", target, prop, descriptor)
+		object.Call("defineProperty", shared, prop, descriptor)
+	}
+	return shared
+}
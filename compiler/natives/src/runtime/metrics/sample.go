@@ -2,7 +2,49 @@
 
 package metrics
 
-import "unsafe"
+import (
+	"runtime"
 
-//gopherjs:replace No-op since runtime does not define metrics yet.
-func runtime_readMetrics(unsafe.Pointer, int, int) {}
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// Read populates each Value field in the given slice of metric samples.
+//
+// GopherJS supports the metrics that can be derived from runtime.ReadMemStats
+// and the scheduler state. Values of all other metrics have the KindBad kind.
+//
+//gopherjs:replace
+func Read(m []Sample) {
+	if len(m) == 0 {
+		return
+	}
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	for i := range m {
+		var v uint64
+		switch m[i].Name {
+		case "/gc/heap/allocs:bytes":
+			v = stats.TotalAlloc
+		case "/gc/heap/allocs:objects":
+			v = stats.Mallocs
+		case "/gc/heap/objects:objects":
+			v = stats.HeapObjects
+		case "/gc/heap/live:bytes", "/memory/classes/heap/objects:bytes":
+			v = stats.HeapAlloc
+		case "/memory/classes/heap/free:bytes":
+			v = stats.HeapIdle
+		case "/memory/classes/other:bytes":
+			v = stats.OtherSys
+		case "/memory/classes/total:bytes":
+			v = stats.Sys
+		case "/sched/goroutines:goroutines":
+			v = uint64(js.Global.Get("$totalGoroutines").Int())
+		case "/sched/gomaxprocs:threads":
+			v = 1
+		default:
+			m[i].Value = Value{}
+			continue
+		}
+		m[i].Value = Value{kind: KindUint64, scalar: v}
+	}
+}
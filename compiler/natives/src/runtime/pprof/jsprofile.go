@@ -0,0 +1,281 @@
+//go:build js
+
+package pprof
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// Tags of the profile.proto messages, see the upstream proto.go.
+const (
+	tagProfile_SampleType        = 1  // repeated ValueType
+	tagProfile_Sample            = 2  // repeated Sample
+	tagProfile_Location          = 4  // repeated Location
+	tagProfile_Function          = 5  // repeated Function
+	tagProfile_StringTable       = 6  // repeated string
+	tagProfile_TimeNanos         = 9  // int64
+	tagProfile_DurationNanos     = 10 // int64
+	tagProfile_PeriodType        = 11 // ValueType (really optional string???)
+	tagProfile_Period            = 12 // int64
+	tagProfile_DefaultSampleType = 14 // int64
+
+	tagValueType_Type = 1 // int64 (string table index)
+	tagValueType_Unit = 2 // int64 (string table index)
+
+	tagSample_Location = 1 // repeated uint64
+	tagSample_Value    = 2 // repeated int64
+
+	tagLocation_ID   = 1 // uint64
+	tagLocation_Line = 4 // repeated Line
+
+	tagLine_FunctionID = 1 // uint64
+	tagLine_Line       = 2 // int64
+
+	tagFunction_ID         = 1 // uint64
+	tagFunction_Name       = 2 // int64 (string table index)
+	tagFunction_SystemName = 3 // int64 (string table index)
+	tagFunction_Filename   = 4 // int64 (string table index)
+)
+
+// inspector is the session of the Node.js inspector used to control the V8
+// profilers in the same process.
+var inspector struct {
+	session *js.Object
+	err     error
+}
+
+// inspectorPost sends a command of the Chrome DevTools Protocol to the
+// inspector session and returns its result. Commands sent to a session in the
+// same thread complete synchronously.
+func inspectorPost(method string, params js.M) (result *js.Object, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			jsErr, ok := e.(*js.Error)
+			if !ok {
+				panic(e)
+			}
+			result, err = nil, fmt.Errorf("pprof: %s failed: %w", method, jsErr)
+		}
+	}()
+
+	if inspector.session == nil && inspector.err == nil {
+		if js.Global.Get("process") == js.Undefined || js.Global.Get("require") == js.Undefined {
+			inspector.err = errors.New("pprof: profiling is only supported in Node.js")
+		} else {
+			inspector.session = js.Global.Call("require", "inspector").Get("Session").New()
+			inspector.session.Call("connect")
+		}
+	}
+	if inspector.err != nil {
+		return nil, inspector.err
+	}
+
+	done := false
+	inspector.session.Call("post", method, params, func(jsErr, r *js.Object) {
+		done = true
+		if jsErr != nil {
+			err = fmt.Errorf("pprof: %s failed: %s", method, jsErr.Get("message"))
+		}
+		result = r
+	})
+	if !done {
+		return nil, fmt.Errorf("pprof: %s didn't complete synchronously", method)
+	}
+	return result, err
+}
+
+// jsLine is a position in the Go or JavaScript source that a location of a
+// profile refers to.
+type jsLine struct {
+	function string
+	file     string
+	line     int64
+}
+
+// jsProfileBuilder writes a profile in the protobuf format from samples of the
+// JavaScript call stack, resolving JavaScript positions into Go functions and
+// lines using the source map of the program.
+type jsProfileBuilder struct {
+	pb        protobuf
+	strings   []string
+	stringMap map[string]int
+	frames    map[string]uint64 // Location IDs by JavaScript position.
+	locs      map[jsLine]uint64 // Location IDs by resolved position.
+	funcs     map[[2]string]uint64
+}
+
+func newJSProfileBuilder() *jsProfileBuilder {
+	return &jsProfileBuilder{
+		strings:   []string{""},
+		stringMap: map[string]int{"": 0},
+		frames:    map[string]uint64{},
+		locs:      map[jsLine]uint64{},
+		funcs:     map[[2]string]uint64{},
+	}
+}
+
+func (b *jsProfileBuilder) stringIndex(s string) int64 {
+	id, ok := b.stringMap[s]
+	if !ok {
+		id = len(b.strings)
+		b.strings = append(b.strings, s)
+		b.stringMap[s] = id
+	}
+	return int64(id)
+}
+
+func (b *jsProfileBuilder) valueType(tag int, typ, unit string) {
+	start := b.pb.startMessage()
+	b.pb.int64(tagValueType_Type, b.stringIndex(typ))
+	b.pb.int64(tagValueType_Unit, b.stringIndex(unit))
+	b.pb.endMessage(tag, start)
+}
+
+// valueTypes writes the types of the values of each sample.
+func (b *jsProfileBuilder) valueTypes(types [][2]string) {
+	for _, t := range types {
+		b.valueType(tagProfile_SampleType, t[0], t[1])
+	}
+}
+
+// sample writes a sample with the given location IDs, leaf first.
+func (b *jsProfileBuilder) sample(locs []uint64, values []int64) {
+	start := b.pb.startMessage()
+	b.pb.int64s(tagSample_Value, values)
+	b.pb.uint64s(tagSample_Location, locs)
+	b.pb.endMessage(tagProfile_Sample, start)
+}
+
+// location returns the ID of the location of a call frame of a V8 profile,
+// writing the location and its function if they are new.
+func (b *jsProfileBuilder) location(callFrame *js.Object) uint64 {
+	name := callFrame.Get("functionName").String()
+	url := callFrame.Get("url").String()
+	if strings.HasPrefix(url, "file:") {
+		// Resolve the sources of the source map as paths like in stack traces.
+		url = js.Global.Call("require", "url").Call("fileURLToPath", url).String()
+	}
+	// Positions in V8 profiles are 0-based, and in stack traces 1-based.
+	pos := url + ":" + strconv.Itoa(callFrame.Get("lineNumber").Int()+1) + ":" + strconv.Itoa(callFrame.Get("columnNumber").Int()+1)
+	if id, ok := b.frames[name+" "+pos]; ok {
+		return id
+	}
+
+	l := jsLine{function: name}
+	if url != "" {
+		frame := js.Global.Call("$resolveCallFrame", "    at "+name+" ("+pos+")")
+		l = jsLine{function: frame.Index(0).String(), file: frame.Index(1).String(), line: frame.Index(2).Int64()}
+	}
+	if l.function == "" {
+		l.function = "(anonymous)"
+	}
+	id, ok := b.locs[l]
+	if !ok {
+		id = uint64(len(b.locs) + 1)
+		b.locs[l] = id
+
+		fn := [2]string{l.function, l.file}
+		funcID, ok := b.funcs[fn]
+		if !ok {
+			funcID = uint64(len(b.funcs) + 1)
+			b.funcs[fn] = funcID
+			start := b.pb.startMessage()
+			b.pb.uint64Opt(tagFunction_ID, funcID)
+			b.pb.int64Opt(tagFunction_Name, b.stringIndex(l.function))
+			b.pb.int64Opt(tagFunction_SystemName, b.stringIndex(l.function))
+			b.pb.int64Opt(tagFunction_Filename, b.stringIndex(l.file))
+			b.pb.endMessage(tagProfile_Function, start)
+		}
+
+		start := b.pb.startMessage()
+		b.pb.uint64Opt(tagLocation_ID, id)
+		lineStart := b.pb.startMessage()
+		b.pb.uint64Opt(tagLine_FunctionID, funcID)
+		b.pb.int64Opt(tagLine_Line, l.line)
+		b.pb.endMessage(tagLocation_Line, lineStart)
+		b.pb.endMessage(tagProfile_Location, start)
+	}
+	b.frames[name+" "+pos] = id
+	return id
+}
+
+// build writes the complete profile to w, compressed with gzip.
+func (b *jsProfileBuilder) build(w io.Writer, start time.Time, duration time.Duration, periodType [2]string, period int64, defaultSampleType string) error {
+	b.pb.int64Opt(tagProfile_TimeNanos, start.UnixNano())
+	b.pb.int64Opt(tagProfile_DurationNanos, int64(duration))
+	b.valueType(tagProfile_PeriodType, periodType[0], periodType[1])
+	b.pb.int64Opt(tagProfile_Period, period)
+	if defaultSampleType != "" {
+		b.pb.int64Opt(tagProfile_DefaultSampleType, b.stringIndex(defaultSampleType))
+	}
+	b.pb.strings(tagProfile_StringTable, b.strings)
+
+	zw, _ := gzip.NewWriterLevel(w, gzip.BestSpeed)
+	if _, err := zw.Write(b.pb.data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// jsCallTree is the call tree of a V8 CPU or heap profile, whose nodes are
+// identified by IDs.
+type jsCallTree struct {
+	b      *jsProfileBuilder
+	order  []int          // Node IDs in the order they were added.
+	parent map[int]int    // Parent node IDs.
+	locs   map[int]uint64 // Location IDs, missing for the root node.
+	idle   map[int]bool   // Nodes of the idle time in CPU profiles.
+}
+
+func newJSCallTree(b *jsProfileBuilder) *jsCallTree {
+	return &jsCallTree{b: b, parent: map[int]int{}, locs: map[int]uint64{}, idle: map[int]bool{}}
+}
+
+// add adds a node of a profile to the tree. Children of CPU profile nodes are
+// node IDs, and children of heap profile nodes are nodes.
+func (t *jsCallTree) add(node *js.Object) {
+	id := node.Get("id").Int()
+	t.order = append(t.order, id)
+	callFrame := node.Get("callFrame")
+	switch callFrame.Get("functionName").String() {
+	case "(root)":
+	case "(idle)":
+		t.idle[id] = true
+	default:
+		t.locs[id] = t.b.location(callFrame)
+	}
+	children := node.Get("children")
+	if children == js.Undefined {
+		return
+	}
+	for i := 0; i < children.Length(); i++ {
+		child := children.Index(i)
+		if childID := child.Get("id"); childID != js.Undefined {
+			child = childID
+		}
+		t.parent[child.Int()] = id
+	}
+}
+
+// stack returns the location IDs of the stack of a node, leaf first.
+func (t *jsCallTree) stack(id int) []uint64 {
+	var locs []uint64
+	for {
+		if loc, ok := t.locs[id]; ok {
+			locs = append(locs, loc)
+		}
+		parent, ok := t.parent[id]
+		if !ok {
+			return locs
+		}
+		id = parent
+	}
+}
@@ -3,8 +3,15 @@
 package pprof
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"math"
+	"runtime"
 	"sync"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
 )
 
 type Profile struct {
@@ -15,16 +22,27 @@ type Profile struct {
 	write func(io.Writer, int) error
 }
 
+// WriteTo writes a pprof-formatted snapshot of the profile to w. Only the
+// protobuf format (debug=0) is supported by GopherJS.
 func (p *Profile) WriteTo(w io.Writer, debug int) error {
-	return nil
+	if p == nil || p.write == nil {
+		return nil
+	}
+	return p.write(w, debug)
 }
 
 func (p *Profile) Count() int {
-	return 0
+	if p == nil || p.count == nil {
+		return 0
+	}
+	return p.count()
 }
 
 func (p *Profile) Name() string {
-	return ""
+	if p == nil {
+		return ""
+	}
+	return p.name
 }
 
 func (p *Profile) Add(value any, skip int) {
@@ -33,17 +51,232 @@ func (p *Profile) Add(value any, skip int) {
 func (p *Profile) Remove(value any) {
 }
 
+var heapProfile = &Profile{
+	name:  "heap",
+	count: countHeap,
+	write: writeHeap,
+}
+
+var allocsProfile = &Profile{
+	name:  "allocs",
+	count: countHeap, // identical to heap profile
+	write: writeAlloc,
+}
+
+// Lookup returns the profile with the given name, or nil if no such profile
+// exists. GopherJS supports the "heap" and "allocs" profiles in Node.js, which
+// are sampled by the V8 heap profiler.
+func Lookup(name string) *Profile {
+	switch name {
+	case "heap":
+		return heapProfile
+	case "allocs":
+		return allocsProfile
+	}
+	return nil
+}
+
+var cpu struct {
+	sync.Mutex
+	profiling bool
+}
+
+// StartCPUProfile enables CPU profiling for the current process.
+// While profiling, the profile will be buffered and written to w.
+// StartCPUProfile returns an error if profiling is already enabled.
+//
+// GopherJS samples the JavaScript stack with the V8 profiler of Node.js, so
+// StartCPUProfile returns an error in other environments. The stacks are
+// translated into Go functions and lines using the source map of the program.
 func StartCPUProfile(w io.Writer) error {
+	// Same as the Go runtime, see the upstream StartCPUProfile.
+	const hz = 100
+
+	cpu.Lock()
+	defer cpu.Unlock()
+	if cpu.profiling {
+		return fmt.Errorf("cpu profiling already in use")
+	}
+	if _, err := inspectorPost("Profiler.enable", nil); err != nil {
+		return err
+	}
+	if _, err := inspectorPost("Profiler.setSamplingInterval", js.M{"interval": 1000000 / hz}); err != nil {
+		return err
+	}
+	if _, err := inspectorPost("Profiler.start", nil); err != nil {
+		return err
+	}
+	cpu.profiling = true
+	cpuProfile.w = w
+	cpuProfile.period = int64(time.Second / hz)
+	cpuProfile.start = time.Now()
 	return nil
 }
 
+// cpuProfile holds the state of the CPU profile in progress.
+var cpuProfile struct {
+	w      io.Writer
+	period int64 // Sampling period in nanoseconds.
+	start  time.Time
+}
+
+// StopCPUProfile stops the current CPU profile, if any.
+// StopCPUProfile only returns after all the writes for the
+// profile have completed.
 func StopCPUProfile() {
+	cpu.Lock()
+	defer cpu.Unlock()
+
+	if !cpu.profiling {
+		return
+	}
+	cpu.profiling = false
+	result, err := inspectorPost("Profiler.stop", nil)
+	inspectorPost("Profiler.disable", nil)
+	if err != nil {
+		return
+	}
+	writeCPUProfile(cpuProfile.w, result.Get("profile"), cpuProfile.start, cpuProfile.period)
+}
+
+// writeCPUProfile converts a V8 CPU profile into the protobuf format. Each
+// sample is attributed the sampling period, like the Go runtime does.
+func writeCPUProfile(w io.Writer, profile *js.Object, start time.Time, period int64) error {
+	b := newJSProfileBuilder()
+	tree := newJSCallTree(b)
+	nodes := profile.Get("nodes")
+	for i := 0; i < nodes.Length(); i++ {
+		tree.add(nodes.Index(i))
+	}
+
+	samples := profile.Get("samples")
+	counts := map[int]int64{}
+	for i := 0; i < samples.Length(); i++ {
+		counts[samples.Index(i).Int()]++
+	}
+	b.valueTypes([][2]string{{"samples", "count"}, {"cpu", "nanoseconds"}})
+	for _, id := range tree.order {
+		if n := counts[id]; n != 0 && !tree.idle[id] {
+			b.sample(tree.stack(id), []int64{n, n * period})
+		}
+	}
+
+	// The start and end times of V8 profiles are in microseconds.
+	duration := time.Duration(profile.Get("endTime").Int64()-profile.Get("startTime").Int64()) * time.Microsecond
+	return b.build(w, start, duration, [2]string{"cpu", "nanoseconds"}, period, "")
 }
 
+// heapSampling is true if the V8 heap profiler samples allocations.
+var heapSampling bool
+
+func init() {
+	// Like the Go runtime, sample heap allocations from the start with the
+	// default rate, so that the heap profile covers the whole program.
+	if runtime.MemProfileRate <= 0 {
+		return
+	}
+	if _, err := inspectorPost("HeapProfiler.startSampling", js.M{"samplingInterval": runtime.MemProfileRate}); err == nil {
+		heapSampling = true
+	}
+}
+
+// heapSamples returns the call tree and the number and total size of sampled
+// live objects allocated by each node of the tree.
+func heapSamples(b *jsProfileBuilder) (*jsCallTree, map[int][2]int64) {
+	tree := newJSCallTree(b)
+	stats := map[int][2]int64{}
+	if !heapSampling {
+		return tree, stats
+	}
+	// Collect garbage first, so that the profile reflects the objects in use,
+	// like the Go heap profile reflects the most recently completed GC.
+	inspectorPost("HeapProfiler.collectGarbage", nil)
+	result, err := inspectorPost("HeapProfiler.getSamplingProfile", nil)
+	if err != nil {
+		return tree, stats
+	}
+	profile := result.Get("profile")
+	var walk func(node *js.Object)
+	walk = func(node *js.Object) {
+		tree.add(node)
+		children := node.Get("children")
+		for i := 0; i < children.Length(); i++ {
+			walk(children.Index(i))
+		}
+	}
+	walk(profile.Get("head"))
+
+	samples := profile.Get("samples")
+	for i := 0; i < samples.Length(); i++ {
+		sample := samples.Index(i)
+		id := sample.Get("nodeId").Int()
+		s := stats[id]
+		stats[id] = [2]int64{s[0] + 1, s[1] + sample.Get("size").Int64()}
+	}
+	return tree, stats
+}
+
+// WriteHeapProfile is shorthand for Lookup("heap").WriteTo(w, 0).
+// It is preserved for backwards compatibility.
 func WriteHeapProfile(w io.Writer) error {
-	return nil
+	return writeHeap(w, 0)
 }
 
-func Lookup(name string) *Profile {
-	return nil
+// countHeap returns the number of records in the heap profile.
+func countHeap() int {
+	_, stats := heapSamples(newJSProfileBuilder())
+	return len(stats)
+}
+
+// writeHeap writes the current runtime heap profile to w.
+func writeHeap(w io.Writer, debug int) error {
+	return writeHeapInternal(w, debug, "")
+}
+
+// writeAlloc writes the current runtime heap profile to w
+// with the total allocation space as the default sample type.
+func writeAlloc(w io.Writer, debug int) error {
+	return writeHeapInternal(w, debug, "alloc_space")
+}
+
+// writeHeapInternal writes the heap profile sampled by the V8 heap profiler.
+// JavaScript doesn't report when objects are freed, so only objects that are
+// still alive are sampled, and the alloc_* values equal the inuse_* ones.
+// Contents of byte slices and other typed arrays are stored outside of the
+// JavaScript heap and aren't sampled either.
+func writeHeapInternal(w io.Writer, debug int, defaultSampleType string) error {
+	if debug != 0 {
+		return errors.New("pprof: GopherJS supports only the protobuf format of the heap profile")
+	}
+	rate := int64(runtime.MemProfileRate)
+	b := newJSProfileBuilder()
+	tree, stats := heapSamples(b)
+	b.valueTypes([][2]string{{"alloc_objects", "count"}, {"alloc_space", "bytes"}, {"inuse_objects", "count"}, {"inuse_space", "bytes"}})
+	for _, id := range tree.order {
+		if s, ok := stats[id]; ok {
+			objects, bytes := scaleHeapSample(s[0], s[1], rate)
+			b.sample(tree.stack(id), []int64{objects, bytes, objects, bytes})
+		}
+	}
+	return b.build(w, time.Now(), 0, [2]string{"space", "bytes"}, rate, defaultSampleType)
+}
+
+// scaleHeapSample adjusts the data from a heap Sample to
+// account for its probability of appearing in the collected
+// data. See the upstream protomem.go.
+func scaleHeapSample(count, size, rate int64) (int64, int64) {
+	if count == 0 || size == 0 {
+		return 0, 0
+	}
+
+	if rate <= 1 {
+		// if rate==1 all samples were collected so no adjustment is needed.
+		// if rate<1 treat as unknown and skip scaling.
+		return count, size
+	}
+
+	avgSize := float64(size) / float64(count)
+	scale := 1 / (1 - math.Exp(-avgSize/float64(rate)))
+
+	return int64(float64(count) * scale), int64(float64(size) * scale)
 }
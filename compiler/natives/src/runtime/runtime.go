@@ -94,10 +94,17 @@ func init() {
 	buildVersion = js.Global.Get("$goVersion").String()
 	// Prepare the prelude's $panicnil flag from GODEBUG at startup.
 	syncPanicNilFromGodebug(getEnvString(godebugEnvKey))
-	// avoid dead code elimination
+	setTraceback(getEnvString("GOTRACEBACK"))
+	tracebackEnvAll = tracebackAll
+	// avoid dead code elimination and reflection metadata stripping of the
+	// types the prelude creates values of
 	var e error
 	e = &TypeAssertionError{}
+	e = &PanicNilError{}
+	e = &js.Error{}
 	_ = e
+	var o any = js.Global
+	_ = o
 }
 
 func GOROOT() string {
@@ -163,8 +170,15 @@ func callstack(skip, limit int) []basicFrame {
 	}
 
 	skip = skip + 2 // skip callstack's own frame and $callstack's frame
-	lines := js.Global.Call("$callstack", skip, limit)
-	return parseCallstack(lines, limit)
+	// Hidden frames don't count towards the limit, so more of the stack is
+	// captured if too few frames remain after they are filtered out.
+	for n := limit; ; n *= 2 {
+		lines := js.Global.Call("$callstack", skip, n)
+		frames := parseCallstack(lines, limit)
+		if len(frames) >= limit || lines.Length() < n {
+			return frames
+		}
+	}
 }
 
 var (
@@ -172,14 +186,17 @@ var (
 	// upstream Go runtime. To improve interoperability, we filter them out from
 	// the stack trace.
 	hiddenFrames = map[string]bool{
-		"$callDeferred": true,
+		"$callDeferred":  true,
+		"$deferredCalls": true,
+		"$initPackages":  true,
 	}
 	// The following GopherJS prelude functions have differently-named
 	// counterparts in the upstream Go runtime. Some standard library code relies
 	// on the names matching, so we perform this substitution.
 	knownFrames = map[string]string{
-		"$panic":     "runtime.gopanic",
-		"$goroutine": "runtime.goexit",
+		"$panic":             "runtime.gopanic",
+		"$panicDeferredCall": "runtime.gopanic",
+		"$goroutine":         "runtime.goexit",
 	}
 )
 
@@ -188,9 +205,11 @@ func parseCallstack(lines *js.Object, limit int) []basicFrame {
 	frames := []basicFrame{}
 	l := lines.Length()
 	for i := 0; i < l; i++ {
-		frame := js.Global.Call("$parseCallFrame", lines.Index(i))
+		// Resolve the frame to the Go function and source position using the
+		// program's source map, if available.
+		frame := js.Global.Call("$resolveCallFrame", lines.Index(i))
 		funcName := frame.Index(0).String()
-		if hiddenFrames[funcName] {
+		if hiddenFrames[funcName] || isGeneratorResumption(funcName, frame.Index(1).String()) {
 			continue
 		}
 		if alias, ok := knownFrames[funcName]; ok {
@@ -212,6 +231,14 @@ func parseCallstack(lines *js.Object, limit int) []basicFrame {
 	return frames
 }
 
+// isGeneratorResumption reports whether a stack frame is the next() method of a
+// generator, through which blocking functions are resumed with generator
+// code generation.
+func isGeneratorResumption(funcName, file string) bool {
+	const suffix = ".next"
+	return file == "<anonymous>" && len(funcName) > len(suffix) && funcName[len(funcName)-len(suffix):] == suffix
+}
+
 func Caller(skip int) (pc uintptr, file string, line int, ok bool) {
 	skip = skip + 1 /*skip Caller's own frame*/
 	frames := callstack(skip, 1)
@@ -303,11 +330,16 @@ type Frame struct {
 	Entry    uintptr
 }
 
-func GC() {}
+// GC runs a garbage collection if the JavaScript engine exposes it, e.g. with
+// the --expose-gc flag of Node.js, which GopherJS passes to it.
+func GC() {
+	if gc := js.Global.Get("gc"); gc != js.Undefined {
+		gc.Invoke()
+	}
+}
 
 func Goexit() {
-	js.Global.Get("$curGoroutine").Set("exit", true)
-	js.Global.Call("$throw", nil)
+	js.Global.Call("$goexit")
 }
 
 func GOMAXPROCS(int) int { return 1 }
@@ -374,14 +406,41 @@ type MemStats struct {
 	}
 }
 
+// ReadMemStats populates m with memory allocator statistics.
+//
+// The heap sizes are reported by the JavaScript host: process.memoryUsage() in
+// Node.js and performance.memory in browsers that support it. Allocations of
+// structs, slices and maps are counted by the GopherJS runtime once the first
+// call enables counting, so the counts are only meaningful as differences
+// between calls. Frees are invisible to JavaScript and are never counted.
 func ReadMemStats(m *MemStats) {
-	// TODO(nevkontakte): This function is effectively unimplemented and may
-	// lead to silent unexpected behaviors. Consider panicing explicitly.
-}
-
-func SetFinalizer(x, f any) {
-	// TODO(nevkontakte): This function is effectively unimplemented and may
-	// lead to silent unexpected behaviors. Consider panicing explicitly.
+	mallocs, allocBytes := js.Global.Get("$mallocs"), js.Global.Get("$allocBytes")
+	*m = MemStats{EnableGC: true}
+	// Zeroing m constructs its nested structs, which the program didn't allocate.
+	js.Global.Set("$mallocs", mallocs)
+	js.Global.Set("$allocBytes", allocBytes)
+	// Allocations are counted from the first call on, see $countAllocs.
+	js.Global.Set("$countAllocs", true)
+	m.Mallocs = uint64(mallocs.Int64())
+	m.TotalAlloc = uint64(allocBytes.Int64())
+	m.HeapObjects = m.Mallocs - m.Frees
+
+	usage := js.Global.Call("$memoryUsage")
+	if usage == js.Undefined {
+		return
+	}
+	m.HeapAlloc = uint64(usage.Get("heapUsed").Int64())
+	m.Alloc = m.HeapAlloc
+	m.HeapInuse = m.HeapAlloc
+	m.HeapSys = uint64(usage.Get("heapTotal").Int64())
+	if m.HeapSys > m.HeapInuse {
+		m.HeapIdle = m.HeapSys - m.HeapInuse
+	}
+	m.Sys = uint64(usage.Get("sys").Int64())
+	if m.Sys < m.HeapSys {
+		m.Sys = m.HeapSys
+	}
+	m.OtherSys = m.Sys - m.HeapSys
 }
 
 type Func struct {
@@ -445,14 +504,37 @@ func SetMutexProfileFraction(rate int) int {
 // number of bytes written to buf. If all is true, Stack formats stack traces of
 // all other goroutines into buf after the trace for the current goroutine.
 //
-// Unlike runtime.Callers(), it returns an unprocessed, runtime-specific text
-// representation of the JavaScript stack trace.
+// Like with the gc runtime, the trace of each goroutine starts with its state,
+// e.g. the channel operation it's blocked on, and ends with the location of
+// the go statement that created it. The frames of goroutines that aren't
+// running are the ones at the time they blocked, which goroutines only record
+// with GOTRACEBACK=all or debug.SetTraceback("all") and higher levels, since
+// recording them slows down blocking operations and go statements.
 func Stack(buf []byte, all bool) int {
-	s := js.Global.Get("Error").New().Get("stack")
-	if s == js.Undefined {
-		return 0
+	// Skip the frame of Stack itself.
+	return copy(buf, js.Global.Call("$stackDump", all, 1).String())
+}
+
+var (
+	// tracebackAll is whether the traceback level is "all" or higher, at which
+	// goroutines record their stack traces for the tracebacks of all
+	// goroutines, see $goroutineStacks in the prelude.
+	tracebackAll bool
+	// tracebackEnvAll is tracebackAll for the GOTRACEBACK environment
+	// variable, which setTraceback can't lower.
+	tracebackEnvAll bool
+)
+
+// setTraceback implements runtime/debug.SetTraceback, which refers to it with
+// a go:linkname directive in its natives.
+func setTraceback(level string) {
+	switch level {
+	case "all", "system", "crash", "wer", "2":
+		tracebackAll = true
+	default:
+		tracebackAll = tracebackEnvAll
 	}
-	return copy(buf, s.Call("substring", s.Call("indexOf", "\n").Int()+1).String())
+	js.Global.Set("$goroutineStacks", tracebackAll)
 }
 
 func LockOSThread() {}
@@ -465,10 +547,6 @@ func Version() string {
 	return buildVersion
 }
 
-func StartTrace() error { return nil }
-func StopTrace()        {}
-func ReadTrace() []byte
-
 // We fake a cgo environment to catch errors. Therefore we have to implement this and always return 0
 func NumCgoCall() int64 {
 	return 0
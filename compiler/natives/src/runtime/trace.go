@@ -0,0 +1,338 @@
+//go:build js
+
+package runtime
+
+import "github.com/gopherjs/gopherjs/js"
+
+// tracer is the state of the execution tracer while tracing is enabled.
+type tracer struct {
+	data chan []byte   // Receives the trace and then nil when tracing stops.
+	done chan struct{} // Closed when the reader has received the whole trace.
+}
+
+var activeTracer *tracer
+
+// StartTrace enables tracing for the current process. While tracing, the
+// data is buffered and available via ReadTrace. StartTrace returns an error if
+// tracing is already enabled. Most clients should use the runtime/trace
+// package or the testing package's -test.trace flag instead of calling
+// StartTrace directly.
+//
+// GopherJS records the events of its goroutine scheduler and the user
+// annotations of runtime/trace, and the trace becomes available once tracing
+// is stopped.
+func StartTrace() error {
+	if activeTracer != nil {
+		return errorString("tracing is already enabled")
+	}
+	activeTracer = &tracer{data: make(chan []byte, 2), done: make(chan struct{})}
+	js.Global.Call("$startTrace")
+	return nil
+}
+
+// StopTrace stops tracing, if it was previously enabled. StopTrace only
+// returns after all the reads for the trace have completed.
+func StopTrace() {
+	t := activeTracer
+	if t == nil {
+		return
+	}
+	events := js.Global.Call("$stopTrace")
+	t.data <- encodeTrace(events)
+	t.data <- nil
+	<-t.done
+}
+
+// ReadTrace returns the next chunk of binary tracing data, blocking until data
+// is available. If tracing is turned off and all the data accumulated while it
+// was on has been returned, ReadTrace returns nil. The caller must copy the
+// returned data before calling ReadTrace again. ReadTrace must be called from
+// one goroutine at a time.
+func ReadTrace() []byte {
+	t := activeTracer
+	if t == nil {
+		return nil
+	}
+	if data := <-t.data; data != nil {
+		return data
+	}
+	activeTracer = nil
+	close(t.done)
+	return nil
+}
+
+// traceEnabled reports whether the prelude records events for the tracer.
+func traceEnabled() bool {
+	return js.Global.Get("$traceEvents") != nil
+}
+
+// traceUserEvent records an event of the runtime/trace annotations with the
+// stack trace of its caller.
+func traceUserEvent(kind string, args ...any) {
+	if !traceEnabled() {
+		return
+	}
+	event := append([]any{kind, js.Global.Call("$traceClock")}, args...)
+	event = append(event, js.Global.Call("$captureStack", 100))
+	js.Global.Get("$traceEvents").Call("push", event)
+}
+
+// The functions below implement the annotations of runtime/trace, which refers
+// to them with go:linkname directives in its natives.
+
+func trace_userTaskCreate(id, parentID uint64, taskType string) {
+	traceUserEvent("task", float64(id), float64(parentID), taskType)
+}
+
+func trace_userTaskEnd(id uint64) {
+	traceUserEvent("taskEnd", float64(id))
+}
+
+func trace_userRegion(id, mode uint64, regionType string) {
+	traceUserEvent("region", float64(id), float64(mode), regionType)
+}
+
+func trace_userLog(id uint64, category, message string) {
+	traceUserEvent("log", float64(id), category, message)
+}
+
+// Event types of the execution tracer, see internal/trace.
+const (
+	traceEvBatch          = 1  // [pid, timestamp]
+	traceEvFrequency      = 2  // [ticks per second]
+	traceEvStack          = 3  // [stack id, number of frames, {pc, func string id, file string id, line}...]
+	traceEvGomaxprocs     = 4  // [timestamp, GOMAXPROCS, stack id]
+	traceEvProcStart      = 5  // [timestamp, thread id]
+	traceEvGoCreate       = 13 // [timestamp, new goroutine id, new stack id, stack id]
+	traceEvGoEnd          = 15 // [timestamp]
+	traceEvGoSched        = 17 // [timestamp, stack]
+	traceEvGoPreempt      = 18 // [timestamp, stack]
+	traceEvGoSleep        = 19 // [timestamp, stack]
+	traceEvGoBlock        = 20 // [timestamp, stack]
+	traceEvGoBlockSend    = 22 // [timestamp, stack]
+	traceEvGoBlockRecv    = 23 // [timestamp, stack]
+	traceEvGoBlockSelect  = 24 // [timestamp, stack]
+	traceEvGoBlockSync    = 25 // [timestamp, stack]
+	traceEvGoBlockCond    = 26 // [timestamp, stack]
+	traceEvGoWaiting      = 31 // [timestamp, goroutine id]
+	traceEvString         = 37 // [id, length, string]
+	traceEvGoStartLocal   = 38 // [timestamp, goroutine id]
+	traceEvGoUnblockLocal = 39 // [timestamp, goroutine id, stack]
+	traceEvUserTaskCreate = 45 // [timestamp, task id, parent task id, name string id, stack]
+	traceEvUserTaskEnd    = 46 // [timestamp, task id, stack]
+	traceEvUserRegion     = 47 // [timestamp, task id, mode, name string id, stack]
+	traceEvUserLog        = 48 // [timestamp, task id, key string id, stack], value string
+)
+
+// traceBlockEvent returns the type of the event for a goroutine that blocks
+// for the given wait reason.
+func traceBlockEvent(reason string) byte {
+	switch reason {
+	case "runnable":
+		return traceEvGoPreempt
+	case "sleep":
+		return traceEvGoSleep
+	case "chan send", "chan send (nil chan)":
+		return traceEvGoBlockSend
+	case "chan receive", "chan receive (nil chan)":
+		return traceEvGoBlockRecv
+	case "select", "select (no cases)":
+		return traceEvGoBlockSelect
+	case "semacquire", "sync.Mutex.Lock", "sync.RWMutex.RLock", "sync.RWMutex.Lock", "sync.WaitGroup.Wait":
+		return traceEvGoBlockSync
+	case "sync.Cond.Wait":
+		return traceEvGoBlockCond
+	default:
+		return traceEvGoBlock
+	}
+}
+
+// traceFrame is a frame of a stack trace in the trace.
+type traceFrame struct {
+	fn, file string
+	line     int
+}
+
+// traceWriter encodes events in the format of the Go 1.21 execution tracer,
+// which all events are written to as a single batch of processor 0. Stack
+// frames are given unique made up PCs, since JavaScript has no such thing.
+type traceWriter struct {
+	buf     []byte
+	dict    []byte // String and stack events, written after all the others.
+	lastTs  int64
+	strings map[string]uint64
+	stacks  map[string]uint64
+	pcs     map[traceFrame]uint64
+}
+
+func (w *traceWriter) varint(buf []byte, v uint64) []byte {
+	for ; v >= 0x80; v >>= 7 {
+		buf = append(buf, 0x80|byte(v))
+	}
+	return append(buf, byte(v))
+}
+
+// write appends an event with the given arguments to buf. Events with more
+// than three arguments are prefixed by the length of their arguments.
+func (w *traceWriter) write(buf []byte, typ byte, args ...uint64) []byte {
+	if len(args) <= 3 {
+		buf = append(buf, typ|byte(len(args)-1)<<6)
+		for _, a := range args {
+			buf = w.varint(buf, a)
+		}
+		return buf
+	}
+	var encoded []byte
+	for _, a := range args {
+		encoded = w.varint(encoded, a)
+	}
+	buf = append(buf, typ|3<<6)
+	buf = w.varint(buf, uint64(len(encoded)))
+	return append(buf, encoded...)
+}
+
+// event appends an event that happened at the given time in nanoseconds. The
+// timestamps of the events must not decrease, so earlier times are clamped.
+func (w *traceWriter) event(typ byte, ts int64, args ...uint64) {
+	if ts < w.lastTs {
+		ts = w.lastTs
+	}
+	w.buf = w.write(w.buf, typ, append([]uint64{uint64(ts - w.lastTs)}, args...)...)
+	w.lastTs = ts
+}
+
+// str returns the id of the string s in the string dictionary of the trace.
+func (w *traceWriter) str(s string) uint64 {
+	if s == "" {
+		return 0
+	}
+	id, ok := w.strings[s]
+	if !ok {
+		id = uint64(len(w.strings) + 1)
+		w.strings[s] = id
+		w.dict = w.write(w.dict, traceEvString, id, uint64(len(s)))
+		w.dict = append(w.dict, s...)
+	}
+	return id
+}
+
+// stack returns the id of the given stack trace in the trace, or 0 if it's
+// empty.
+func (w *traceWriter) stack(frames []traceFrame) uint64 {
+	if len(frames) == 0 {
+		return 0
+	}
+	var key []byte
+	pcs := make([]uint64, len(frames))
+	for i, f := range frames {
+		pc, ok := w.pcs[f]
+		if !ok {
+			pc = uint64(len(w.pcs)+1) * 0x10
+			w.pcs[f] = pc
+		}
+		pcs[i] = pc
+		key = w.varint(key, pc)
+	}
+	id, ok := w.stacks[string(key)]
+	if ok {
+		return id
+	}
+	id = uint64(len(w.stacks) + 1)
+	w.stacks[string(key)] = id
+	args := []uint64{id, uint64(len(frames))}
+	for i, f := range frames {
+		args = append(args, pcs[i], w.str(f.fn), w.str(f.file), uint64(f.line))
+	}
+	w.dict = w.write(w.dict, traceEvStack, args...)
+	return id
+}
+
+// traceFrames returns the Go frames of the stack trace captured by the error
+// err, except the ones of runtime/trace itself.
+func traceFrames(err *js.Object) []traceFrame {
+	if err == js.Undefined || err == nil {
+		return nil
+	}
+	resolved := js.Global.Call("$goFrames", err.Get("stack"))
+	var frames []traceFrame
+	for i := 0; i < resolved.Length(); i++ {
+		f := resolved.Index(i)
+		fn := f.Index(0).String()
+		if len(frames) == 0 && len(fn) > len("runtime/trace.") && fn[:len("runtime/trace.")] == "runtime/trace." {
+			continue
+		}
+		frames = append(frames, traceFrame{fn: fn, file: f.Index(1).String(), line: f.Index(2).Int()})
+	}
+	return frames
+}
+
+// encodeTrace encodes the events recorded by the prelude.
+func encodeTrace(events *js.Object) []byte {
+	const nanosPerMilli = 1e6
+	w := &traceWriter{
+		strings: map[string]uint64{},
+		stacks:  map[string]uint64{},
+		pcs:     map[traceFrame]uint64{},
+	}
+
+	// The entry of a goroutine is the bottom frame of any of its stack traces.
+	entries := map[uint64][]traceFrame{}
+	frames := make([][]traceFrame, events.Length())
+	var running uint64
+	for i := range frames {
+		ev := events.Index(i)
+		switch ev.Index(0).String() {
+		case "start":
+			running = ev.Index(2).Uint64()
+		case "block":
+			frames[i] = traceFrames(ev.Index(3))
+			if n := len(frames[i]); n > 0 && entries[running] == nil {
+				entries[running] = frames[i][n-1:]
+			}
+		}
+	}
+
+	w.buf = append(w.buf, "go 1.21 trace\x00\x00\x00"...)
+	start := int64(0)
+	if events.Length() > 0 {
+		start = int64(events.Index(0).Index(1).Float() * nanosPerMilli)
+	}
+	w.buf = w.write(w.buf, traceEvBatch, 0, uint64(start))
+	w.lastTs = start
+	w.buf = w.write(w.buf, traceEvFrequency, 1e9)
+	w.event(traceEvGomaxprocs, start, 1, 0)
+	w.event(traceEvProcStart, start, 0)
+	for i := 0; i < events.Length(); i++ {
+		ev := events.Index(i)
+		ts := int64(ev.Index(1).Float() * nanosPerMilli)
+		switch ev.Index(0).String() {
+		case "create":
+			g := ev.Index(2).Uint64()
+			w.event(traceEvGoCreate, ts, g, w.stack(entries[g]), w.stack(traceFrames(ev.Index(3))))
+		case "waiting":
+			w.event(traceEvGoWaiting, ts, ev.Index(2).Uint64())
+		case "start":
+			w.event(traceEvGoStartLocal, ts, ev.Index(2).Uint64())
+		case "block":
+			w.event(traceBlockEvent(ev.Index(2).String()), ts, w.stack(frames[i]))
+		case "unblock":
+			w.event(traceEvGoUnblockLocal, ts, ev.Index(2).Uint64(), 0)
+		case "sched":
+			w.event(traceEvGoSched, ts, 0)
+		case "end":
+			w.event(traceEvGoEnd, ts)
+		case "task":
+			w.event(traceEvUserTaskCreate, ts, ev.Index(2).Uint64(), ev.Index(3).Uint64(), w.str(ev.Index(4).String()), w.stack(traceFrames(ev.Index(5))))
+		case "taskEnd":
+			w.event(traceEvUserTaskEnd, ts, ev.Index(2).Uint64(), w.stack(traceFrames(ev.Index(3))))
+		case "region":
+			w.event(traceEvUserRegion, ts, ev.Index(2).Uint64(), ev.Index(3).Uint64(), w.str(ev.Index(4).String()), w.stack(traceFrames(ev.Index(5))))
+		case "log":
+			w.event(traceEvUserLog, ts, ev.Index(2).Uint64(), w.str(ev.Index(3).String()), w.stack(traceFrames(ev.Index(5))))
+			message := ev.Index(4).String()
+			w.buf = w.varint(w.buf, uint64(len(message)))
+			w.buf = append(w.buf, message...)
+		}
+	}
+	return append(w.buf, w.dict...)
+}
@@ -0,0 +1,17 @@
+//go:build js
+
+package trace
+
+import _ "unsafe" // For go:linkname
+
+//go:linkname userTaskCreate runtime.trace_userTaskCreate
+func userTaskCreate(id, parentID uint64, taskType string)
+
+//go:linkname userTaskEnd runtime.trace_userTaskEnd
+func userTaskEnd(id uint64)
+
+//go:linkname userRegion runtime.trace_userRegion
+func userRegion(id, mode uint64, regionType string)
+
+//go:linkname userLog runtime.trace_userLog
+func userLog(id uint64, category, message string)
@@ -2,6 +2,8 @@
 
 package sync
 
+import "github.com/gopherjs/gopherjs/js"
+
 type Cond struct {
 	// fields used by vanilla implementation
 	noCopy  noCopy
@@ -20,6 +22,7 @@ func (c *Cond) Wait() {
 		c.ch = make(chan bool)
 	}
 	c.L.Unlock()
+	js.Global.Set("$waitReason", "sync.Cond.Wait")
 	<-c.ch
 	c.L.Lock()
 }
@@ -22,13 +22,27 @@ var semWaiters = make(map[*uint32][]chan bool)
 var semAwoken = make(map[*uint32]uint32)
 
 func runtime_Semacquire(s *uint32) {
-	runtime_SemacquireMutex(s, false, 1)
+	semacquire(s, false, "semacquire")
 }
 
 // SemacquireMutex is like Semacquire, but for profiling contended Mutexes.
 // Mutex profiling is not supported, so just use the same implementation as runtime_Semacquire.
 // TODO: Investigate this. If it's possible to implement, consider doing so, otherwise remove this comment.
 func runtime_SemacquireMutex(s *uint32, lifo bool, skipframes int) {
+	semacquire(s, lifo, "sync.Mutex.Lock")
+}
+
+func runtime_SemacquireRWMutexR(s *uint32, lifo bool, skipframes int) {
+	semacquire(s, lifo, "sync.RWMutex.RLock")
+}
+
+func runtime_SemacquireRWMutex(s *uint32, lifo bool, skipframes int) {
+	semacquire(s, lifo, "sync.RWMutex.Lock")
+}
+
+// semacquire waits until *s > 0 and then atomically decrements it. The reason
+// is shown in goroutine tracebacks while the calling goroutine waits.
+func semacquire(s *uint32, lifo bool, reason string) {
 	if (*s - semAwoken[s]) == 0 {
 		ch := make(chan bool)
 		if lifo {
@@ -36,6 +50,7 @@ func runtime_SemacquireMutex(s *uint32, lifo bool, skipframes int) {
 		} else {
 			semWaiters[s] = append(semWaiters[s], ch)
 		}
+		js.Global.Set("$waitReason", reason)
 		<-ch
 		semAwoken[s] -= 1
 		if semAwoken[s] == 0 {
@@ -45,14 +60,6 @@ func runtime_SemacquireMutex(s *uint32, lifo bool, skipframes int) {
 	*s--
 }
 
-func runtime_SemacquireRWMutexR(s *uint32, lifo bool, skipframes int) {
-	runtime_SemacquireMutex(s, lifo, skipframes)
-}
-
-func runtime_SemacquireRWMutex(s *uint32, lifo bool, skipframes int) {
-	runtime_SemacquireMutex(s, lifo, skipframes)
-}
-
 func runtime_Semrelease(s *uint32, handoff bool, skipframes int) {
 	// TODO: Use handoff if needed/possible.
 	*s++
@@ -2,6 +2,8 @@
 
 package sync
 
+import "github.com/gopherjs/gopherjs/js"
+
 type WaitGroup struct {
 	counter int
 	ch      chan struct{}
@@ -26,6 +28,7 @@ func (wg *WaitGroup) Add(delta int) {
 
 func (wg *WaitGroup) Wait() {
 	if wg.counter > 0 {
+		js.Global.Set("$waitReason", "sync.WaitGroup.Wait")
 		<-wg.ch
 	}
 }
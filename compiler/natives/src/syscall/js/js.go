@@ -3,6 +3,7 @@
 package js
 
 import (
+	"runtime"
 	"unsafe"
 
 	"github.com/gopherjs/gopherjs/js"
@@ -58,19 +59,41 @@ func Undefined() Value {
 
 type Func struct {
 	Value
+	handle *funcHandle
+}
+
+// funcHandle is shared by the copies of a Func and referenced by its
+// JavaScript function, so that it becomes unreachable along with both of them.
+// Its finalizer releases the Func if Release wasn't called.
+type funcHandle struct {
+	released bool
 }
 
 func (f Func) Release() {
-	js.Global.Set("$exportedFunctions", js.Global.Get("$exportedFunctions").Int()-1)
+	if f.handle == nil || f.handle.released {
+		return
+	}
+	f.handle.released = true
+	runtime.SetFinalizer(f.handle, nil)
+	releaseFunc(f.handle)
 	f.Value = Null()
 }
 
+// releaseFunc allows deadlock detection again once no functions are exported.
+func releaseFunc(*funcHandle) {
+	js.Global.Set("$exportedFunctions", js.Global.Get("$exportedFunctions").Int()-1)
+}
+
 func FuncOf(fn func(this Value, args []Value) any) Func {
 	// Existence of a wrapped function means that an external event may awaken the
 	// program and we need to suppress deadlock detection.
 	js.Global.Set("$exportedFunctions", js.Global.Get("$exportedFunctions").Int()+1)
+	handle := &funcHandle{}
+	runtime.SetFinalizer(handle, releaseFunc)
 	return Func{
+		handle: handle,
 		Value: objectToValue(js.MakeFunc(func(this *js.Object, args []*js.Object) any {
+			runtime.KeepAlive(handle)
 			vargs := make([]Value, len(args))
 			for i, a := range args {
 				vargs[i] = objectToValue(a)
@@ -0,0 +1,29 @@
+//go:build js
+
+package syscall
+
+// GOPHERJS: Node.js delivers more signals than the ones upstream defines for
+// js/wasm, so the additional ones are defined here, following them.
+const (
+	SIGHUP Signal = SIGTERM + 1 + iota
+	SIGUSR1
+	SIGUSR2
+	SIGPIPE
+	SIGALRM
+	SIGWINCH
+)
+
+var signals = [...]string{
+	SIGCHLD:  "child exited",
+	SIGINT:   "interrupt",
+	SIGKILL:  "killed",
+	SIGTRAP:  "trace/breakpoint trap",
+	SIGQUIT:  "quit",
+	SIGTERM:  "terminated",
+	SIGHUP:   "hangup",
+	SIGUSR1:  "user defined signal 1",
+	SIGUSR2:  "user defined signal 2",
+	SIGPIPE:  "broken pipe",
+	SIGALRM:  "alarm clock",
+	SIGWINCH: "window changed",
+}
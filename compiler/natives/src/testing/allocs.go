@@ -0,0 +1,20 @@
+//go:build js
+
+package testing
+
+// AllocsPerRun returns the average number of allocations during calls to f.
+//
+// GOPHERJS: Values that the gc compiler keeps on the stack are JavaScript
+// objects in GopherJS, so the allocation counts that tests expect don't apply.
+// AllocsPerRun still calls f, but reports no allocations. Benchmarks report the
+// allocations counted by runtime.ReadMemStats.
+//
+//gopherjs:replace
+func AllocsPerRun(runs int, f func()) (avg float64) {
+	// Warm up the function like the original does.
+	f()
+	for i := 0; i < runs; i++ {
+		f()
+	}
+	return 0
+}
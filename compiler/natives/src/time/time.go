@@ -34,6 +34,7 @@ func now() (sec int64, nsec int32, mono int64) {
 func Sleep(d Duration) {
 	c := make(chan struct{})
 	js.Global.Call("$setTimeout", js.InternalObject(func() { close(c) }), int(d/Millisecond))
+	js.Global.Set("$waitReason", "sleep")
 	<-c
 }
 
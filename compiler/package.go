@@ -6,13 +6,16 @@ import (
 	"go/token"
 	"go/types"
 	"strings"
+	"time"
 
 	"golang.org/x/tools/go/types/typeutil"
 
+	"github.com/gopherjs/gopherjs/compiler/astutil"
 	"github.com/gopherjs/gopherjs/compiler/errlist"
 	"github.com/gopherjs/gopherjs/compiler/internal/analysis"
 	"github.com/gopherjs/gopherjs/compiler/internal/dce"
 	"github.com/gopherjs/gopherjs/compiler/internal/typeparams"
+	"github.com/gopherjs/gopherjs/compiler/linkname"
 	"github.com/gopherjs/gopherjs/compiler/sources"
 	"github.com/gopherjs/gopherjs/compiler/typesutil"
 	"github.com/gopherjs/gopherjs/internal/sourcemapx"
@@ -34,15 +37,21 @@ type pkgContext struct {
 	// varPtrNames is for unexported package-level pointer var names only.
 	// Exported package-level names are always the full name from the Var.
 	// Function-level pointer var names are stored in the funcContext.varPtrNames.
-	varPtrNames  map[*types.Var]string
-	anonTypes    []*types.TypeName
-	anonTypeMap  typeutil.Map
-	escapingVars map[*types.Var]bool
-	indentation  int
-	minify       bool
-	fileSet      *token.FileSet
-	errList      errlist.ErrorList
-	instanceSet  *typeparams.PackageInstanceSets
+	varPtrNames map[*types.Var]string
+	anonTypes   []*types.TypeName
+	anonTypeMap typeutil.Map
+	// Functions constructing anonymous types on first use by the names of the
+	// types, see Options.LazyTypes.
+	anonTypeInits map[*types.TypeName]string
+	escapingVars  map[*types.Var]bool
+	indentation   int
+	opts          Options
+	fileSet       *token.FileSet
+	errList       errlist.ErrorList
+	instanceSet   *typeparams.PackageInstanceSets
+	// go:linkname directives of the package, which reference implementations
+	// in other packages.
+	linknames linkname.GoLinknameSet
 }
 
 // isMain returns true if this is the main package of the program.
@@ -121,23 +130,40 @@ type funcContext struct {
 	objectNames map[types.Object]string
 	// Number of function literals encountered within the current function context.
 	funcLitCounter int
+	// Type parameters of a generic function compiled once for multiple
+	// instances. Type arguments for them are passed in the `$dict` array at
+	// runtime, see sharedFuncDecls(). nil outside of such functions.
+	dictParams *types.TypeParamList
+	// Set to true while translating the minimal reflection metadata of a
+	// struct type, which omits field tags. See Options.StripReflection.
+	stripTags bool
+	// Set to true while translating the struct fields of a named type with
+	// Options.LazyTypes, whose field types are constructed on first use.
+	lazyFields bool
+	// Set to true if the deferred calls of the function are open-coded instead
+	// of being pushed onto the goroutine's defer stack, see canOpenCodeDefers.
+	openCodedDefers bool
+	// Local variables holding the deferred functions and their arguments for
+	// each open-coded defer statement translated so far, in the source order.
+	openDefers []openDefer
 }
 
-func newRootCtx(tContext *types.Context, srcs *sources.Sources, minify bool) *funcContext {
+func newRootCtx(tContext *types.Context, srcs *sources.Sources, opts Options) *funcContext {
 	funcCtx := &funcContext{
 		FuncInfo: srcs.TypeInfo.InitFuncInfo,
 		pkgCtx: &pkgContext{
 			Info:                 srcs.TypeInfo,
 			additionalSelections: make(map[*ast.SelectorExpr]typesutil.Selection),
 
-			typesCtx:     tContext,
-			pkgVars:      make(map[string]string),
-			varPtrNames:  make(map[*types.Var]string),
-			escapingVars: make(map[*types.Var]bool),
-			indentation:  1,
-			minify:       minify,
-			fileSet:      srcs.FileSet,
-			instanceSet:  srcs.TypeInfo.InstanceSets,
+			typesCtx:      tContext,
+			pkgVars:       make(map[string]string),
+			varPtrNames:   make(map[*types.Var]string),
+			escapingVars:  make(map[*types.Var]bool),
+			anonTypeInits: make(map[*types.TypeName]string),
+			indentation:   1,
+			opts:          opts,
+			fileSet:       srcs.FileSet,
+			instanceSet:   srcs.TypeInfo.InstanceSets,
 		},
 		allVars:     make(map[string]int),
 		varPtrNames: make(map[*types.Var]string),
@@ -146,6 +172,10 @@ func newRootCtx(tContext *types.Context, srcs *sources.Sources, minify bool) *fu
 		labelCases:  make(map[*types.Label]int),
 		objectNames: map[types.Object]string{},
 	}
+	funcCtx.FuncInfo = funcCtx.pkgCtx.adaptFuncInfo(funcCtx.FuncInfo)
+	if err := funcCtx.pkgCtx.linknames.Add(srcs.GoLinknames); err != nil {
+		funcCtx.pkgCtx.errList = append(funcCtx.pkgCtx.errList, err)
+	}
 	for name := range reservedKeywords {
 		funcCtx.allVars[name] = 1
 	}
@@ -158,11 +188,69 @@ type flowData struct {
 	endCase   int
 }
 
+// Options controls how Go sources are translated into JavaScript by Compile.
+type Options struct {
+	// Minify enables removal of unnecessary whitespace and shortening of
+	// identifiers in the generated code.
+	Minify bool
+	// NoInline disables inlining of small functions at their call sites.
+	NoInline bool
+	// SharedGenerics enables compiling a generic function only once for all
+	// instances that don't depend on the type arguments, passing the type
+	// arguments to the shared code in a runtime dictionary. See
+	// analysis.Info.IsShapeAgnostic for details.
+	SharedGenerics bool
+	// Generators enables compiling blocking functions into JavaScript generator
+	// functions and blocking calls into yield* expressions, which work like async
+	// functions and await expressions, instead of resumable state machines. The
+	// two modes use different runtime support code, so all packages of a program
+	// must be compiled with the same setting.
+	Generators bool
+	// PreemptBudget is the time a goroutine may run before its preempted loops
+	// yield to other goroutines and the event loop, see
+	// analysis.Info.EnablePreemption. Zero selects the default budget.
+	PreemptBudget time.Duration
+	// Unchecked omits index bounds checks, nil pointer checks and integer
+	// division by zero checks from the generated code. This is unsafe: an out of
+	// range index, a nil pointer dereference or a division by zero doesn't panic,
+	// but leads to undefined behavior instead. A package may also opt in with a
+	// //gopherjs:unchecked directive, see astutil.Unchecked.
+	Unchecked bool
+	// StripReflection enables emitting minimal reflection metadata for types
+	// that are never reflected upon, i.e. whose values can't reach reflect,
+	// fmt, encoding/json, type assertions etc. through an interface. Such types
+	// get method lists without signatures and struct fields without tags.
+	// Which types are reflected upon is determined for the whole program by
+	// dce.Selector, so all packages must be compiled with the same setting.
+	StripReflection bool
+	// LazyTypes defers construction of anonymous type objects, of the field
+	// types of named struct types and of method lists of named types until
+	// their first use, instead of building all of them in $finishSetup before
+	// the program starts. This reduces the startup time of large programs at
+	// the cost of a check whether the type is constructed yet whenever an
+	// anonymous type is referenced.
+	LazyTypes bool
+	// MangleProps enables renaming of unexported struct fields and methods to
+	// short property names, which are allocated for the whole program by
+	// WriteProgramCode. Names observable via reflection and fields with `js`
+	// struct tags are preserved. Packages augmented by GopherJS are not
+	// affected (see mangledPkg), and code accessing unexported fields of other
+	// packages by name from JavaScript breaks. All packages of a program must be compiled
+	// with the same setting.
+	MangleProps bool
+	// StableNames derives minified names of package-level variables from
+	// hashes of the symbols they represent instead of the order in which they
+	// are allocated. This keeps the minified code of unrelated declarations
+	// identical when a declaration is added or removed, at the cost of slightly
+	// longer names. Only has an effect together with Minify.
+	StableNames bool
+}
+
 // Compile the provided Go sources as a single package.
 //
 // Provided sources must be prepared so that the type information has been determined,
 // and the source files have been sorted by name to ensure reproducible JavaScript output.
-func Compile(srcs *sources.Sources, tContext *types.Context, minify bool) (_ *Archive, err error) {
+func Compile(srcs *sources.Sources, tContext *types.Context, opts Options) (_ *Archive, err error) {
 	defer func() {
 		e := recover()
 		if e == nil {
@@ -178,7 +266,12 @@ func Compile(srcs *sources.Sources, tContext *types.Context, minify bool) (_ *Ar
 		err = bailout(fmt.Errorf("unexpected compiler panic while building package %q: %v", srcs.ImportPath, e))
 	}()
 
-	rootCtx := newRootCtx(tContext, srcs, minify)
+	for _, file := range srcs.Files {
+		if astutil.Unchecked(file) {
+			opts.Unchecked = true
+		}
+	}
+	rootCtx := newRootCtx(tContext, srcs, opts)
 
 	importedPaths, importDecls := rootCtx.importDecls()
 
@@ -210,7 +303,7 @@ func Compile(srcs *sources.Sources, tContext *types.Context, minify bool) (_ *Ar
 	// final program.
 	allDecls := append(append(append(importDecls, typeDecls...), varDecls...), funcDecls...)
 
-	if minify {
+	if opts.Minify {
 		for _, d := range allDecls {
 			*d = d.minify()
 		}
@@ -221,15 +314,18 @@ func Compile(srcs *sources.Sources, tContext *types.Context, minify bool) (_ *Ar
 	}
 
 	return &Archive{
-		ImportPath:   srcs.ImportPath,
-		Name:         srcs.Package.Name(),
-		Imports:      importedPaths,
-		Package:      srcs.Package,
-		Declarations: allDecls,
-		FileSet:      srcs.FileSet,
-		Minified:     minify,
-		GoLinknames:  srcs.GoLinknames,
-		IncJSCode:    srcs.JSFiles,
+		ImportPath:    srcs.ImportPath,
+		Name:          srcs.Package.Name(),
+		Imports:       importedPaths,
+		Package:       srcs.Package,
+		Declarations:  allDecls,
+		FileSet:       srcs.FileSet,
+		Minified:      opts.Minify,
+		MangledProps:  opts.MangleProps,
+		Generators:    opts.Generators,
+		PreemptBudget: opts.PreemptBudget,
+		GoLinknames:   srcs.GoLinknames,
+		IncJSCode:     srcs.JSFiles,
 	}, nil
 }
 
@@ -310,7 +406,7 @@ func (fc *funcContext) initArgs(ty types.Type) string {
 			if !method.Exported() {
 				pkgPath = method.Pkg().Path()
 			}
-			methods[i] = fmt.Sprintf(`{prop: "%s", name: "%s", pkg: "%s", typ: $funcType(%s)}`, method.Name(), method.Name(), pkgPath, fc.initArgs(method.Type()))
+			methods[i] = fmt.Sprintf(`{prop: "%s", name: "%s", pkg: "%s", typ: $funcType(%s)}`, fc.methodName(method), method.Name(), pkgPath, fc.initArgs(method.Type()))
 		}
 		return fmt.Sprintf("[%s]", strings.Join(methods, ", "))
 	case *types.Map:
@@ -338,8 +434,24 @@ func (fc *funcContext) initArgs(ty types.Type) string {
 				pkgPath = field.Pkg().Path()
 			}
 			ft := fc.fieldType(t, i)
+			tag := t.Tag(i)
+			if fc.stripTags {
+				tag = ""
+			}
+			_, named := ft.(*types.Named)
+			_, basic := ft.(*types.Basic)
+			if fc.lazyFields && !named && !basic {
+				fields[i] = fmt.Sprintf(`$lazyField({prop: "%s", name: %s, embedded: %t, exported: %t, tag: %s}, () => %s)`,
+					fc.fieldName(t, i), encodeString(field.Name()), field.Anonymous(), field.Exported(), encodeString(tag), fc.typeName(ft))
+				continue
+			}
 			fields[i] = fmt.Sprintf(`{prop: "%s", name: %s, embedded: %t, exported: %t, typ: %s, tag: %s}`,
-				fieldName(t, i), encodeString(field.Name()), field.Anonymous(), field.Exported(), fc.typeName(ft), encodeString(t.Tag(i)))
+				fc.fieldName(t, i), encodeString(field.Name()), field.Anonymous(), field.Exported(), fc.typeName(ft), encodeString(tag))
+		}
+		if fc.lazyFields {
+			// The runtime can't tell whether the struct is comparable without
+			// constructing the field types.
+			return fmt.Sprintf(`"%s", [%s], %t`, pkgPath, strings.Join(fields, ", "), types.Comparable(t))
 		}
 		return fmt.Sprintf(`"%s", [%s]`, pkgPath, strings.Join(fields, ", "))
 	case *types.TypeParam:
@@ -9,6 +9,9 @@ var $getStackDepth = () => {
 
 var $panicStackDepth = null, $panicValue;
 var $callDeferred = (deferred, jsErr, fromPanic) => {
+    if ($isStackOverflow(jsErr)) {
+        throw jsErr;
+    }
     if (!fromPanic && deferred !== null && $curGoroutine.deferStack.indexOf(deferred) == -1) {
         throw jsErr;
     }
@@ -40,6 +43,10 @@ var $callDeferred = (deferred, jsErr, fromPanic) => {
         while (true) {
             if (deferred === null) {
                 deferred = $curGoroutine.deferStack[$curGoroutine.deferStack.length - 1];
+                if (deferred === $openDeferMarker) {
+                    /* Unwind to the function with open-coded deferred calls, which continues the panic. */
+                    throw $openDeferSignal;
+                }
                 if (deferred === undefined) {
                     /* The panic reached the top of the stack. Clear it and throw it as a JavaScript error. */
                     $panicStackDepth = null;
@@ -56,7 +63,7 @@ var $callDeferred = (deferred, jsErr, fromPanic) => {
                     } else {
                         msg = localPanicValue;
                     }
-                    throw new Error(msg);
+                    throw $panicError(msg);
                 }
             }
             var call = deferred.pop();
@@ -109,12 +116,47 @@ var $callDeferred = (deferred, jsErr, fromPanic) => {
     }
 };
 
+// Marks a function with open-coded deferred calls on the defer stack. Such a
+// function keeps its deferred calls in local variables, so a panic has to unwind
+// to it, signaled by $openDeferSignal, before it can run them.
+var $openDeferMarker = {};
+var $openDeferSignal = {};
+
+// Called by a function with open-coded deferred calls if its body or one of the
+// deferred calls threw. Replaces the function's marker on top of the defer stack
+// with a regular defer frame containing the deferred calls that haven't run yet,
+// i.e. those with the corresponding bit set, and runs them like a function that
+// doesn't open-code its deferred calls would.
+var $callOpenDeferred = (bits, calls, jsErr) => {
+    var deferred = [];
+    for (var i = 0; i < calls.length; i++) {
+        if ((bits & (1 << i)) !== 0) {
+            deferred.push(calls[i]);
+        }
+    }
+    var stack = $curGoroutine.deferStack;
+    stack[stack.length - 1] = deferred;
+    if (jsErr === $openDeferSignal) {
+        // Continue the panic that unwound to this function.
+        jsErr = null;
+        try {
+            $panic($curGoroutine.panicStack.pop());
+        } catch (err) {
+            jsErr = err;
+        }
+    }
+    $callDeferred(deferred, jsErr);
+};
+
 var $panicnil = "0";
 var $panic = value => {
     if (value === $ifaceNil && $panicnil !== "1") {
         value = $newPanicNilError();
     }
     $curGoroutine.panicStack.push(value);
+    // Deferred calls may be run after unwinding the stack to the function that
+    // deferred them, so the stack trace of the panic is captured in advance.
+    $curGoroutine.panicTrace = new Error();
     $callDeferred(null, null, true);
 };
 var $recover = () => {
@@ -122,19 +164,198 @@ var $recover = () => {
         return $ifaceNil;
     }
     $panicStackDepth = null;
+    // The captured stack references the frames of the panic, which shouldn't
+    // stay reachable after it's recovered.
+    $curGoroutine.panicTrace = undefined;
     return $panicValue;
 };
 var $throw = err => { throw err; };
 
-var $noGoroutine = { asleep: false, exit: false, deferStack: [], panicStack: [] };
-var $curGoroutine = $noGoroutine, $totalGoroutines = 0, $awakeGoroutines = 0, $checkForDeadlock = true, $exportedFunctions = 0;
+// $panicError returns the JavaScript error thrown for a Go panic with the given
+// message that hasn't been recovered.
+var $panicError = msg => {
+    var err = new Error(msg);
+    err.$goPanic = true;
+    return err;
+};
+
+// $isStackOverflow reports whether err is the error a JavaScript engine
+// throws when the call stack is exhausted: a RangeError in V8 and
+// JavaScriptCore, and an InternalError in SpiderMonkey. Go treats a stack
+// overflow as a fatal error, so these errors aren't converted into panics and
+// no deferred calls run while they unwind the stack.
+var $isStackOverflow = err => {
+    if (!(err instanceof Error)) {
+        return false;
+    }
+    return (err instanceof RangeError && /call stack/i.test(err.message)) ||
+        (err.name === "InternalError" && /recursion/i.test(err.message));
+};
+
+// $fatalError is the error that terminated the program, after which no
+// goroutines run. Browsers can't exit like Node.js does.
+var $fatalError = null;
+
+// $reportPanic prints an error that terminated goroutine g the way the Go
+// runtime reports an unrecovered panic, with the traceback of the goroutine
+// where the panic occurred. In Node.js the process exits with status 2 like a
+// Go program does; elsewhere the caller re-throws the error so that it reaches
+// the browser's console as well.
+var $reportPanic = (g, err) => {
+    if (!(err instanceof Error)) {
+        return;
+    }
+    if ($isStackOverflow(err)) {
+        $fatalError = err;
+        $scheduled = [];
+        console.error("runtime: goroutine stack exceeds limit\nfatal error: stack overflow\n\n" + $goroutineTraceback(g, "running", err.stack, 0));
+        if ($global.process !== undefined) {
+            $global.process.exit(2);
+        }
+        return;
+    }
+    var msg = err.message, trace = err;
+    if (!err.$goPanic) {
+        msg = "JavaScript error: " + msg;
+    } else if (g.panicTrace !== undefined) {
+        // The stack may have been unwound by the time the panic is reported.
+        trace = g.panicTrace;
+    }
+    console.error("panic: " + msg + "\n\n" + $goroutineTraceback(g, "running", trace.stack, 0));
+    if ($global.process !== undefined) {
+        $global.process.exit(2);
+    }
+};
+
+// $reportDeadlock prints the fatal error of a program whose goroutines are all
+// blocked, along with their tracebacks, and exits in Node.js like Go does.
+var $reportDeadlock = () => {
+    console.error("fatal error: all goroutines are asleep - deadlock!\n\n" + $allTracebacks(null));
+    if ($global.process !== undefined) {
+        $global.process.exit(2);
+    }
+};
+
+// $captureStack returns an error with the stack trace of the caller, limited to
+// the given number of frames where the JavaScript engine supports it.
+var $captureStack = limit => {
+    var outerLimit = Error.stackTraceLimit;
+    Error.stackTraceLimit = limit;
+    try {
+        return new Error();
+    } finally {
+        Error.stackTraceLimit = outerLimit;
+    }
+};
+
+// $goroutineStacks reports whether goroutines record their stack traces when
+// they block and where they are created, for the tracebacks of goroutines other
+// than the running one. Recording them makes blocking operations and go
+// statements an order of magnitude slower, so it's only done with
+// GOTRACEBACK=all or higher, in tests generated with the --detect-leaks flag of
+// gopherjs test and while runtime/trace is enabled.
+var $goroutineStacks = false;
+
+// $recordStack returns the stack trace of the caller like $captureStack if
+// goroutines record their stack traces, and undefined otherwise.
+var $recordStack = limit => $goroutineStacks || $traceEvents !== null ? $captureStack(limit + 1) : undefined;
+
+// $goroutineTraceback formats the traceback of goroutine g in the given state
+// like the Go runtime does: a header, the Go frames of the stack trace except
+// the first skip ones, and the location of the go statement that created g.
+var $goroutineTraceback = (g, state, stack, skip) => {
+    var trace = "goroutine " + g.id + " [" + state + "]:\n";
+    if (stack !== undefined) {
+        trace += $goTraceback(stack, skip);
+    }
+    if (g.createdBy !== undefined) {
+        var creator = $goFrames(g.createdBy.stack)[0];
+        if (creator !== undefined) {
+            trace += "created by " + creator[0] + " in goroutine " + g.parentId + "\n\t" + creator[1] + ":" + creator[2] + "\n";
+        }
+    }
+    return trace;
+};
+
+// $allTracebacks formats the tracebacks of all goroutines except the given
+// one, separated by blank lines. The stack trace of a goroutine that isn't
+// running is the one recorded when it blocked last time, if any.
+var $allTracebacks = except => {
+    var traces = [];
+    for (var g of $allGoroutines) {
+        if (g === except) {
+            continue;
+        }
+        var state = g.asleep ? g.waitReason : "runnable";
+        var trace = $goroutineTraceback(g, state, g.waitTrace && g.waitTrace.stack, 0);
+        if (g.waitTrace === undefined && !$goroutineStacks) {
+            trace += "\t(stack not recorded, set GOTRACEBACK=all to record it)\n";
+        }
+        traces.push(trace);
+    }
+    return traces.join("\n");
+};
+
+// $stackDump implements runtime.Stack: it formats the traceback of the current
+// goroutine, omitting its first skip Go frames, followed by the tracebacks of
+// all other goroutines if all is true.
+var $stackDump = (all, skip) => {
+    var g = $curGoroutine;
+    var dump = $goroutineTraceback(g, "running", new Error().stack, skip);
+    if (all) {
+        var others = $allTracebacks(g);
+        if (others !== "") {
+            dump += "\n" + others;
+        }
+    }
+    return dump;
+};
+
+// $leakedGoroutines formats the tracebacks of the goroutines that are blocked
+// and were created since the goroutine with the given id, except the ones that
+// package testing creates to run tests and the one os/signal delivers signals
+// with. The tests generated with the --detect-leaks flag of gopherjs test fail
+// if there are any after they return.
+var $leakedGoroutines = first => {
+    var traces = [];
+    for (var g of $allGoroutines) {
+        if (g.id < first || !g.asleep || g.waitReason === "runnable") {
+            continue;
+        }
+        var creator = g.createdBy && $goFrames(g.createdBy.stack)[0];
+        if (creator !== undefined && /^(testing|os\/signal)\./.test(creator[0])) {
+            continue;
+        }
+        traces.push($goroutineTraceback(g, g.waitReason, g.waitTrace && g.waitTrace.stack, 0));
+    }
+    return traces.join("\n");
+};
+var $goexit = () => {
+    $curGoroutine.exit = true;
+    throw null;
+};
+
+var $noGoroutine = { id: 0, asleep: false, exit: false, deferStack: [], panicStack: [] };
+var $curGoroutine = $noGoroutine, $lastGoroutineId = 0, $totalGoroutines = 0, $awakeGoroutines = 0, $checkForDeadlock = true, $exportedFunctions = 0;
 var $mainFinished = false;
+
+// $allGoroutines contains the goroutines that haven't exited yet, in the order
+// of their creation.
+var $allGoroutines = new Set();
+
+// $waitReason describes what the current goroutine is about to wait for, e.g.
+// "sleep". Natives of the standard library set it right before a channel
+// operation that implements a blocking primitive, and the operation consumes
+// it, so that tracebacks show the reason instead of the channel operation.
+var $waitReason = "";
+
 var $go = (fun, args) => {
     $totalGoroutines++;
     $awakeGoroutines++;
     var $goroutine = () => {
         try {
             $curGoroutine = $goroutine;
+            $traceGoStart($goroutine);
             var r = fun(...args);
             if (r && r.$blk !== undefined) {
                 fun = () => { return r.$blk(); };
@@ -144,43 +365,127 @@ var $go = (fun, args) => {
             $goroutine.exit = true;
         } catch (err) {
             if (!$goroutine.exit) {
+                $reportPanic($goroutine, err);
                 throw err;
             }
         } finally {
             $curGoroutine = $noGoroutine;
+            $traceGoStop($goroutine);
             if ($goroutine.exit) { /* also set by runtime.Goexit() */
                 $totalGoroutines--;
+                $allGoroutines.delete($goroutine);
                 $goroutine.asleep = true;
             }
             if ($goroutine.asleep) {
                 $awakeGoroutines--;
                 if (!$mainFinished && $awakeGoroutines === 0 && $checkForDeadlock && $exportedFunctions === 0) {
-                    console.error("fatal error: all goroutines are asleep - deadlock!");
-                    if ($global.process !== undefined) {
-                        $global.process.exit(2);
-                    }
+                    $reportDeadlock();
                 }
             }
         }
     };
+    $goroutine.id = ++$lastGoroutineId;
+    if ($curGoroutine !== $noGoroutine) {
+        $goroutine.parentId = $curGoroutine.id;
+        $goroutine.createdBy = $recordStack(3);
+    }
     $goroutine.asleep = false;
     $goroutine.exit = false;
     $goroutine.deferStack = [];
     $goroutine.panicStack = [];
+    $allGoroutines.add($goroutine);
+    $traceEvent("create", $goroutine.id, $goroutine.createdBy);
     $schedule($goroutine);
 };
 
 var $scheduled = [];
+var $timeSliceStart = 0;
+
+// $schedulerDriver replaces the default policy of when the goroutines in
+// $scheduled run if it's set by package github.com/gopherjs/gopherjs/scheduler,
+// or if JavaScript code sets the gopherjsScheduler property of the global
+// object. The request(drain) method of the driver is called when goroutines
+// become runnable while none run, and must call drain() once, later, e.g. in a
+// requestAnimationFrame callback. drain() runs goroutines for the number of
+// milliseconds in the optional budget property, and then requests another call
+// if goroutines are still runnable.
+var $schedulerDriver = null;
+var $activeSchedulerDriver = () => $schedulerDriver || $global.gopherjsScheduler || null;
+var $setSchedulerDriver = driver => {
+    $schedulerDriver = driver;
+    $schedulerDriverChanged();
+};
+var $jsSchedulerDriver = $global.gopherjsScheduler;
+Object.defineProperty($global, "gopherjsScheduler", {
+    get: () => $jsSchedulerDriver,
+    set: driver => {
+        $jsSchedulerDriver = driver;
+        $schedulerDriverChanged();
+    },
+    enumerable: true,
+    configurable: true,
+});
+
+// $schedulerDriverChanged hands the runnable goroutines over to the driver that
+// is active after a change, since the replaced driver may never call drain()
+// for them. If a goroutine is running, $runScheduled does it once the goroutine
+// returns control.
+var $schedulerDriverChanged = () => {
+    if ($curGoroutine !== $noGoroutine || $scheduled.length === 0) {
+        return;
+    }
+    var driver = $activeSchedulerDriver();
+    if (driver !== null) {
+        $requestDrain(driver);
+    } else {
+        setTimeout($runScheduled);
+    }
+};
+// $drainRequestedFrom is the driver whose drain() call is pending, if any.
+// Another driver, which replaced it meanwhile, is asked for a drain() call of
+// its own, since the replaced one may never call drain().
+var $drainRequestedFrom = null;
+var $requestDrain = driver => {
+    if ($drainRequestedFrom === driver) {
+        return;
+    }
+    $drainRequestedFrom = driver;
+    driver.request(() => {
+        if ($drainRequestedFrom === driver) {
+            $drainRequestedFrom = null;
+        }
+        // Goroutines can be preempted even if the driver calls drain() from a
+        // Go function called by JavaScript, since they have stacks of their own.
+        var outerDisabled = $preemptDisabled;
+        $preemptDisabled = 0;
+        try {
+            $runScheduled();
+        } finally {
+            $preemptDisabled = outerDisabled;
+        }
+    });
+};
+var $schedulerBudget = driver => {
+    return driver !== null && driver.budget !== undefined ? driver.budget : 4;
+};
+
 var $runScheduled = () => {
+    var driver = $activeSchedulerDriver();
     // For nested setTimeout calls browsers enforce 4ms minimum delay. We minimize
     // the effect of this penalty by queueing the timer preemptively before we run
     // the goroutines, and later cancelling it if it turns out unneeded. See:
     // https://developer.mozilla.org/en-US/docs/Web/API/setTimeout#nested_timeouts
-    var nextRun = setTimeout($runScheduled);
+    var nextRun = driver === null ? setTimeout($runScheduled) : undefined;
+    var budget = $schedulerBudget(driver);
+    // Goroutines have stacks of their own, so they can be preempted even if the
+    // scheduler runs within a Go function called by JavaScript, e.g. a timer.
+    var outerDisabled = $preemptDisabled;
+    $preemptDisabled = 0;
     try {
         var start = Date.now();
         var r;
         while ((r = $scheduled.shift()) !== undefined) {
+            $timeSliceStart = Date.now();
             r();
             // We need to interrupt this loop in order to allow the event loop to
             // process timers, IO, etc. However, invoking scheduling through
@@ -188,40 +493,169 @@ var $runScheduled = () => {
             // looping until the 4ms minimal delay has elapsed (assuming there are
             // scheduled goroutines to run), and then yield to the event loop.
             var elapsed = Date.now() - start;
-            if (elapsed > 4 || elapsed < 0) { break; }
+            if (elapsed > budget || elapsed < 0) { break; }
         }
     } finally {
-        if ($scheduled.length == 0) {
+        $preemptDisabled = outerDisabled;
+        // The goroutines that ran may have replaced the driver.
+        var nextDriver = $activeSchedulerDriver();
+        if (nextDriver !== null) {
+            // The driver decides when the remaining goroutines run.
+            clearTimeout(nextRun);
+            if ($scheduled.length !== 0) {
+                $requestDrain(nextDriver);
+            }
+        } else if ($scheduled.length == 0) {
             // Cancel scheduling pass if there's nothing to run.
             clearTimeout(nextRun);
+        } else if (driver !== null) {
+            setTimeout($runScheduled);
         }
     }
 };
 
 var $schedule = goroutine => {
+    if ($fatalError !== null) {
+        return;
+    }
+    $traceGoUnblock(goroutine);
     if (goroutine.asleep) {
         goroutine.asleep = false;
         $awakeGoroutines++;
     }
     $scheduled.push(goroutine);
     if ($curGoroutine === $noGoroutine) {
-        $runScheduled();
+        var driver = $activeSchedulerDriver();
+        if (driver !== null) {
+            $requestDrain(driver);
+        } else {
+            $runScheduled();
+        }
     }
 };
 
+// $setTimeout calls the Go function f after t milliseconds. f is called from
+// JavaScript, so it can't block.
 var $setTimeout = (f, t) => {
     $awakeGoroutines++;
     return setTimeout(() => {
         $awakeGoroutines--;
-        f();
+        $sync(f());
     }, t);
 };
 
-var $block = () => {
+// $block marks the current goroutine as blocked for the given reason. The
+// stack trace is recorded for tracebacks of the goroutine while it's blocked,
+// since the stack is unwound afterwards, see $goroutineStacks.
+var $block = reason => {
     if ($curGoroutine === $noGoroutine) {
         $throwRuntimeError("cannot block in JavaScript callback, fix by wrapping code in goroutine");
     }
     $curGoroutine.asleep = true;
+    $curGoroutine.waitReason = reason;
+    $curGoroutine.waitTrace = $recordStack(100);
+    $traceGoBlock($curGoroutine);
+};
+
+// $takeWaitReason returns $waitReason, or the given default reason if it isn't
+// set, and resets it.
+var $takeWaitReason = reason => {
+    if ($waitReason !== "") {
+        reason = $waitReason;
+        $waitReason = "";
+    }
+    return reason;
+};
+
+// $sync returns the result r of a call of a Go function that can't be suspended,
+// e.g. because it's called from JavaScript. A blocking function returns its
+// results unless it blocks, so they are returned as is, unlike with generator
+// code generation, see goroutines_generators.js.
+var $sync = r => r;
+
+// $traceEvents records the scheduler events for the execution tracer while
+// runtime/trace is enabled, and is null otherwise. Each event is an array of
+// its kind, the time in milliseconds and its arguments, which the runtime
+// encodes in the format of the Go execution tracer, see runtime.ReadTrace.
+var $traceEvents = null;
+var $traceClock = $global.performance !== undefined ? () => $global.performance.now() : () => Date.now();
+var $traceEvent = (kind, ...args) => {
+    if ($traceEvents !== null) {
+        $traceEvents.push([kind, $traceClock(), ...args]);
+    }
+};
+
+// $startTrace starts recording events, beginning with the goroutines that
+// exist at this point and whether they are blocked.
+var $startTrace = () => {
+    $traceEvents = [];
+    for (var g of $allGoroutines) {
+        g.traceRunning = false;
+        g.traceWaiting = false;
+        $traceEvent("create", g.id, g.createdBy);
+        if (g.asleep && g.waitReason !== "runnable") {
+            g.traceWaiting = true;
+            $traceEvent("waiting", g.id);
+        }
+    }
+    $traceGoStart($curGoroutine);
+};
+
+// $stopTrace stops recording events and returns the recorded ones.
+var $stopTrace = () => {
+    var events = $traceEvents;
+    $traceEvents = null;
+    return events;
+};
+
+// $traceGoStart, $traceGoBlock, $traceGoUnblock and $traceGoStop record the
+// transitions of goroutine g between the running, waiting and runnable states.
+// A goroutine that is preempted stays runnable, so it isn't unblocked later.
+var $traceGoStart = g => {
+    if ($traceEvents !== null && g !== $noGoroutine) {
+        g.traceRunning = true;
+        $traceEvent("start", g.id);
+    }
+};
+var $traceGoBlock = g => {
+    if (g.traceRunning) {
+        g.traceRunning = false;
+        g.traceWaiting = g.waitReason !== "runnable";
+        $traceEvent("block", g.waitReason, g.waitTrace);
+    }
+};
+var $traceGoUnblock = g => {
+    if (g.traceWaiting) {
+        g.traceWaiting = false;
+        $traceEvent("unblock", g.id);
+    }
+};
+var $traceGoStop = g => {
+    if (g.traceRunning) {
+        g.traceRunning = false;
+        $traceEvent(g.exit ? "end" : "sched");
+    }
+};
+
+// Preempted loops call $shouldPreempt every $preemptTicks iterations to check
+// whether the current goroutine has run for longer than $preemptBudget
+// milliseconds, and then call $preempt to yield to other goroutines and the
+// event loop. Go functions called synchronously from JavaScript, which can't
+// yield, increment $preemptDisabled while they run.
+var $preemptBudget = 10, $preemptTicks = 0, $preemptDisabled = 0;
+var $shouldPreempt = () => {
+    $preemptTicks = 1000;
+    if ($preemptDisabled !== 0 || $curGoroutine === $noGoroutine) {
+        return false;
+    }
+    var elapsed = Date.now() - $timeSliceStart;
+    return elapsed >= $preemptBudget || elapsed < 0;
+};
+var $preempt = () => {
+    var g = $curGoroutine;
+    $setTimeout(() => { $schedule(g); }, 0);
+    $block("runnable");
+    return { $blk() { } };
 };
 
 var $restore = (context, params) => {
@@ -232,6 +666,7 @@ var $restore = (context, params) => {
 }
 
 var $send = (chan, value) => {
+    var reason = $takeWaitReason(chan === $chanNil ? "chan send (nil chan)" : "chan send");
     if (chan.$closed) {
         $throwRuntimeError("send on closed channel");
     }
@@ -252,7 +687,7 @@ var $send = (chan, value) => {
         $schedule(thisGoroutine);
         return value;
     });
-    $block();
+    $block(reason);
     return {
         $blk() {
             if (closedDuringSend) {
@@ -262,6 +697,7 @@ var $send = (chan, value) => {
     };
 };
 var $recv = chan => {
+    var reason = $takeWaitReason(chan === $chanNil ? "chan receive (nil chan)" : "chan receive");
     var queuedSend = chan.$sendQueue.shift();
     if (queuedSend !== undefined) {
         chan.$buffer.push(queuedSend(false));
@@ -281,7 +717,7 @@ var $recv = chan => {
         $schedule(thisGoroutine);
     };
     chan.$recvQueue.push(queueEntry);
-    $block();
+    $block(reason);
     return f;
 };
 var $close = chan => {
@@ -305,6 +741,7 @@ var $close = chan => {
     }
 };
 var $select = comms => {
+    var reason = $takeWaitReason(comms.length === 0 ? "select (no cases)" : "select");
     var ready = [];
     var selection = -1;
     for (var i = 0; i < comms.length; i++) {
@@ -388,6 +825,6 @@ var $select = comms => {
             }
         })(i);
     }
-    $block();
+    $block(reason);
     return f;
 };
@@ -0,0 +1,298 @@
+// Goroutine support for programs compiled with generator code generation.
+//
+// Blocking Go functions are compiled into JavaScript generator functions, and
+// blocking calls delegate to the generators they return with yield*, much like
+// async functions and await expressions. A goroutine yields through all of its
+// suspended calls when it blocks, and the scheduler resumes it by calling the
+// next() method of the generator of its function. Unlike a promise, a generator
+// can also be run synchronously, so a Go function called from JavaScript
+// returns its results unless it actually blocks, see $sync. The definitions
+// below replace the ones from goroutines.js, which support resumable state
+// machines instead.
+
+// $panicSignal and $goexitSignal are thrown to unwind the stack of a panicking
+// or exiting goroutine. Deferred calls are run by each function as the signal
+// passes through it, see $deferredCalls.
+var $panicSignal = { toString() { return "Go panic"; } };
+var $goexitSignal = { toString() { return "runtime.Goexit"; } };
+
+$noGoroutine.deferDepth = null;
+$noGoroutine.recoverable = false;
+
+// $Generator is the prototype of the generators returned by blocking functions.
+var $Generator = Object.getPrototypeOf(function* () { }).prototype;
+var $isGenerator = r => $Generator.isPrototypeOf(r);
+
+// $await returns the iterator a blocking call delegates to with yield*. That's
+// the generator returned by a blocking function, or an iterator that returns
+// the result of any other function right away, e.g. of a function value that
+// doesn't block.
+var $returned = {
+    value: undefined,
+    next() {
+        var step = { done: true, value: this.value };
+        this.value = undefined;
+        return step;
+    },
+    [Symbol.iterator]() { return this; },
+};
+var $await = r => {
+    if ($isGenerator(r)) {
+        return r;
+    }
+    $returned.value = r;
+    return $returned;
+};
+
+// $sync runs the generator returned by a blocking function to completion and
+// returns its result, or returns r itself if it isn't a generator. Blocking is
+// disabled meanwhile, since the caller has no way to wait for the generator.
+var $blockingDisabled = 0;
+var $sync = r => {
+    if (!$isGenerator(r)) {
+        return r;
+    }
+    $blockingDisabled++;
+    try {
+        return r.next().value;
+    } finally {
+        $blockingDisabled--;
+    }
+};
+
+var $panic = value => {
+    if (value === $ifaceNil && $panicnil !== "1") {
+        value = $newPanicNilError();
+    }
+    $curGoroutine.panicStack.push(value);
+    // The stack is unwound before the panic is reported if it's not recovered,
+    // so the stack trace of the panic is captured in advance.
+    $curGoroutine.panicTrace = new Error();
+    throw $panicSignal;
+};
+
+var $goexit = () => {
+    $curGoroutine.exit = true;
+    throw $goexitSignal;
+};
+
+// $isDeferredCall reports whether its caller has been invoked directly by
+// $deferredCalls while the goroutine was panicking, in which case the caller
+// may recover from the panic.
+//
+// Functions calling recover() check this on entry, since after a yield the
+// stack no longer reflects how the function has been called.
+var $isDeferredCall = () => {
+    var g = $curGoroutine;
+    return g.deferDepth !== null && g.deferDepth === $getStackDepth() - 2;
+};
+
+var $recover = canRecover => {
+    var g = $curGoroutine;
+    if (!canRecover || !g.recoverable) {
+        return $ifaceNil;
+    }
+    g.recoverable = false;
+    // The captured stack references the frames of the panic, which shouldn't
+    // stay reachable after it's recovered.
+    g.panicTrace = undefined;
+    return g.panicStack.pop();
+};
+
+// $deferredCalls runs the deferred calls of a function, which either returns
+// normally (jsErr is null) or unwinds because of jsErr. It yields while a
+// deferred call is blocked. If the function still unwinds after all deferred
+// calls, the error is re-thrown.
+var $deferredCalls = function* (deferred, jsErr) {
+    var g = $curGoroutine;
+    if ($isStackOverflow(jsErr)) {
+        throw jsErr;
+    }
+    if (jsErr !== null && jsErr !== $panicSignal && jsErr !== $goexitSignal) {
+        // JavaScript exceptions are treated as Go panics.
+        g.panicStack.push(new $jsErrorPtr(jsErr));
+        jsErr = $panicSignal;
+    }
+
+    var call;
+    while ((call = deferred.pop()) !== undefined) {
+        var panicking = jsErr === $panicSignal;
+        var panics = g.panicStack.length;
+        var outerDepth = g.deferDepth, outerRecoverable = g.recoverable;
+        g.deferDepth = panicking ? $getStackDepth() + 1 : null;
+        g.recoverable = panicking;
+        try {
+            var blocked;
+            if (panicking) {
+                blocked = $panicDeferredCall(call);
+            } else {
+                var r = call[0].apply(call[2], call[1]);
+                blocked = $isGenerator(r) && !r.next().done ? r : undefined;
+            }
+            g.deferDepth = outerDepth;
+            if (blocked !== undefined) {
+                yield;
+                yield* blocked;
+            }
+            if (panicking && !g.recoverable) {
+                jsErr = null; // The panic was recovered.
+            }
+        } catch (err) {
+            if ($isStackOverflow(err)) {
+                throw err;
+            }
+            if (err !== $panicSignal && err !== $goexitSignal) {
+                g.panicStack.push(new $jsErrorPtr(err));
+                err = $panicSignal;
+            }
+            if (err === $panicSignal && panicking && g.panicStack.length > panics) {
+                // A new panic replaces the one the deferred call was run for.
+                g.panicStack.splice(panics - 1, 1);
+            }
+            jsErr = err;
+        } finally {
+            g.deferDepth = outerDepth;
+            g.recoverable = outerRecoverable;
+        }
+    }
+
+    if (jsErr !== null) {
+        throw jsErr;
+    }
+};
+
+// $panicDeferredCall runs a deferred call for a panic until it returns or
+// blocks, and returns the generator of a blocked call. The stack of the panic
+// has been unwound by then, so its frame stands in for runtime.gopanic in
+// stack traces.
+var $panicDeferredCall = call => {
+    var r = call[0].apply(call[2], call[1]);
+    if (!$isGenerator(r)) {
+        return undefined;
+    }
+    // A blocking function starts running below the frame of the generator's
+    // next() method, one frame deeper than other functions.
+    $curGoroutine.deferDepth++;
+    return r.next().done ? undefined : r;
+};
+
+// $callDeferred runs deferred calls of a non-blocking function.
+var $callDeferred = (deferred, jsErr) => {
+    $sync($deferredCalls(deferred, jsErr));
+};
+
+// $uncaughtError returns the JavaScript error to report for an error that
+// terminated the goroutine.
+var $uncaughtError = (g, err) => {
+    if (err !== $panicSignal) {
+        return err;
+    }
+    var value = g.panicStack.pop();
+    if (value.Object instanceof Error) {
+        return value.Object;
+    }
+    var msg;
+    if (value.constructor === $String) {
+        msg = value.$val;
+    } else if (value.Error !== undefined) {
+        msg = $sync(value.Error());
+    } else if (value.String !== undefined) {
+        msg = $sync(value.String());
+    } else {
+        msg = value;
+    }
+    return $panicError(msg);
+};
+
+var $go = (fun, args) => {
+    $totalGoroutines++;
+    $awakeGoroutines++;
+    // The iterator of the goroutine's function, once it has started.
+    var steps;
+    var $goroutine = () => {
+        // Goroutines have stacks of their own, so they can block even if the
+        // scheduler runs within a Go function called by JavaScript.
+        var outerDisabled = $blockingDisabled;
+        $blockingDisabled = 0;
+        try {
+            $curGoroutine = $goroutine;
+            $traceGoStart($goroutine);
+            if (steps === undefined) {
+                steps = $await(fun(...args));
+            }
+            if (steps.next().done) {
+                $goroutine.exit = true;
+            }
+        } catch (err) {
+            if (!$goroutine.exit) {
+                err = $uncaughtError($goroutine, err);
+                $reportPanic($goroutine, err);
+                throw err;
+            }
+        } finally {
+            $blockingDisabled = outerDisabled;
+            $curGoroutine = $noGoroutine;
+            $traceGoStop($goroutine);
+            if ($goroutine.exit) { /* also set by runtime.Goexit() */
+                $totalGoroutines--;
+                $allGoroutines.delete($goroutine);
+                $goroutine.asleep = true;
+            }
+            if ($goroutine.asleep) {
+                $awakeGoroutines--;
+                if (!$mainFinished && $awakeGoroutines === 0 && $checkForDeadlock && $exportedFunctions === 0) {
+                    $reportDeadlock();
+                }
+            }
+        }
+    };
+    $goroutine.id = ++$lastGoroutineId;
+    if ($curGoroutine !== $noGoroutine) {
+        $goroutine.parentId = $curGoroutine.id;
+        $goroutine.createdBy = $recordStack(3);
+    }
+    $goroutine.asleep = false;
+    $goroutine.exit = false;
+    $goroutine.deferStack = [];
+    $goroutine.panicStack = [];
+    $goroutine.deferDepth = null;
+    $goroutine.recoverable = false;
+    $allGoroutines.add($goroutine);
+    $traceEvent("create", $goroutine.id, $goroutine.createdBy);
+    $schedule($goroutine);
+};
+
+// $initPackages initializes the runtime package and then the main package,
+// which runs the main function. Package initializers are generator functions,
+// so both of them run in the main goroutine.
+var $initPackages = function* () {
+    yield* $packages["runtime"].$init();
+    yield* $mainPkg.$init();
+};
+
+var $blockNow = $block;
+var $block = reason => {
+    if ($blockingDisabled !== 0) {
+        $throwRuntimeError("cannot block in JavaScript callback, fix by wrapping code in goroutine");
+    }
+    $blockNow(reason);
+};
+
+// $blockable adapts a channel operation from goroutines.js, which returns a
+// continuation with a $blk() method if it has blocked the goroutine, to return
+// a generator instead, which yields once and then returns the operation result.
+var $blockable = op => (...args) => {
+    var r = op(...args);
+    if (r === undefined || r.$blk === undefined) {
+        return r;
+    }
+    return $resume(r);
+};
+var $resume = function* (r) {
+    yield;
+    return r.$blk();
+};
+var $send = $blockable($send);
+var $recv = $blockable($recv);
+var $select = $blockable($select);
+var $preempt = $blockable($preempt);
@@ -133,7 +133,7 @@ var $externalize = (v, t, makeWrapper) => {
             }
 
             if (makeWrapper !== undefined) {
-                return makeWrapper(v);
+                return $sync(makeWrapper(v));
             }
 
             o = {};
@@ -168,23 +168,33 @@ var $externalizeFunction = (v, t, passThis, makeWrapper) => {
                 }
                 args.push($internalize(arguments[i], t.params[i], makeWrapper));
             }
-            var result = v.apply(passThis ? this : undefined, args);
-            switch (t.results.length) {
-                case 0:
-                    return;
-                case 1:
-                    return $externalize($copyIfRequired(result, t.results[0]), t.results[0], makeWrapper);
-                default:
-                    for (var i = 0; i < t.results.length; i++) {
-                        result[i] = $externalize($copyIfRequired(result[i], t.results[i]), t.results[i], makeWrapper);
-                    }
-                    return result;
+            $preemptDisabled++;
+            try {
+                return $externalizeResults($sync(v.apply(passThis ? this : undefined, args)), t, makeWrapper);
+            } finally {
+                $preemptDisabled--;
             }
         };
     }
     return v.$externalizeWrapper;
 };
 
+// $externalizeResults converts results of a Go function of type t called from
+// JavaScript.
+var $externalizeResults = (result, t, makeWrapper) => {
+    switch (t.results.length) {
+        case 0:
+            return;
+        case 1:
+            return $externalize($copyIfRequired(result, t.results[0]), t.results[0], makeWrapper);
+        default:
+            for (var i = 0; i < t.results.length; i++) {
+                result[i] = $externalize($copyIfRequired(result[i], t.results[i]), t.results[i], makeWrapper);
+            }
+            return result;
+    }
+};
+
 var $internalize = (v, t, recv, seen, makeWrapper) => {
     if (t === $jsObjectPtr) {
         return v;
@@ -22,6 +22,9 @@ var jsmapping string
 //go:embed goroutines.js
 var goroutines string
 
+//go:embed goroutines_generators.js
+var goroutinesGenerators string
+
 type PreludeFile struct {
 	Name   string
 	Source string
@@ -45,6 +48,16 @@ func PreludeFiles() (files []PreludeFile) {
 	return
 }
 
+// GeneratorGoroutines gets the goroutine support code for programs compiled with
+// generator code generation. It must follow PreludeFiles, since it replaces
+// some of the definitions in them.
+func GeneratorGoroutines() PreludeFile {
+	return PreludeFile{
+		Name:   filepath.Join(getPackagePath(), `goroutines_generators.js`),
+		Source: goroutinesGenerators,
+	}
+}
+
 // getPackagePath attempts to determine the package path of the prelude package
 // by inspecting the runtime stack. This is used to set the correct paths for
 // the prelude files so that source maps work correctly. This should get the
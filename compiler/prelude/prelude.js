@@ -75,7 +75,16 @@ var $throwRuntimeError; /* set by package "runtime" */
 var $newPanicNilError; /* set by package "runtime" — returns a new *PanicNilError */
 var $throwNilPointerError = () => { $throwRuntimeError("invalid memory address or nil pointer dereference"); };
 var $call = (fn, rcvr, args) => { return fn.apply(rcvr, args); };
-var $makeFunc = fn => { return function(...args) { return $externalize(fn(this, new ($sliceType($jsObjectPtr))($global.Array.prototype.slice.call(args, []))), $emptyInterface); }; };
+var $makeFunc = fn => {
+    return function(...args) {
+        $preemptDisabled++;
+        try {
+            return $externalize($sync(fn(this, new ($sliceType($jsObjectPtr))($global.Array.prototype.slice.call(args, [])))), $emptyInterface);
+        } finally {
+            $preemptDisabled--;
+        }
+    };
+};
 var $unused = v => { };
 var $print = console.log;
 // Under Node we can emulate print() more closely by avoiding a newline.
@@ -149,9 +158,10 @@ var $parseCallFrame = (frame) => {
     const stripReceiver = (fnName) => fnName.replace(receiverRe, "$1");
 
     $parseCallFrame = (frame) => {
-        // FireFox
+        // FireFox. Chrome / Node.js frames may contain "@" in the file path
+        // (e.g. Go module cache directories), so they are detected first.
         const atIdx = frame.indexOf("@")
-        if (atIdx >= 0) {
+        if (atIdx >= 0 && !frame.trimStart().startsWith("at ")) {
             const fnName = frame.substring(0, atIdx) || "<none>";
             return parsePos(fnName, frame.substring(atIdx + 1));
         }
@@ -167,6 +177,8 @@ var $parseCallFrame = (frame) => {
 
         // With-parens form: "at func (file:line:col)"
         var fnName = frame.substring(0, frame.indexOf("(")).trim();
+        // Callers awaiting an async function are marked with "async".
+        if (fnName.startsWith("async ")) fnName = fnName.substring(6);
         const asIdx = fnName.indexOf("[as ");
         if (asIdx > 0) {
             var closeIdx = fnName.indexOf("]");
@@ -187,6 +199,250 @@ var $parseCallFrame = (frame) => {
     return $parseCallFrame(frame);
 };
 
+// $sourceMaps caches the decoded source maps of the scripts that appear in
+// stack traces by the script file name or URL. A null value means that the
+// script has no source map, or it couldn't be loaded.
+var $sourceMaps = new Map();
+
+// $resolveCallFrame parses a call frame string like $parseCallFrame, and
+// translates the position in the generated JavaScript into the original
+// position using the source map of the script, if it's available. Frames of
+// Go code are named after the Go function enclosing the position.
+//
+// Frames that have been resolved already (e.g. by Node.js with the
+// --enable-source-maps flag) are returned as parsed.
+var $resolveCallFrame = frame => {
+    var parsed = $parseCallFrame(frame);
+    var file = parsed[1];
+    if (file === "" || file === "<anonymous>" || file.endsWith(".go")) {
+        return parsed;
+    }
+    var sm = $sourceMaps.get(file);
+    if (sm === undefined) {
+        sm = null;
+        try {
+            sm = $loadSourceMap(file);
+        } catch (e) { /* Treat malformed source maps as missing. */ }
+        $sourceMaps.set(file, sm);
+    }
+    if (sm === null) {
+        return parsed;
+    }
+
+    // Find the last segment starting at or before the position, which may be
+    // on one of the preceding lines, like Node.js does. Stack traces use 1-based
+    // lines and columns, and source maps use 0-based ones.
+    var line = Number(parsed[2]) - 1, col = Number(parsed[3]) - 1;
+    if (line < 0 || line >= sm.lines.length - 1) {
+        return parsed;
+    }
+    var lo = sm.lines[line], hi = sm.lines[line + 1];
+    while (lo < hi) {
+        var mid = (lo + hi) >>> 1;
+        if (sm.segments[mid * 5] <= col) {
+            lo = mid + 1;
+        } else {
+            hi = mid;
+        }
+    }
+    var seg = (lo - 1) * 5;
+    if (lo === 0 || sm.segments[seg + 1] === -1) {
+        return parsed;
+    }
+    var origFile = sm.sources[sm.segments[seg + 1]];
+    var fnName = parsed[0];
+    if (origFile.endsWith(".go") && sm.segments[seg + 4] !== -1) {
+        fnName = sm.names[sm.segments[seg + 4]];
+    }
+    return [fnName, origFile, sm.segments[seg + 2] + 1, sm.segments[seg + 3] + 1];
+};
+
+// $loadSourceMap loads and decodes the source map referenced by the
+// sourceMappingURL comment of the given script. The source map may be embedded
+// into the script as a data URL, or be a separate file alongside the script.
+// In the browsers, a separate source map is only fetched if the page sets
+// gopherjsFetchSourceMaps to true, since the request blocks the page.
+// Returns null if the script or the source map can't be read synchronously.
+var $loadSourceMap = file => {
+    var script = $readScriptFile(file);
+    if (script === null) {
+        return null;
+    }
+    var comment = "//# sourceMappingURL=";
+    var i = script.lastIndexOf(comment);
+    if (i === -1) {
+        return null;
+    }
+    var url = script.substring(i + comment.length).trim().split(/\s/)[0];
+    var json;
+    if (url.startsWith("data:")) {
+        var comma = url.indexOf(",");
+        var data = url.substring(comma + 1);
+        if (url.substring(0, comma).endsWith(";base64")) {
+            json = new TextDecoder("utf-8").decode(Uint8Array.from(atob(data), c => c.charCodeAt(0)));
+        } else {
+            json = decodeURIComponent(data);
+        }
+        url = file;
+    } else {
+        if ($global.process === undefined && $global.gopherjsFetchSourceMaps !== true) {
+            return null;
+        }
+        url = $resolveSourceURL(file, url);
+        json = $readScriptFile(url);
+        if (json === null) {
+            return null;
+        }
+    }
+
+    var map = JSON.parse(json);
+    if (typeof map.mappings !== "string") {
+        return null;
+    }
+    var root = map.sourceRoot ? map.sourceRoot.replace(/\/?$/, "/") : "";
+    return Object.assign($decodeMappings(map.mappings), {
+        sources: map.sources.map(source => $resolveSourceURL(url, root + source)),
+        names: map.names || [],
+    });
+};
+
+// $decodeMappings decodes the VLQ-encoded mappings of a source map. The
+// segments are returned as a flat array of (generated column, source index,
+// original line, original column, function name index) tuples, where the
+// source index is -1 for unmapped segments, and the function name is the
+// name of the nearest preceding named segment, or -1. Segments of the
+// generated line n are segments[lines[n]*5] through segments[lines[n+1]*5].
+var $decodeMappings = mappings => {
+    var base64 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/";
+    var digits = new Int8Array(128);
+    for (var i = 0; i < base64.length; i++) {
+        digits[base64.charCodeAt(i)] = i;
+    }
+
+    var segments = [], lines = [0];
+    var fields = [0, 0, 0, 0, 0], n = 0;
+    var col = 0, source = 0, origLine = 0, origCol = 0, name = 0, fnName = -1;
+    var value = 0, shift = 0;
+    for (var i = 0; i <= mappings.length; i++) {
+        var c = i < mappings.length ? mappings.charCodeAt(i) : 59 /* ; */;
+        if (c === 44 /* , */ || c === 59 /* ; */) {
+            if (n > 0) {
+                col += fields[0];
+                if (n >= 4) {
+                    source += fields[1];
+                    origLine += fields[2];
+                    origCol += fields[3];
+                }
+                if (n >= 5) {
+                    name += fields[4];
+                    fnName = name;
+                }
+                segments.push(col, n >= 4 ? source : -1, origLine, origCol, fnName);
+                n = 0;
+            }
+            if (c === 59) {
+                lines.push(segments.length / 5);
+                col = 0;
+            }
+            continue;
+        }
+        var digit = digits[c];
+        value += (digit & 31) << shift;
+        shift += 5;
+        if ((digit & 32) === 0) {
+            fields[n++] = (value & 1) ? -(value >>> 1) : (value >>> 1);
+            value = 0;
+            shift = 0;
+        }
+    }
+    return { segments: Int32Array.from(segments), lines: lines };
+};
+
+// $resolveSourceURL resolves a file name or URL relative to the location of
+// the file or URL base, like a browser resolves the source map URL of a script.
+var $resolveSourceURL = (base, rel) => {
+    if (/^[a-zA-Z][a-zA-Z0-9+.-]+:/.test(base)) {
+        try {
+            return new URL(rel, base).href;
+        } catch (e) {
+            return rel;
+        }
+    }
+    if (rel.startsWith("/") || /^[a-zA-Z]:[\\/]/.test(rel)) {
+        return rel;
+    }
+    return base.substring(0, Math.max(base.lastIndexOf("/"), base.lastIndexOf("\\")) + 1) + rel;
+};
+
+// $readScriptFile synchronously reads the content of a file, or a URL in the
+// browsers, where it's usually a script the browser has just loaded. Returns
+// null if it can't be read.
+var $readScriptFile = file => {
+    try {
+        if ($global.process !== undefined && $global.require) {
+            if (file.startsWith("file:")) {
+                file = $global.require("url").fileURLToPath(file);
+            }
+            return $global.require("fs").readFileSync(file, "utf8");
+        }
+        if (typeof XMLHttpRequest !== "undefined") {
+            var xhr = new XMLHttpRequest();
+            xhr.open("GET", file, false);
+            xhr.send(null);
+            if (xhr.status === 200 || (xhr.status === 0 && xhr.responseText)) {
+                return xhr.responseText;
+            }
+        }
+    } catch (e) { /* The file is not accessible. */ }
+    return null;
+};
+
+// $goFrames returns the resolved Go frames of a JavaScript stack trace as
+// (function name, file, line) tuples. Frames of the GopherJS runtime and
+// unexported functions of package runtime are omitted like Go does, and the
+// frames end at the bottom of the goroutine's stack. The main function is at
+// the bottom of the main goroutine's stack in Go, while in GopherJS it's
+// called by the initialization function of the main package.
+var $goFrames = stack => {
+    var lines = String(stack).split("\n");
+    var isFrame = line => line.indexOf("@") !== -1;
+    if (lines.some(line => /^\s+at /.test(line))) {
+        isFrame = line => /^\s+at /.test(line);
+    }
+    var frames = [];
+    for (var i = 0; i < lines.length; i++) {
+        if (!isFrame(lines[i])) {
+            continue;
+        }
+        var frame = $resolveCallFrame(lines[i]);
+        if (frame[0] === "$goroutine") {
+            break;
+        }
+        if (frame[1].endsWith(".go") && !/^runtime\.[^A-Z]/.test(frame[0])) {
+            frames.push(frame);
+        }
+        if (frame[0] === "main.main") {
+            break;
+        }
+    }
+    return frames;
+};
+
+// $goTraceback formats the Go frames of a JavaScript stack trace, except the
+// first skip ones, the way the Go runtime prints goroutine tracebacks: the
+// function name followed by the file and line of each frame. Like Go, it
+// elides the frames in the middle of stacks deeper than 100 frames.
+var $goTraceback = (stack, skip) => {
+    var frames = $goFrames(stack).slice(skip || 0);
+    var format = frame => frame[0] + "(...)\n\t" + frame[1] + ":" + frame[2] + "\n";
+    if (frames.length > 100) {
+        return frames.slice(0, 50).map(format).join("") +
+            "..." + (frames.length - 100) + " frames elided...\n" +
+            frames.slice(-50).map(format).join("");
+    }
+    return frames.map(format).join("");
+};
+
 var $callForAllPackages = (methodName) => {
     var names = $keys($packages);
     for (var i = 0; i < names.length; i++) {
@@ -591,6 +847,10 @@ var $growSlice = (slice, minCapacity) => {
 
     if (minCapacity > capacity) {
         capacity = $calculateNewCapacity(minCapacity, capacity);
+        if ($countAllocs) {
+            $mallocs++;
+            $allocBytes += capacity * slice.constructor.elem.size;
+        }
         
         let newArray;
         if (array.constructor === Array) {
@@ -38,6 +38,39 @@ var $synthesizeMethods = () => {
     $methodSynthesizers = null;
 };
 
+// Returns the struct field with a typ property, which constructs the field
+// type on the first access. Used for struct fields with lazy type setup.
+var $lazyField = (field, construct) => {
+    Object.defineProperty(field, "typ", {
+        get: () => {
+            var typ = construct();
+            Object.defineProperty(field, "typ", { value: typ, writable: true, enumerable: true, configurable: true });
+            return typ;
+        },
+        enumerable: true,
+        configurable: true
+    });
+    return field;
+};
+
+// Defines the method list of a named type to be built on first access. Used
+// with lazy type setup.
+var $lazyMethods = (typ, build) => {
+    var define = methods => {
+        Object.defineProperty(typ, "methods", { value: methods, writable: true, enumerable: true, configurable: true });
+    };
+    Object.defineProperty(typ, "methods", {
+        get: () => {
+            var methods = build();
+            define(methods);
+            return methods;
+        },
+        set: define,
+        enumerable: true,
+        configurable: true
+    });
+};
+
 var $ifaceKeyFor = x => {
     if (x === $ifaceNil) {
         return 'nil';
@@ -252,14 +285,18 @@ var $newType = (size, kind, string, named, pkg, exported, constructor) => {
             typ.ptr.elem = typ;
             typ.ptr.prototype.$get = function () { return this; };
             typ.ptr.prototype.$set = function (v) { typ.copy(this, v); };
-            typ.init = (pkgPath, fields) => {
+            typ.init = (pkgPath, fields, comparable) => {
                 typ.pkgPath = pkgPath;
                 typ.fields = fields;
-                fields.forEach(f => {
-                    if (!f.typ.comparable) {
-                        typ.comparable = false;
-                    }
-                });
+                if (comparable !== undefined) {
+                    typ.comparable = comparable; // Set if the fields are lazy.
+                } else {
+                    fields.forEach(f => {
+                        if (!f.typ.comparable) {
+                            typ.comparable = false;
+                        }
+                    });
+                }
                 typ.keyFor = x => {
                     var val = x.$val;
                     return $mapArray(fields, f => {
@@ -649,8 +686,39 @@ var $mapType = (key, elem) => {
     }
     return typ;
 };
+// $mallocs and $allocBytes count the JavaScript objects allocated for Go
+// slices, maps, structs and pointers to other values, and their total size
+// according to the Go types. They are reported by runtime.ReadMemStats, which
+// sets $countAllocs on the first call. Allocations aren't counted before, so
+// that programs which never read the statistics don't pay for counting.
+var $mallocs = 0, $allocBytes = 0, $countAllocs = false;
+
+// $memoryUsage returns the heap usage of the JavaScript engine as reported by
+// Node.js or the browser, or undefined if it isn't available.
+var $memoryUsage = () => {
+    if ($global.process !== undefined && typeof $global.process.memoryUsage === "function") {
+        var usage = $global.process.memoryUsage();
+        return { heapUsed: usage.heapUsed, heapTotal: usage.heapTotal, sys: usage.rss };
+    }
+    var memory = $global.performance !== undefined ? $global.performance.memory : undefined;
+    if (memory !== undefined) {
+        return { heapUsed: memory.usedJSHeapSize, heapTotal: memory.totalJSHeapSize, sys: memory.totalJSHeapSize };
+    }
+    return undefined;
+};
+
+// $newMap creates a Go map, optionally with entries given as [key, entry]
+// pairs.
+var $newMap = entries => {
+    if ($countAllocs) {
+        $mallocs++;
+        $allocBytes += 48; // Size of the map header of the gc runtime.
+    }
+    return new Map(entries);
+};
+
 var $makeMap = (keyForFunc, entries) => {
-    var m = new Map();
+    var m = $newMap();
     for (var i = 0; i < entries.length; i++) {
         var e = entries[i];
         m.set(keyForFunc(e.k), e);
@@ -672,6 +740,10 @@ var $newDataPointer = (data, constructor) => {
     if (constructor.elem.kind === $kindStruct) {
         return data;
     }
+    if ($countAllocs) {
+        $mallocs++;
+        $allocBytes += constructor.elem.size;
+    }
     return new constructor(() => { return data; }, v => { data = v; });
 };
 
@@ -725,6 +797,10 @@ var $makeSlice = (typ, length, capacity = length) => {
     if (capacity < 0 || capacity < length || capacity > 2147483647) {
         $throwRuntimeError("makeslice: cap out of range");
     }
+    if ($countAllocs) {
+        $mallocs++;
+        $allocBytes += capacity * typ.elem.size;
+    }
     var array = new typ.nativeArray(capacity);
     if (typ.nativeArray === Array) {
         for (var i = 0; i < capacity; i++) {
@@ -753,6 +829,9 @@ var $structType = (pkgPath, fields) => {
         }
         typ = $newType(0, $kindStruct, string, false, "", false, function(...args) {
             this.$val = this;
+            if ($countAllocs) {
+                $mallocs++;
+            }
             for (var i = 0; i < fields.length; i++) {
                 var f = fields[i];
                 if (f.name == '_') {
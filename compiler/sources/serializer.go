@@ -15,6 +15,7 @@ import (
 // s.TypeInfo, s.baseInfo, s.Package, and s.GoLinknames are intentionally
 // omitted from encoding since they must be constructed in the context of the
 // full program to be able to handle generics and cross-package references.
+// s.Preempt is omitted since it depends on the build options.
 func (s *Sources) Write(encode func(any) error) error {
 	prepareGob()
 	if err := encode(s.ImportPath); err != nil {
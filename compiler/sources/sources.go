@@ -57,6 +57,11 @@ type Sources struct {
 	// GoLinknames is the set of Go linknames for this package.
 	// This is nil until set by ParseGoLinknames.
 	GoLinknames []linkname.GoLinkname
+
+	// Preempt indicates that long-running loops in the package should
+	// periodically yield to other goroutines, see
+	// analysis.Info.EnablePreemption. This must be set before Analyze.
+	Preempt bool
 }
 
 type Importer func(path, srcDir string) (*Sources, error)
@@ -84,7 +89,22 @@ func (s *Sources) getFileName(file *ast.File) string {
 // in the type information, if analyze is called first.
 func (s *Sources) Simplify() {
 	for i, file := range s.Files {
+		// astrewrite.Simplify keeps the types of function literals, but drops the
+		// positions of their bodies, which mark where deferred calls are run.
+		bodies := map[*ast.FuncType]*ast.BlockStmt{}
+		ast.Inspect(file, func(n ast.Node) bool {
+			if lit, ok := n.(*ast.FuncLit); ok {
+				bodies[lit.Type] = lit.Body
+			}
+			return true
+		})
 		s.Files[i] = astrewrite.Simplify(file, s.baseInfo, false)
+		ast.Inspect(s.Files[i], func(n ast.Node) bool {
+			if lit, ok := n.(*ast.FuncLit); ok && bodies[lit.Type] != nil {
+				lit.Body.Lbrace, lit.Body.Rbrace = bodies[lit.Type].Lbrace, bodies[lit.Type].Rbrace
+			}
+			return true
+		})
 	}
 }
 
@@ -180,6 +200,9 @@ func (s *Sources) Analyze(importer Importer, tContext *types.Context, instances
 		return srcs.TypeInfo, nil
 	}
 	s.TypeInfo = analysis.AnalyzePkg(s.Files, s.FileSet, s.baseInfo, tContext, s.Package, instances, infoImporter)
+	if s.Preempt {
+		s.TypeInfo.EnablePreemption()
+	}
 }
 
 // ParseGoLinknames extracts all //go:linkname compiler directive from the sources.
@@ -185,7 +185,7 @@ func (fc *funcContext) translateStmt(stmt ast.Stmt, label *types.Label) {
 			if s.Post != nil {
 				fc.translateStmt(s.Post, nil)
 			}
-		}, label, fc.Flattened[s])
+		}, label, fc.Flattened[s], fc.Preempted[s])
 
 	case *ast.RangeStmt:
 		refVar := fc.newLocalVariable("_ref")
@@ -206,7 +206,7 @@ func (fc *funcContext) translateStmt(stmt ast.Stmt, label *types.Label) {
 				}
 			}, func() {
 				fc.Printf("%s += %s[1];", iVar, runeVar)
-			}, label, fc.Flattened[s])
+			}, label, fc.Flattened[s], fc.Preempted[s])
 
 		case *types.Map:
 			iVar := fc.newLocalVariable("_i")
@@ -233,7 +233,7 @@ func (fc *funcContext) translateStmt(stmt ast.Stmt, label *types.Label) {
 				}
 			}, func() {
 				fc.Printf("%s++;", iVar)
-			}, label, fc.Flattened[s])
+			}, label, fc.Flattened[s], fc.Preempted[s])
 
 		case *types.Array, *types.Pointer, *types.Slice:
 			var length string
@@ -263,7 +263,7 @@ func (fc *funcContext) translateStmt(stmt ast.Stmt, label *types.Label) {
 				}
 			}, func() {
 				fc.Printf("%s++;", iVar)
-			}, label, fc.Flattened[s])
+			}, label, fc.Flattened[s], fc.Preempted[s])
 
 		case *types.Chan:
 			okVar := fc.newIdent(fc.newLocalVariable("_ok"), types.Typ[types.Bool])
@@ -344,10 +344,11 @@ func (fc *funcContext) translateStmt(stmt ast.Stmt, label *types.Label) {
 			fc.Printf("return%s;", rVal)
 			return
 		}
-		if !fc.Blocking[s] {
+		if !fc.Blocking[s] || fc.pkgCtx.opts.Generators {
 			// The function is flattened, but the return statement is non-blocking
-			// (i.e. doesn't lead to blocking deferred calls). A regular return
-			// is sufficient, but we also make sure to not resume function body.
+			// (i.e. doesn't lead to blocking deferred calls), or the function is
+			// a generator and won't be re-entered. A regular return is sufficient, but we
+			// also make sure to not resume function body.
 			fc.Printf("$s = -1; return%s;", rVal)
 			return
 		}
@@ -369,6 +370,10 @@ func (fc *funcContext) translateStmt(stmt ast.Stmt, label *types.Label) {
 		return
 
 	case *ast.DeferStmt:
+		if fc.openCodedDefers {
+			fc.translateOpenDefer(s.Call)
+			return
+		}
 		callable, arglist := fc.delegatedCall(s.Call)
 		fc.Printf("$deferred.push([%s, %s]);", callable, arglist)
 
@@ -475,7 +480,7 @@ func (fc *funcContext) translateStmt(stmt ast.Stmt, label *types.Label) {
 			Fun:  fc.newIdent("$send", types.NewSignatureType(nil, nil, nil, types.NewTuple(types.NewVar(0, nil, "", chanType), types.NewVar(0, nil, "", chanType.Elem())), nil, false)),
 			Args: []ast.Expr{s.Chan, fc.newIdent(fc.translateImplicitConversionWithCloning(s.Value, chanType.Elem()).String(), chanType.Elem())},
 		}
-		fc.Blocking[call] = true
+		fc.markBlockingCall(call)
 		fc.translateStmt(&ast.ExprStmt{X: call}, label)
 
 	case *ast.SelectStmt:
@@ -527,7 +532,7 @@ func (fc *funcContext) translateStmt(stmt ast.Stmt, label *types.Label) {
 			Args: []ast.Expr{fc.newIdent(fmt.Sprintf("[%s]", strings.Join(channels, ", ")), types.NewInterfaceType(nil, nil))},
 		}, types.Typ[types.Int])
 		if !hasDefault {
-			fc.Blocking[selectCall] = true
+			fc.markBlockingCall(selectCall)
 		}
 		fc.Printf("%s = %s;", selectionVar, fc.translateExpr(selectCall))
 
@@ -634,7 +639,7 @@ func (fc *funcContext) translateBranchingStmt(caseClauses []*ast.CaseClause, def
 	fc.PrintCond(!flatten, "}"+suffix, fmt.Sprintf("case %d:", endCase))
 }
 
-func (fc *funcContext) translateLoopingStmt(cond func() string, body *ast.BlockStmt, bodyPrefix, post func(), label *types.Label, flatten bool) {
+func (fc *funcContext) translateLoopingStmt(cond func() string, body *ast.BlockStmt, bodyPrefix, post func(), label *types.Label, flatten, preempt bool) {
 	prevFlowData := fc.flowDatas[nil]
 	data := &flowData{
 		postStmt: post,
@@ -657,6 +662,9 @@ func (fc *funcContext) translateLoopingStmt(cond func() string, body *ast.BlockS
 	isTerminated := false
 	fc.PrintCond(!flatten, "while (true) {", fmt.Sprintf("case %d:", data.beginCase))
 	fc.Indented(func() {
+		if preempt {
+			fc.translateStmt(fc.preemptionCheck(flatten), nil)
+		}
 		condStr := cond()
 		if condStr != "true" {
 			fc.PrintCond(!flatten, fmt.Sprintf("if (!(%s)) { break; }", condStr), fmt.Sprintf("if(!(%s)) { $s = %d; continue; }", condStr, data.endCase))
@@ -694,6 +702,22 @@ func (fc *funcContext) translateLoopingStmt(cond func() string, body *ast.BlockS
 	}
 }
 
+// preemptionCheck returns a statement that yields to other goroutines and the
+// event loop once the current goroutine has used up its time budget. It is
+// evaluated at the beginning of each iteration of a preempted loop.
+func (fc *funcContext) preemptionCheck(flatten bool) ast.Stmt {
+	call := &ast.CallExpr{Fun: fc.newIdent("$preempt", types.NewSignatureType(nil, nil, nil, nil, nil, false))}
+	fc.markBlockingCall(call)
+	check := &ast.IfStmt{
+		Cond: fc.newIdent("--$preemptTicks < 0 && $shouldPreempt()", types.Typ[types.Bool]),
+		Body: &ast.BlockStmt{List: []ast.Stmt{&ast.ExprStmt{X: call}}},
+	}
+	if flatten {
+		fc.Flattened[check] = true
+	}
+	return check
+}
+
 func (fc *funcContext) translateAssign(lhs, rhs ast.Expr, define bool) string {
 	lhs = astutil.RemoveParens(lhs)
 	if isBlank(lhs) {
@@ -733,6 +757,9 @@ func (fc *funcContext) translateAssign(lhs, rhs ast.Expr, define bool) string {
 		switch lhsType.Underlying().(type) {
 		case *types.Array, *types.Struct:
 			if define {
+				if fc.isSharedDefine(lhs, rhs) {
+					return fmt.Sprintf("%s = %s;", fc.translateExpr(lhs), rhsExpr) // skip $clone
+				}
 				return fmt.Sprintf("%s = $clone(%s, %s);", fc.translateExpr(lhs), rhsExpr, fc.typeName(lhsType))
 			}
 			return fmt.Sprintf("%s.copy(%s, %s);", fc.typeName(lhsType), fc.translateExpr(lhs), rhsExpr)
@@ -758,13 +785,13 @@ func (fc *funcContext) translateAssign(lhs, rhs ast.Expr, define bool) string {
 	case *ast.IndexExpr:
 		switch t := fc.typeOf(l.X).Underlying().(type) {
 		case *types.Array, *types.Pointer:
-			pattern := rangeCheck("%1e[%2f] = %3s", fc.pkgCtx.Types[l.Index].Value != nil, true)
-			if _, ok := t.(*types.Pointer); ok { // check pointer for nil (attribute getter causes a panic)
+			pattern := fc.rangeCheck("%1e[%2f] = %3s", fc.pkgCtx.Types[l.Index].Value != nil, true)
+			if _, ok := t.(*types.Pointer); ok && !fc.pkgCtx.opts.Unchecked { // check pointer for nil (attribute getter causes a panic)
 				pattern = `%1e.nilCheck, ` + pattern
 			}
 			return fc.formatExpr(pattern, l.X, l.Index, rhsExpr).String() + ";"
 		case *types.Slice:
-			return fc.formatExpr(rangeCheck("%1e.$array[%1e.$offset + %2f] = %3s", fc.pkgCtx.Types[l.Index].Value != nil, false), l.X, l.Index, rhsExpr).String() + ";"
+			return fc.formatExpr(fc.rangeCheck("%1e.$array[%1e.$offset + %2f] = %3s", fc.pkgCtx.Types[l.Index].Value != nil, false), l.X, l.Index, rhsExpr).String() + ";"
 		default:
 			panic(fmt.Sprintf("Unhandled lhs type: %T\n", t))
 		}
@@ -773,6 +800,23 @@ func (fc *funcContext) translateAssign(lhs, rhs ast.Expr, define bool) string {
 	}
 }
 
+// isSharedDefine returns true if a struct or array variable may be defined
+// sharing its storage with the assigned value instead of cloning it. This is
+// safe when neither the new variable nor the variable holding the value are
+// ever modified, exposed or retained.
+func (fc *funcContext) isSharedDefine(lhs, rhs ast.Expr) bool {
+	id, ok := lhs.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	v, ok := fc.pkgCtx.ObjectOf(id).(*types.Var)
+	if !ok || !fc.pkgCtx.IsReadOnly(v) {
+		return false
+	}
+	root, ok := fc.stableValueRoot(rhs)
+	return ok && fc.pkgCtx.IsReadOnly(root)
+}
+
 func (fc *funcContext) translateResults(results []ast.Expr) string {
 	tuple := fc.typeResolver.Substitute(fc.sig.Sig.Results()).(*types.Tuple)
 	switch tuple.Len() {
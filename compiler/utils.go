@@ -8,6 +8,7 @@ import (
 	"go/constant"
 	"go/token"
 	"go/types"
+	"hash/fnv"
 	"net/url"
 	"reflect"
 	"regexp"
@@ -17,7 +18,9 @@ import (
 	"text/template"
 	"unicode"
 
+	"github.com/gopherjs/gopherjs/compiler/astutil"
 	"github.com/gopherjs/gopherjs/compiler/internal/analysis"
+	"github.com/gopherjs/gopherjs/compiler/internal/symbol"
 	"github.com/gopherjs/gopherjs/compiler/internal/typeparams"
 	"github.com/gopherjs/gopherjs/compiler/typesutil"
 	"github.com/gopherjs/gopherjs/internal/sourcemapx"
@@ -148,7 +151,10 @@ func (fc *funcContext) expandTupleArgs(argExprs []ast.Expr) []ast.Expr {
 	return argExprs
 }
 
-func (fc *funcContext) translateArgs(sig *types.Signature, argExprs []ast.Expr, ellipsis bool) []string {
+// translateArgs translates call arguments according to the function signature.
+// If the called function is known statically, callee must be set to it, which
+// allows passing some of the arguments without cloning them.
+func (fc *funcContext) translateArgs(sig *types.Signature, argExprs []ast.Expr, ellipsis bool, callee *types.Func) []string {
 	argExprs = fc.expandTupleArgs(argExprs)
 
 	sigTypes := typesutil.Signature{Sig: sig}
@@ -157,14 +163,22 @@ func (fc *funcContext) translateArgs(sig *types.Signature, argExprs []ast.Expr,
 		return []string{fmt.Sprintf("%s.nil", fc.typeName(sigTypes.VariadicType()))}
 	}
 
+	// Blocking calls are hoisted out of the expression unless they are
+	// translated into yield* expressions, in which case the evaluation order is
+	// preserved as is.
 	preserveOrder := false
-	for i := 1; i < len(argExprs); i++ {
+	for i := 1; i < len(argExprs) && !fc.pkgCtx.opts.Generators; i++ {
 		preserveOrder = preserveOrder || fc.Blocking[argExprs[i]]
 	}
 
 	args := make([]string, len(argExprs))
 	for i, argExpr := range argExprs {
-		arg := fc.translateImplicitConversionWithCloning(argExpr, sigTypes.Param(i, ellipsis)).String()
+		var arg string
+		if callee != nil && fc.pkgCtx.IsReadOnlyParam(callee, i) && fc.isStableValue(argExpr) {
+			arg = fc.translateImplicitConversion(argExpr, sigTypes.Param(i, ellipsis)).String()
+		} else {
+			arg = fc.translateImplicitConversionWithCloning(argExpr, sigTypes.Param(i, ellipsis)).String()
+		}
 
 		if preserveOrder && fc.pkgCtx.Types[argExpr].Value == nil {
 			argVar := fc.newLocalVariable("_arg")
@@ -202,7 +216,7 @@ func (fc *funcContext) translateSelection(sel typesutil.Selection, pos token.Pos
 		if jsTag := getJsTag(s.Tag(index)); jsTag != "" {
 			jsFieldName := s.Field(index).Name()
 			for {
-				fields = append(fields, fieldName(s, 0))
+				fields = append(fields, fc.fieldName(s, 0))
 				ft := fc.fieldType(s, 0)
 				if typesutil.IsJsObject(ft) {
 					return fields, jsTag
@@ -219,7 +233,7 @@ func (fc *funcContext) translateSelection(sel typesutil.Selection, pos token.Pos
 				}
 			}
 		}
-		fields = append(fields, fieldName(s, index))
+		fields = append(fields, fc.fieldName(s, index))
 		t = fc.fieldType(s, index)
 	}
 	return fields, ""
@@ -228,6 +242,10 @@ func (fc *funcContext) translateSelection(sel typesutil.Selection, pos token.Pos
 var nilObj = types.Universe.Lookup("nil")
 
 func (fc *funcContext) zeroValue(ty types.Type) ast.Expr {
+	if _, ok := ty.(*types.TypeParam); ok {
+		// Only possible within shared generic functions.
+		return fc.newIdent(fc.typeName(ty)+".zero()", ty)
+	}
 	switch t := ty.Underlying().(type) {
 	case *types.Basic:
 		switch {
@@ -286,7 +304,7 @@ func (fc *funcContext) newVariable(name string, pkgLevel bool) string {
 		panic("newVariable: empty name")
 	}
 	name = encodeIdent(name)
-	if fc.pkgCtx.minify {
+	if fc.pkgCtx.opts.Minify {
 		i := 0
 		for {
 			offset := int('a')
@@ -308,6 +326,60 @@ func (fc *funcContext) newVariable(name string, pkgLevel bool) string {
 			i++
 		}
 	}
+	return fc.declareVariable(name, pkgLevel)
+}
+
+// newPkgVariable assigns a new JavaScript variable name for a package-level
+// variable, like newVariable does.
+//
+// The key must identify the variable within the package regardless of the
+// order in which variables are allocated, e.g. by a linking name. With
+// Options.StableNames the minified name is derived from a hash of the key, so
+// that adding or removing unrelated declarations doesn't rename the variable.
+func (fc *funcContext) newPkgVariable(name, key string) string {
+	if !fc.pkgCtx.opts.Minify || !fc.pkgCtx.opts.StableNames {
+		return fc.newVariable(name, true)
+	}
+	root := fc.root()
+	for i := 0; ; i++ {
+		name = stableName(key, i)
+		if fc.allVars[name] == 0 && root.allVars[name] == 0 {
+			break
+		}
+	}
+	return fc.declareVariable(name, true)
+}
+
+// stableName returns a minified package-level variable name derived from the
+// hash of the key. The attempt number is mixed into the hash to resolve
+// collisions.
+//
+// The names consist of an upper case letter, a digit and two alphanumeric
+// characters. They don't collide with minified local variable names, which are
+// lower case, nor with JavaScript keywords and globals, none of which has a
+// digit as the second character.
+func stableName(key string, attempt int) string {
+	const chars = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	if attempt > 0 {
+		fmt.Fprintf(h, "#%d", attempt)
+	}
+	n := h.Sum64()
+	name := []byte{byte('A' + n%26), byte('0' + n/26%10)}
+	n /= 26 * 10
+	for i := 0; i < 2; i++ {
+		name = append(name, chars[n%uint64(len(chars))])
+		n /= uint64(len(chars))
+	}
+	return string(name)
+}
+
+// declareVariable records a JavaScript variable name chosen by newVariable or
+// newPkgVariable and returns it, suffixed with a number if the name is
+// already taken.
+func (fc *funcContext) declareVariable(name string, pkgLevel bool) string {
 	n := fc.allVars[name]
 	fc.allVars[name] = n + 1
 	varName := name
@@ -326,6 +398,12 @@ func (fc *funcContext) newVariable(name string, pkgLevel bool) string {
 	return varName
 }
 
+// markBlockingCall marks a call synthesized by the compiler as blocking.
+func (fc *funcContext) markBlockingCall(call ast.Expr) {
+	fc.Blocking[call] = true
+	fc.BlockingOps[call] = true
+}
+
 // newIdent declares a new Go variable with the given name and type and returns
 // an *ast.Ident referring to that object.
 func (fc *funcContext) newIdent(name string, t types.Type) *ast.Ident {
@@ -393,6 +471,51 @@ func isVarOrConst(o types.Object) bool {
 	return false
 }
 
+// stableValueRoot returns the function-level variable whose own storage holds
+// the value of the expression, if the expression is a variable, a field of it
+// or an array element, such as `x.a.b[i]`. Struct fields accessed through a
+// pointer are not included.
+func (fc *funcContext) stableValueRoot(e ast.Expr) (*types.Var, bool) {
+	for {
+		switch x := astutil.RemoveParens(e).(type) {
+		case *ast.SelectorExpr:
+			sel, ok := fc.selectionOf(x)
+			if !ok || sel.Kind() != types.FieldVal || len(sel.Index()) != 1 {
+				return nil, false
+			}
+			if _, isStruct := fc.typeOf(x.X).Underlying().(*types.Struct); !isStruct {
+				return nil, false
+			}
+			e = x.X
+		case *ast.IndexExpr:
+			if _, isArray := fc.typeOf(x.X).Underlying().(*types.Array); !isArray {
+				return nil, false
+			}
+			e = x.X
+		default:
+			v, ok := fc.localVarOf(e)
+			if !ok || fc.pkgCtx.escapingVars[v] {
+				return nil, false
+			}
+			switch v.Type().Underlying().(type) {
+			case *types.Struct, *types.Array:
+				return v, true
+			}
+			return nil, false
+		}
+	}
+}
+
+// isStableValue returns true if the expression refers to a struct or array
+// value that can't be modified by anything other than the current function.
+// Such value may be shared with a read-only parameter of a called function
+// without cloning it, since the current function is suspended until the call
+// returns.
+func (fc *funcContext) isStableValue(e ast.Expr) bool {
+	v, ok := fc.stableValueRoot(e)
+	return ok && !fc.pkgCtx.IsExposed(v)
+}
+
 func isPkgLevel(o types.Object) bool {
 	// Note: named types are always assigned a variable at package level to be
 	// initialized with the rest of the package types, even the types declared
@@ -435,7 +558,11 @@ func (fc *funcContext) objectName(o types.Object) string {
 	name, ok := fc.assignedObjectName(o)
 	if !ok {
 		pkgLevel := isPkgLevel(o)
-		name = fc.newVariable(o.Name(), pkgLevel)
+		if pkgLevel {
+			name = fc.newPkgVariable(o.Name(), symbol.New(o).String())
+		} else {
+			name = fc.newVariable(o.Name(), false)
+		}
 		if pkgLevel {
 			fc.root().objectNames[o] = name
 		} else {
@@ -482,6 +609,9 @@ func (fc *funcContext) methodName(fun *types.Func) string {
 	if fun.Type().(*types.Signature).Recv() == nil {
 		panic(fmt.Errorf("expected a method, got a standalone function %v", fun))
 	}
+	if fc.mangles(fun.Pkg(), fun.Name()) {
+		return mangledProp(fun.Pkg().Path(), fun.Name())
+	}
 	// Method names are scoped to their receiver type and guaranteed to be
 	// unique within that, so we only need to make sure it's not a reserved keyword
 	return sanitizeName(fun.Name())
@@ -497,7 +627,7 @@ func (fc *funcContext) varPtrName(o *types.Var) string {
 		// so use the package level varPtrNames.
 		name, ok := fc.pkgCtx.varPtrNames[o]
 		if !ok {
-			name = fc.newVariable(o.Name()+"$ptr", true)
+			name = fc.newPkgVariable(o.Name()+"$ptr", symbol.New(o).String()+"$ptr")
 			fc.pkgCtx.varPtrNames[o] = name
 		}
 		return name
@@ -551,9 +681,18 @@ func (fc *funcContext) typeName(ty types.Type) string {
 			return "$emptyInterface"
 		}
 	case *types.TypeParam:
+		if i := fc.dictIndex(t); i >= 0 {
+			return fmt.Sprintf("$dict[%d]", i)
+		}
 		panic(fmt.Errorf("unexpected type parameter: %v", t))
 	}
 
+	if fc.dictParams != nil && typeparams.ContainsTypeParams(ty) {
+		// Types that depend on the dictionary can't be declared at the package
+		// level, so they are constructed at runtime, which caches them.
+		return fmt.Sprintf("$%sType(%s)", strings.ToLower(typeKind(ty)[5:]), fc.initArgs(ty))
+	}
+
 	// For anonymous composite types, generate a synthetic package-level type
 	// declaration, which will be reused for all instances of this type. This
 	// improves performance, since runtime won't have to synthesize the same type
@@ -561,17 +700,52 @@ func (fc *funcContext) typeName(ty types.Type) string {
 	anonType, ok := fc.pkgCtx.anonTypeMap.At(ty).(*types.TypeName)
 	if !ok {
 		fc.initArgs(ty) // cause all embedded types to be registered
-		varName := fc.newVariable(strings.ToLower(typeKind(ty)[5:])+"Type", true)
+		varName := fc.newPkgVariable(strings.ToLower(typeKind(ty)[5:])+"Type", "type:"+types.TypeString(ty, nil))
 		anonType = types.NewTypeName(token.NoPos, fc.pkgCtx.Pkg, varName, ty) // fake types.TypeName
 		fc.pkgCtx.anonTypes = append(fc.pkgCtx.anonTypes, anonType)
 		fc.pkgCtx.anonTypeMap.Set(ty, anonType)
+		if fc.pkgCtx.opts.LazyTypes {
+			fc.pkgCtx.anonTypeInits[anonType] = fc.newPkgVariable(varName+"Init", "typeinit:"+types.TypeString(ty, nil))
+		}
 	}
 	// Since anonymous types are always package-level so they can be shared,
 	// don't pass in the function context (nest type parameters) to the DCE.
 	fc.pkgCtx.DeclareDCEDep(anonType, nil, nil)
+	if init, ok := fc.pkgCtx.anonTypeInits[anonType]; ok {
+		// The variable is unset until the function constructing the type is
+		// called by the first use, see anonTypeDecls.
+		return "(" + anonType.Name() + " || " + init + "())"
+	}
 	return anonType.Name()
 }
 
+// reflectType records that values of the given type may be exposed to the
+// reflection by the code being translated, so the type and the types it
+// consists of need the full reflection metadata. See Options.StripReflection.
+func (fc *funcContext) reflectType(ty types.Type) {
+	if !fc.pkgCtx.opts.StripReflection {
+		return
+	}
+	if _, ok := ty.(*types.Basic); ok || types.IsInterface(ty) {
+		// Built-in basic types have no metadata to strip and dynamic types of
+		// interface values are recorded when they are converted to interfaces.
+		return
+	}
+	fc.pkgCtx.CollectDCEReflectDeps(func() { fc.typeName(ty) })
+}
+
+// dictIndex returns the index of the type argument for the given type
+// parameter in the runtime dictionary of a shared generic function, or -1 if
+// the type parameter is not passed in the dictionary.
+func (fc *funcContext) dictIndex(t *types.TypeParam) int {
+	for i := 0; i < fc.dictParams.Len(); i++ {
+		if fc.dictParams.At(i) == t {
+			return i
+		}
+	}
+	return -1
+}
+
 // importedPkgVar returns a package-level variable name for accessing an imported
 // package.
 //
@@ -586,7 +760,7 @@ func (fc *funcContext) importedPkgVar(pkg *types.Package) string {
 		return pkgVar // Already registered.
 	}
 
-	pkgVar := fc.newVariable(pkg.Name(), true)
+	pkgVar := fc.newPkgVariable(pkg.Name(), "import:"+pkg.Path())
 	fc.pkgCtx.pkgVars[pkg.Path()] = pkgVar
 	return pkgVar
 }
@@ -668,6 +842,17 @@ func (fc *funcContext) handleEscapingVars(n ast.Node) {
 	}
 }
 
+// fieldName returns a JS property name corresponding to the i-th field of the
+// struct, which is mangled with Options.MangleProps unless the field has a `js`
+// struct tag.
+func (fc *funcContext) fieldName(t *types.Struct, i int) string {
+	field := t.Field(i)
+	if fc.mangles(field.Pkg(), field.Name()) && getJsTag(t.Tag(i)) == "" {
+		return mangledProp(field.Pkg().Path(), field.Name())
+	}
+	return fieldName(t, i)
+}
+
 func fieldName(t *types.Struct, i int) string {
 	name := t.Field(i).Name()
 	if name == "_" || reservedKeywords[name] {
@@ -701,6 +886,36 @@ func typeKind(ty types.Type) string {
 	}
 }
 
+// nativeArrayType returns the name of the JS typed array constructor used to
+// store arrays of the given element type, or an empty string if they are stored
+// in regular JS arrays. It must match $nativeArray in the prelude.
+func nativeArrayType(elem types.Type) string {
+	basic, ok := elem.Underlying().(*types.Basic)
+	if !ok {
+		return ""
+	}
+	switch toJavaScriptType(basic) {
+	case "Int", "Int32":
+		return "Int32Array"
+	case "Int8":
+		return "Int8Array"
+	case "Int16":
+		return "Int16Array"
+	case "Uint", "Uint32", "Uintptr":
+		return "Uint32Array"
+	case "Uint8":
+		return "Uint8Array"
+	case "Uint16":
+		return "Uint16Array"
+	case "Float32":
+		return "Float32Array"
+	case "Float64":
+		return "Float64Array"
+	default:
+		return ""
+	}
+}
+
 func toJavaScriptType(t *types.Basic) string {
 	switch t.Kind() {
 	case types.UntypedInt:
@@ -892,8 +1107,10 @@ func removeWhitespace(b []byte, minify bool) []byte {
 	return out
 }
 
-func rangeCheck(pattern string, constantIndex, array bool) string {
-	if constantIndex && array {
+// rangeCheck wraps the pattern of an index expression into an index bounds
+// check, unless the check is unnecessary or disabled with Options.Unchecked.
+func (fc *funcContext) rangeCheck(pattern string, constantIndex, array bool) string {
+	if (constantIndex && array) || fc.pkgCtx.opts.Unchecked {
 		return pattern
 	}
 	lengthProp := "$length"
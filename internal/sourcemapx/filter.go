@@ -18,6 +18,13 @@ type (
 	jsMappingCallbackHandle func(isolated *sourcemap.Mapping)
 )
 
+// supportedFeatures adjusts the JavaScript features esbuild assumes for the
+// ES2015 target. Unless async generators are supported, esbuild lowers the
+// yield* expressions of all generators into calls of a helper that supports
+// async iterators, while generator code generation delegates every blocking
+// call with yield*. GopherJS doesn't generate async generators.
+var supportedFeatures = map[string]bool{"async-generator": true}
+
 // Filter implements io.Writer which extracts source map hints from the written
 // stream and passed them to the MappingCallback if it's not nil. Encoded hints
 // are always filtered out of the output stream.
@@ -27,6 +34,11 @@ type Filter struct {
 	goMappingCallback goMappingCallbackHandle
 	jsMappingCallback jsMappingCallbackHandle
 
+	// Rename, if not nil, is applied to the written code between the hints
+	// before it's passed to the Writer, e.g. to substitute placeholders that
+	// can only be resolved when the whole program is linked.
+	Rename func([]byte) []byte
+
 	m        *sourcemap.Map
 	goroot   string
 	gopath   string
@@ -49,6 +61,12 @@ func (f *Filter) IsMapping() bool {
 	return f.goMappingCallback != nil || f.jsMappingCallback != nil
 }
 
+// SourceMap returns the source map collected by the filter, or nil if mapping
+// isn't enabled.
+func (f *Filter) SourceMap() *sourcemap.Map {
+	return f.m
+}
+
 func (f *Filter) WriteMappingTo(w io.Writer) error {
 	return f.m.WriteTo(w)
 }
@@ -62,7 +80,13 @@ func (f *Filter) Write(p []byte) (n int, err error) {
 			w = p[:i]
 		}
 
-		n2, err = f.Writer.Write(w)
+		if f.Rename != nil {
+			n2 = len(w)
+			w = f.Rename(w)
+			_, err = f.Writer.Write(w)
+		} else {
+			n2, err = f.Writer.Write(w)
+		}
 		n += n2
 		for {
 			i := bytes.IndexByte(w, '\n')
@@ -106,6 +130,7 @@ func (f *Filter) WriteJS(jsSource, jsFilePath string, minify bool) (n int, err e
 
 	options := api.TransformOptions{
 		Target:         api.ES2015,
+		Supported:      supportedFeatures,
 		Charset:        api.CharsetUTF8,
 		LegalComments:  api.LegalCommentsEndOfFile,
 		JSX:            api.JSXPreserve,
@@ -0,0 +1,126 @@
+package sourcemapx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/neelance/sourcemap"
+	log "github.com/sirupsen/logrus"
+)
+
+// Optimize runs the complete generated program through esbuild's minifier,
+// which removes whitespace, shortens identifiers and simplifies syntax across
+// the whole program rather than within individual declarations.
+//
+// If goMap is not nil, it must be the source map of the code, and the returned
+// source map is composed of it and the esbuild's source map, so that it maps
+// the optimized code back to the original Go and JS sources.
+func Optimize(code []byte, goMap *sourcemap.Map) ([]byte, *sourcemap.Map, error) {
+	options := api.TransformOptions{
+		Target:            api.ES2015,
+		Supported:         supportedFeatures,
+		Charset:           api.CharsetUTF8,
+		LegalComments:     api.LegalCommentsEndOfFile,
+		MinifyWhitespace:  true,
+		MinifyIdentifiers: true,
+		MinifySyntax:      true,
+		// The runtime recognizes some prelude functions in stack traces by
+		// their names, e.g. $panic and $goroutine.
+		KeepNames: true,
+	}
+	if goMap != nil {
+		options.Sourcefile = goMap.File
+		options.Sourcemap = api.SourceMapExternal
+		options.SourcesContent = api.SourcesContentExclude
+	}
+
+	result := api.Transform(string(code), options)
+	for _, w := range result.Warnings {
+		log.Warnf("%d:%d: %s\n%s\n", w.Location.Line, w.Location.Column, w.Text, w.Location.LineText)
+	}
+	if len(result.Errors) > 0 {
+		var errs []error
+		for _, e := range result.Errors {
+			errs = append(errs, fmt.Errorf("%d:%d: %s", e.Location.Line, e.Location.Column, e.Text))
+		}
+		return nil, nil, fmt.Errorf("JS optimization failed with %d errors: %w", len(errs), errors.Join(errs...))
+	}
+
+	if goMap == nil {
+		return result.Code, nil, nil
+	}
+	jsMap, err := sourcemap.ReadFrom(bytes.NewReader(result.Map))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read source map: %w", err)
+	}
+	return result.Code, Compose(jsMap, goMap), nil
+}
+
+// Compose returns a source map that maps the code generated from the code
+// described by inner to the sources of inner, i.e. applies outer and then
+// inner to each position.
+//
+// A position that outer maps into the middle of a segment of inner is mapped
+// to the beginning of that segment. Original names are taken from inner and
+// kept if the position is at the exact start of the named segment.
+//
+// Besides, the GopherJS runtime finds the function enclosing a position by
+// the nearest preceding named segment (see $resolveCallFrame in the prelude).
+// To preserve that, a composed segment is also named if the nearest preceding
+// named segment of its target in inner has a different name than the last
+// named composed segment.
+func Compose(outer, inner *sourcemap.Map) *sourcemap.Map {
+	innerMappings := append([]*sourcemap.Mapping(nil), inner.DecodedMappings()...)
+	sort.SliceStable(innerMappings, func(i, j int) bool {
+		a, b := innerMappings[i], innerMappings[j]
+		return a.GeneratedLine < b.GeneratedLine || (a.GeneratedLine == b.GeneratedLine && a.GeneratedColumn < b.GeneratedColumn)
+	})
+	enclosing := make([]string, len(innerMappings))
+	for i, m := range innerMappings {
+		if m.OriginalName != "" && m.OriginalFile != "" {
+			enclosing[i] = m.OriginalName
+		} else if i > 0 {
+			enclosing[i] = enclosing[i-1]
+		}
+	}
+
+	// lookup finds the index of the last segment of inner that starts at or
+	// before the given position in the intermediate code, or -1.
+	lookup := func(line, column int) int {
+		i := sort.Search(len(innerMappings), func(i int) bool {
+			m := innerMappings[i]
+			return m.GeneratedLine > line || (m.GeneratedLine == line && m.GeneratedColumn > column)
+		})
+		if i == 0 || innerMappings[i-1].GeneratedLine != line {
+			return -1
+		}
+		return i - 1
+	}
+
+	composed := &sourcemap.Map{File: inner.File}
+	lastName := ""
+	for _, m := range outer.DecodedMappings() {
+		mapping := &sourcemap.Mapping{GeneratedLine: m.GeneratedLine, GeneratedColumn: m.GeneratedColumn}
+		if m.OriginalFile != "" {
+			if i := lookup(m.OriginalLine, m.OriginalColumn); i != -1 && innerMappings[i].OriginalFile != "" {
+				target := innerMappings[i]
+				mapping.OriginalFile = target.OriginalFile
+				mapping.OriginalLine = target.OriginalLine
+				mapping.OriginalColumn = target.OriginalColumn
+				if target.GeneratedColumn == m.OriginalColumn && target.OriginalName != "" {
+					mapping.OriginalName = target.OriginalName
+				} else if enclosing[i] != lastName {
+					mapping.OriginalName = enclosing[i]
+				}
+				if mapping.OriginalName != "" {
+					lastName = mapping.OriginalName
+				}
+			}
+		}
+		composed.AddMapping(mapping)
+	}
+	return composed
+}
@@ -0,0 +1,92 @@
+package sourcemapx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/neelance/sourcemap"
+)
+
+func TestCompose(t *testing.T) {
+	inner := &sourcemap.Map{File: "out.js"}
+	inner.AddMapping(&sourcemap.Mapping{GeneratedLine: 1, GeneratedColumn: 0, OriginalFile: "foo.go", OriginalLine: 3, OriginalColumn: 1})
+	inner.AddMapping(&sourcemap.Mapping{GeneratedLine: 1, GeneratedColumn: 10, OriginalFile: "foo.go", OriginalLine: 4, OriginalColumn: 2, OriginalName: "main.Foo"})
+	inner.AddMapping(&sourcemap.Mapping{GeneratedLine: 1, GeneratedColumn: 20, OriginalFile: "foo.go", OriginalLine: 5, OriginalColumn: 2})
+	inner.AddMapping(&sourcemap.Mapping{GeneratedLine: 1, GeneratedColumn: 30, OriginalFile: "foo.go", OriginalLine: 6, OriginalColumn: 2, OriginalName: "main.Bar"})
+	inner.AddMapping(&sourcemap.Mapping{GeneratedLine: 1, GeneratedColumn: 40, OriginalFile: "foo.go", OriginalLine: 7, OriginalColumn: 2})
+	inner.AddMapping(&sourcemap.Mapping{GeneratedLine: 2, GeneratedColumn: 4})
+
+	outer := &sourcemap.Map{File: "out.js"}
+	outer.AddMapping(&sourcemap.Mapping{GeneratedLine: 1, GeneratedColumn: 0, OriginalFile: "out.js", OriginalLine: 1, OriginalColumn: 5})
+	outer.AddMapping(&sourcemap.Mapping{GeneratedLine: 1, GeneratedColumn: 3, OriginalFile: "out.js", OriginalLine: 1, OriginalColumn: 10, OriginalName: "foo$1"})
+	outer.AddMapping(&sourcemap.Mapping{GeneratedLine: 1, GeneratedColumn: 6, OriginalFile: "out.js", OriginalLine: 2, OriginalColumn: 8})
+	outer.AddMapping(&sourcemap.Mapping{GeneratedLine: 1, GeneratedColumn: 9, OriginalFile: "out.js", OriginalLine: 3, OriginalColumn: 0})
+	outer.AddMapping(&sourcemap.Mapping{GeneratedLine: 1, GeneratedColumn: 12, OriginalFile: "out.js", OriginalLine: 1, OriginalColumn: 22})
+	outer.AddMapping(&sourcemap.Mapping{GeneratedLine: 1, GeneratedColumn: 14, OriginalFile: "out.js", OriginalLine: 1, OriginalColumn: 41})
+
+	got := Compose(outer, inner)
+	want := []*sourcemap.Mapping{
+		{GeneratedLine: 1, GeneratedColumn: 0, OriginalFile: "foo.go", OriginalLine: 3, OriginalColumn: 1},
+		{GeneratedLine: 1, GeneratedColumn: 3, OriginalFile: "foo.go", OriginalLine: 4, OriginalColumn: 2, OriginalName: "main.Foo"},
+		{GeneratedLine: 1, GeneratedColumn: 6},
+		{GeneratedLine: 1, GeneratedColumn: 9},
+		// The enclosing name is unchanged since the last named segment.
+		{GeneratedLine: 1, GeneratedColumn: 12, OriginalFile: "foo.go", OriginalLine: 5, OriginalColumn: 2},
+		// The enclosing name changed, even though main.Bar is skipped.
+		{GeneratedLine: 1, GeneratedColumn: 14, OriginalFile: "foo.go", OriginalLine: 7, OriginalColumn: 2, OriginalName: "main.Bar"},
+	}
+	if diff := cmp.Diff(want, got.DecodedMappings()); diff != "" {
+		t.Errorf("Composed mappings differ from expected (-want,+got):\n%s", diff)
+	}
+	if got.File != "out.js" {
+		t.Errorf("Got composed map file %q, want %q.", got.File, "out.js")
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	code := "(function() {\n\tvar longName = 1;\n\tconsole.log(longName + 1);\n}).call(this);\n"
+
+	optimized, m, err := Optimize([]byte(code), nil)
+	if err != nil {
+		t.Fatalf("Got: Optimize() returned error: %s. Want: no error.", err)
+	}
+	if m != nil {
+		t.Errorf("Got: Optimize() returned a source map without the input map. Want: nil.")
+	}
+	if got := string(optimized); strings.Contains(got, "longName") || len(got) >= len(code) {
+		t.Errorf("Got optimized code %q, want identifiers and whitespace minified.", got)
+	}
+
+	goMap := &sourcemap.Map{File: "out.js"}
+	goMap.AddMapping(&sourcemap.Mapping{GeneratedLine: 3, GeneratedColumn: 1, OriginalFile: "foo.go", OriginalLine: 7, OriginalColumn: 2})
+	_, m, err = Optimize([]byte(code), goMap)
+	if err != nil {
+		t.Fatalf("Got: Optimize() returned error: %s. Want: no error.", err)
+	}
+	found := false
+	for _, mapping := range m.DecodedMappings() {
+		if mapping.OriginalFile == "foo.go" && mapping.OriginalLine == 7 {
+			found = true
+		}
+		if mapping.OriginalFile != "" && mapping.OriginalFile != "foo.go" {
+			t.Errorf("Got mapping to %q, want only mappings to foo.go.", mapping.OriginalFile)
+		}
+	}
+	if !found {
+		t.Errorf("Got mappings %v, want a mapping to foo.go:7.", m.DecodedMappings())
+	}
+
+	if _, _, err := Optimize([]byte("var x = ;"), nil); err == nil {
+		t.Errorf("Got: Optimize() returned no error for invalid code. Want: an error.")
+	}
+
+	generator := "(function* () {\n\tyield* g();\n})().next();\n"
+	optimized, _, err = Optimize([]byte(generator), nil)
+	if err != nil {
+		t.Fatalf("Got: Optimize() returned error: %s. Want: no error.", err)
+	}
+	if !strings.Contains(string(optimized), "yield*") {
+		t.Errorf("Got optimized code %q. Want the yield* expression kept as is.", optimized)
+	}
+}
@@ -72,6 +72,10 @@ type TestMain struct {
 	Fuzz       []TestFunc
 	Examples   []ExampleFunc
 	TestMain   *TestFunc
+
+	// DetectLeaks makes tests fail if goroutines they created are still
+	// blocked after they return.
+	DetectLeaks bool
 }
 
 // Scan package for tests functions.
@@ -258,6 +262,12 @@ import (
 {{end}}
 	"testing"
 	"testing/internal/testdeps"
+{{if .DetectLeaks}}
+	"runtime"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+{{end}}
 
 {{if .ImportTest}}
 	{{if .ExecutesTest}}_test{{else}}_{{end}} {{.Package.ImportPath | printf "%q"}}
@@ -269,8 +279,12 @@ import (
 
 var tests = []testing.InternalTest{
 {{- range .Tests}}
+{{- if $.DetectLeaks}}
+	{"{{.Name}}", detectLeaks({{.Location}}.{{.Name}})},
+{{- else}}
 	{"{{.Name}}", {{.Location}}.{{.Name}}},
 {{- end}}
+{{- end}}
 }
 
 var benchmarks = []testing.InternalBenchmark{
@@ -293,6 +307,35 @@ var examples = []testing.InternalExample{
 {{- end }}
 }
 
+{{if .DetectLeaks}}
+func init() {
+	// Goroutines record where they were created and blocked only on demand,
+	// and the failures of leaked goroutines show it.
+	js.Global.Set("$goroutineStacks", true)
+}
+
+// detectLeaks wraps a test to fail it if goroutines created while it ran are
+// still blocked after it returned, since they would never be scheduled again.
+func detectLeaks(test func(*testing.T)) func(*testing.T) {
+	return func(t *testing.T) {
+		first := js.Global.Get("$lastGoroutineId").Int() + 1
+		t.Cleanup(func() {
+			// The goroutines started or unblocked by the test run first, and
+			// the ones that are about to exit get a chance to do so.
+			runtime.Gosched()
+			leaked := js.Global.Call("$leakedGoroutines", first).String()
+			for i := 0; i < 10 && leaked != ""; i++ {
+				time.Sleep(10 * time.Millisecond)
+				leaked = js.Global.Call("$leakedGoroutines", first).String()
+			}
+			if leaked != "" {
+				t.Errorf("found goroutines that are still blocked after the test:\n\n%s", leaked)
+			}
+		})
+		test(t)
+	}
+}
+{{end}}
 func main() {
 	m := testing.MainStart(testdeps.TestDeps{}, tests, benchmarks, fuzzTargets, examples)
 {{with .TestMain}}
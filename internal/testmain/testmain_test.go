@@ -104,6 +104,16 @@ func TestSynthesize(t *testing.T) {
 				},
 			},
 			wantSrc: importOnly,
+		}, {
+			descr: "detect leaks",
+			tm: TestMain{
+				Package: pkg,
+				Tests: []TestFunc{
+					{Location: LocInPackage, Name: "TestXxx"},
+				},
+				DetectLeaks: true,
+			},
+			wantSrc: detectLeaks,
 		},
 	}
 
@@ -211,3 +221,60 @@ func main() {
 	os.Exit(m.Run())
 }
 `
+
+const detectLeaks = `package main
+
+import (
+	"os"
+
+	"testing"
+	"testing/internal/testdeps"
+
+	"runtime"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+
+	_test "foo/bar"
+)
+
+var tests = []testing.InternalTest{
+	{"TestXxx", detectLeaks(_test.TestXxx)},
+}
+
+var benchmarks = []testing.InternalBenchmark{}
+
+var fuzzTargets = []testing.InternalFuzzTarget{}
+
+var examples = []testing.InternalExample{}
+
+func init() {
+
+	js.Global.Set("$goroutineStacks", true)
+}
+
+func detectLeaks(test func(*testing.T)) func(*testing.T) {
+	return func(t *testing.T) {
+		first := js.Global.Get("$lastGoroutineId").Int() + 1
+		t.Cleanup(func() {
+
+			runtime.Gosched()
+			leaked := js.Global.Call("$leakedGoroutines", first).String()
+			for i := 0; i < 10 && leaked != ""; i++ {
+				time.Sleep(10 * time.Millisecond)
+				leaked = js.Global.Call("$leakedGoroutines", first).String()
+			}
+			if leaked != "" {
+				t.Errorf("found goroutines that are still blocked after the test:\n\n%s", leaked)
+			}
+		})
+		test(t)
+	}
+}
+
+func main() {
+	m := testing.MainStart(testdeps.TestDeps{}, tests, benchmarks, fuzzTargets, examples)
+
+	os.Exit(m.Run())
+}
+`
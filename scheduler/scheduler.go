@@ -0,0 +1,74 @@
+// Package scheduler controls when GopherJS runs goroutines.
+//
+// By default, GopherJS runs runnable goroutines as soon as the JavaScript event
+// loop lets it, for up to 4 milliseconds at a time, and then yields to the event
+// loop with setTimeout to let it process timers, I/O and rendering. Programs
+// that need a different policy, e.g. running goroutines only between animation
+// frames, can install a Driver with SetDriver.
+//
+// JavaScript code can install a driver as well, by setting the
+// gopherjsScheduler property of the global object to an object with a
+// request(drain) method and an optional budget property in milliseconds, which
+// correspond to the methods of Driver. A driver installed with SetDriver takes
+// precedence over it.
+package scheduler
+
+import (
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// Driver decides when and for how long runnable goroutines run.
+type Driver interface {
+	// Request is called when goroutines become runnable while none are
+	// running. The driver must call drain once, from a JavaScript callback
+	// after Request returns, e.g. from a requestAnimationFrame callback.
+	// Request is called without a goroutine of its own, so it must not block.
+	//
+	// drain runs goroutines until none are runnable or Budget has elapsed,
+	// and then calls Request again if goroutines are still runnable.
+	Request(drain func())
+
+	// Budget returns how long drain runs goroutines before it returns. It's
+	// called once by SetDriver.
+	Budget() time.Duration
+}
+
+// SetDriver replaces the driver that decides when goroutines run. A nil driver
+// restores the default policy, or the driver installed by JavaScript code.
+//
+// Goroutines that are runnable when the driver is replaced run when the new
+// driver calls drain, even if the previous driver was asked to call drain for
+// them and never does.
+func SetDriver(d Driver) {
+	if d == nil {
+		js.Global.Call("$setSchedulerDriver", nil)
+		return
+	}
+	driver := js.Global.Get("Object").New()
+	driver.Set("request", func(drain *js.Object) {
+		d.Request(func() { drain.Invoke() })
+	})
+	driver.Set("budget", float64(d.Budget())/float64(time.Millisecond))
+	js.Global.Call("$setSchedulerDriver", driver)
+}
+
+type animationFrame struct {
+	budget time.Duration
+}
+
+// AnimationFrame returns a driver that runs goroutines for up to budget before
+// each repaint of the browser, in requestAnimationFrame callbacks. Goroutines
+// don't run while the page is hidden.
+func AnimationFrame(budget time.Duration) Driver {
+	return animationFrame{budget: budget}
+}
+
+func (a animationFrame) Request(drain func()) {
+	js.Global.Call("requestAnimationFrame", func() { drain() })
+}
+
+func (a animationFrame) Budget() time.Duration {
+	return a.budget
+}
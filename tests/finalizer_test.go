@@ -0,0 +1,140 @@
+//go:build js && gopherjs
+
+package tests
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	syscalljs "syscall/js"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// collectGarbage runs garbage collections until done reports true or a timeout
+// expires, giving the finalizers a chance to run in between.
+func collectGarbage(t *testing.T, done func() bool) {
+	t.Helper()
+	if js.Global.Get("gc") == js.Undefined {
+		t.Skip("garbage collection isn't exposed by the JavaScript engine")
+	}
+	for start := time.Now(); !done(); {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("Got: no finalizers ran after garbage collections. Want: the finalizers of unreachable objects to run.")
+		}
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type finalizerObject struct {
+	name string
+	fd   int
+}
+
+func (f *finalizerObject) String() string { return f.name }
+
+func setFinalizers(finalized chan<- string) {
+	s := &finalizerObject{name: "struct"}
+	runtime.SetFinalizer(s, func(s *finalizerObject) {
+		time.Sleep(time.Millisecond) // Finalizers may block.
+		finalized <- s.name + " " + strconv.Itoa(s.fd)
+	})
+	s.fd = 42 // The finalizer sees changes made after it was set.
+
+	i := new(int)
+	runtime.SetFinalizer(i, func(i *int) { finalized <- "int " + strconv.Itoa(*i) })
+	*i = 7
+
+	a := &[2]int{1, 2}
+	runtime.SetFinalizer(a, func(a *[2]int) { finalized <- "array " + strconv.Itoa(a[0]) })
+	a[0] = 3
+
+	iface := &finalizerObject{name: "interface"}
+	runtime.SetFinalizer(iface, func(s fmt.Stringer) { finalized <- s.String() })
+
+	removed := &finalizerObject{name: "removed"}
+	runtime.SetFinalizer(removed, func(s *finalizerObject) { finalized <- s.name })
+	runtime.SetFinalizer(removed, nil)
+}
+
+func TestSetFinalizer(t *testing.T) {
+	finalized := make(chan string, 10)
+	setFinalizers(finalized)
+
+	got := map[string]bool{}
+	collectGarbage(t, func() bool {
+		for {
+			select {
+			case s := <-finalized:
+				got[s] = true
+			default:
+				return len(got) >= 4
+			}
+		}
+	})
+	want := map[string]bool{"struct 42": true, "int 7": true, "array 3": true, "interface": true}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Got finalizers run differ from expected (-want,+got):\n%s", diff)
+	}
+}
+
+func TestSetFinalizerErrors(t *testing.T) {
+	withFinalizer := &finalizerObject{}
+	runtime.SetFinalizer(withFinalizer, func(*finalizerObject) {})
+	defer runtime.SetFinalizer(withFinalizer, nil)
+
+	tests := []struct {
+		name      string
+		obj       any
+		finalizer any
+		want      string
+	}{
+		{name: "nil", obj: nil, finalizer: func(any) {}, want: "first argument is nil"},
+		{name: "nil pointer", obj: (*finalizerObject)(nil), finalizer: func(*finalizerObject) {}, want: "first argument is nil"},
+		{name: "not pointer", obj: finalizerObject{}, finalizer: func(*finalizerObject) {}, want: "first argument is tests.finalizerObject, not pointer"},
+		{name: "not function", obj: &finalizerObject{}, finalizer: 1, want: "second argument is int, not a function"},
+		{name: "wrong argument", obj: &finalizerObject{}, finalizer: func(*int) {}, want: "cannot pass *tests.finalizerObject to finalizer func(*int)"},
+		{name: "already set", obj: withFinalizer, finalizer: func(*finalizerObject) {}, want: "finalizer already set"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				err, ok := recover().(runtime.Error)
+				if !ok || !strings.Contains(err.Error(), "runtime.SetFinalizer: "+test.want) {
+					t.Errorf("Got: SetFinalizer() panicked with %v. Want: a runtime error containing %q.", err, test.want)
+				}
+			}()
+			runtime.SetFinalizer(test.obj, test.finalizer)
+		})
+	}
+}
+
+func exportedFunctions() int {
+	return js.Global.Get("$exportedFunctions").Int()
+}
+
+func leakFunc() {
+	syscalljs.FuncOf(func(syscalljs.Value, []syscalljs.Value) any { return nil })
+}
+
+func TestFuncOfAutoRelease(t *testing.T) {
+	before := exportedFunctions()
+	f := syscalljs.FuncOf(func(syscalljs.Value, []syscalljs.Value) any { return nil })
+	f.Release()
+	f.Release() // Releasing a function again has no effect.
+	if got := exportedFunctions(); got != before {
+		t.Errorf("Got %d exported functions after Release(). Want: %d.", got, before)
+	}
+
+	leakFunc()
+	if got := exportedFunctions(); got != before+1 {
+		t.Errorf("Got %d exported functions after FuncOf(). Want: %d.", got, before+1)
+	}
+	collectGarbage(t, func() bool { return exportedFunctions() == before })
+}
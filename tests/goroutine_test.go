@@ -147,6 +147,88 @@ func TestPanicIssue1030(t *testing.T) {
 	}
 }
 
+// openDeferLog records the order of events in functions with open-coded
+// deferred calls. These functions must be non-blocking, so they don't use
+// t.Errorf and friends.
+var openDeferLog []string
+
+func openDeferRecord(s string) { openDeferLog = append(openDeferLog, s) }
+
+func openDeferOrder(skip bool) {
+	defer openDeferRecord("first")
+	if !skip {
+		defer openDeferRecord("second")
+	}
+	defer openDeferRecord("third")
+}
+
+func openDeferInner() {
+	defer openDeferRecord("inner")
+	panic("boom")
+}
+
+func openDeferMiddle() {
+	defer func() { openDeferRecord("middle") }()
+	openDeferInner()
+}
+
+func openDeferOuter() (err any) {
+	defer func() {
+		err = recover()
+		openDeferRecord("outer")
+	}()
+	openDeferMiddle()
+	return nil
+}
+
+func openDeferPanicInDefer() (result string) {
+	defer func() { result = recover().(string) }()
+	defer openDeferRecord("after panicking defer")
+	defer panic("from defer")
+	return "normal"
+}
+
+func TestOpenCodedDefer(t *testing.T) {
+	tests := []struct {
+		name    string
+		run     func() any
+		want    any
+		wantLog []string
+	}{
+		{
+			name:    "order",
+			run:     func() any { openDeferOrder(false); return nil },
+			wantLog: []string{"third", "second", "first"},
+		}, {
+			name:    "conditional",
+			run:     func() any { openDeferOrder(true); return nil },
+			wantLog: []string{"third", "first"},
+		}, {
+			name:    "panic through frames",
+			run:     func() any { return openDeferOuter() },
+			want:    "boom",
+			wantLog: []string{"inner", "middle", "outer"},
+		}, {
+			name:    "panic in deferred call",
+			run:     func() any { return openDeferPanicInDefer() },
+			want:    "from defer",
+			wantLog: []string{"after panicking defer"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			openDeferLog = nil
+			if got := test.run(); got != test.want {
+				t.Errorf("Got result %v, want %v.", got, test.want)
+			}
+			if got := fmt.Sprint(openDeferLog); got != fmt.Sprint(test.wantLog) {
+				t.Errorf("Got deferred calls %s, want %s.", got, fmt.Sprint(test.wantLog))
+			}
+		})
+	}
+}
+
 func TestSelect(t *testing.T) {
 	expectedI = 1
 	a := make(chan int)
@@ -217,6 +299,27 @@ func TestReturnWithBlockingDefer(t *testing.T) {
 	}
 }
 
+func TestTimers(t *testing.T) {
+	// Timer callbacks are Go functions called from JavaScript, which must run
+	// with any code generation mode.
+	fired := make(chan bool)
+	time.AfterFunc(time.Millisecond, func() { fired <- true })
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("time.AfterFunc() didn't call its function.")
+	}
+
+	timer := time.NewTimer(time.Millisecond)
+	<-timer.C
+
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for i := 0; i < 3; i++ {
+		<-ticker.C
+	}
+}
+
 func BenchmarkGoroutineSwitching(b *testing.B) {
 	// This benchmark is designed to measure the cost of goroutine switching.
 	// The two goroutines communicate through an unbuffered channel, which forces
@@ -239,6 +342,17 @@ func BenchmarkGoroutineSwitching(b *testing.B) {
 	}
 }
 
+func BenchmarkGoroutineCreation(b *testing.B) {
+	// This benchmark measures the cost of go statements, the other hot path of
+	// the scheduler besides goroutine switching. Each goroutine runs before the
+	// next one is created, so that the run queue stays short.
+	c := make(chan bool)
+	for i := 0; i < b.N; i++ {
+		go func() { c <- true }()
+		<-c
+	}
+}
+
 func TestEventLoopStarvation(t *testing.T) {
 	// See: https://github.com/gopherjs/gopherjs/issues/1078.
 	c := make(chan bool)
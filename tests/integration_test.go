@@ -1,6 +1,12 @@
 package tests_test
 
-import "testing"
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
 
 // Test_JSReservedWords uses testdata/reserved/main.go
 // to test that JS reserved words can be used as labels, variable names, etc.
@@ -23,3 +29,73 @@ func Test_ProxyMethod(t *testing.T) { runOutputTest(t, `testdata`, `proxyMethod`
 // to test that the package level type names do not conflict with function level
 // variable names when the code is minified.
 func Test_MinifyNaming(t *testing.T) { runOutputTest(t, `testdata`, `minifyNaming`, `-m`) }
+
+// Test_StableNames uses testdata/minifyNaming/main.go to test that deriving
+// minified package-level names from hashes doesn't introduce name collisions.
+func Test_StableNames(t *testing.T) {
+	runOutputTest(t, `testdata`, `minifyNaming`, `-m`, `--stable_names`)
+}
+
+// Test_Checked and Test_Unchecked use testdata/unchecked/main.go
+// to test that omitting index bounds, nil pointer and division by zero checks
+// doesn't change the behavior of code for which none of the checks fail.
+func Test_Checked(t *testing.T) { runOutputTest(t, `testdata`, `unchecked`) }
+
+func Test_Unchecked(t *testing.T) { runOutputTest(t, `testdata`, `unchecked`, `--unchecked`) }
+
+// Test_LazyTypes uses testdata/lazytypes/main.go
+// to test that constructing anonymous types and method lists on first use
+// doesn't change the behavior of type assertions, method promotion and reflection.
+func Test_LazyTypes(t *testing.T) { runOutputTest(t, `testdata`, `lazytypes`, `--lazy_types`) }
+
+// Test_MangleProps uses testdata/mangleprops/main.go
+// to test that mangling unexported field and method names doesn't change the
+// behavior of method calls, interfaces, reflection and `js` struct tags.
+func Test_MangleProps(t *testing.T) {
+	runOutputTest(t, `testdata`, `mangleprops`, `-m`, `--mangle_props`)
+}
+
+// Test_Optimize uses testdata/mangleprops/main.go to test that running the
+// linked program through esbuild's minifier doesn't change its behavior.
+func Test_Optimize(t *testing.T) {
+	runOutputTest(t, `testdata`, `mangleprops`, `-m`, `--mangle_props`, `--optimize`, `-q`)
+}
+
+// Benchmark_Startup measures the time it takes to load and run the program in
+// testdata/lazytypes/main.go with eager and lazy type setup, and reports how
+// much faster the lazy type setup is than the default eager one.
+func Benchmark_Startup(b *testing.B) {
+	if runtime.GOOS == `js` {
+		b.Skip(`benchmark meant to be run using normal Go compiler (needs os/exec)`)
+	}
+
+	build := func(name string, args ...string) string {
+		jsPath := filepath.Join(b.TempDir(), name+`.js`)
+		args = append([]string{`build`, `-o`, jsPath, filepath.Join(`testdata`, `lazytypes`, `main.go`)}, args...)
+		if out, err := exec.Command(`gopherjs`, args...).CombinedOutput(); err != nil {
+			b.Fatalf("failed to build the program: %v:\n%s", err, out)
+		}
+		return jsPath
+	}
+	run := func(jsPath string) time.Duration {
+		start := time.Now()
+		if out, err := exec.Command(`node`, jsPath).CombinedOutput(); err != nil {
+			b.Fatalf("failed to run the program: %v:\n%s", err, out)
+		}
+		return time.Since(start)
+	}
+	eagerPath := build(`eager`)
+	lazyPath := build(`lazy`, `--lazy_types`)
+
+	// The modes take turns, so that both are affected by the same load on the
+	// machine.
+	var eager, lazy time.Duration
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		eager += run(eagerPath)
+		lazy += run(lazyPath)
+	}
+	b.ReportMetric(float64(eager.Microseconds())/float64(b.N), `eager-us/op`)
+	b.ReportMetric(float64(lazy.Microseconds())/float64(b.N), `lazy-us/op`)
+	b.ReportMetric(100*(1-float64(lazy)/float64(eager)), `%faster`)
+}
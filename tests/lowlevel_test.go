@@ -1,12 +1,14 @@
 package tests_test
 
 import (
+	"context"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -49,3 +51,49 @@ func TestDeferBuiltin(t *testing.T) {
 		t.Fatalf("%v:\n%s", err, got)
 	}
 }
+
+func TestStackOverflow(t *testing.T) {
+	if runtime.GOOS == "js" {
+		t.Skip("test meant to be run using normal Go compiler (needs os/exec)")
+	}
+
+	gotb, err := exec.Command("gopherjs", "run", filepath.Join("testdata", "stack_overflow.go")).CombinedOutput()
+	got := string(gotb)
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 2 {
+		t.Fatalf("Got: %v. Want: the program to exit with status 2.\n%s", err, got)
+	}
+	for _, want := range []string{"runtime: goroutine stack exceeds limit\nfatal error: stack overflow\n\ngoroutine 1 [running]:\nmain.recurse(...)", " frames elided...\n", "main.main(...)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Got output without %q. Want the fatal error with a truncated traceback:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "recovered") {
+		t.Errorf("Got: the deferred call ran. Want: stack overflow to be unrecoverable:\n%s", got)
+	}
+}
+
+func TestPreempt(t *testing.T) {
+	if runtime.GOOS == "js" {
+		t.Skip("test meant to be run using normal Go compiler (needs os/exec)")
+	}
+
+	for _, mode := range [][]string{{"--preempt"}, {"--preempt", "--generators"}} {
+		t.Run(strings.Join(mode, " "), func(t *testing.T) {
+			// Without preemption, the program spins forever.
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			args := append([]string{"run"}, append(mode, filepath.Join("testdata", "preempt.go"))...)
+			cmd := exec.CommandContext(ctx, "gopherjs", args...)
+			// Node is a child process of gopherjs, which keeps the output pipe
+			// open after gopherjs is killed.
+			cmd.WaitDelay = time.Second
+			got, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("%v:\n%s", err, got)
+			}
+			if want := "timer fired\ngoroutine ran\n"; string(got) != want {
+				t.Errorf("Got output %q. Want: %q.", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+//go:build js && gopherjs
+
+package tests
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readProfile returns the uncompressed protobuf data of a profile.
+func readProfile(t *testing.T, profile *bytes.Buffer) string {
+	t.Helper()
+	r, err := gzip.NewReader(profile)
+	if err != nil {
+		t.Fatalf("Got: reading the profile returned error: %s. Want: a gzip-compressed profile.", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Got: reading the profile returned error: %s. Want: no error.", err)
+	}
+	return string(data)
+}
+
+var burnSink float64
+
+func burnCPU(d time.Duration) {
+	for start := time.Now(); time.Since(start) < d; {
+		for i := 0; i < 10000; i++ {
+			burnSink += float64(i) * 1.5
+		}
+	}
+}
+
+func TestCPUProfile(t *testing.T) {
+	profile := &bytes.Buffer{}
+	if err := pprof.StartCPUProfile(profile); err != nil {
+		t.Fatalf("Got: StartCPUProfile() returned error: %s. Want: no error.", err)
+	}
+	if err := pprof.StartCPUProfile(io.Discard); err == nil {
+		t.Errorf("Got: StartCPUProfile() succeeded while profiling. Want: an error.")
+	}
+	burnCPU(300 * time.Millisecond)
+	pprof.StopCPUProfile()
+
+	data := readProfile(t, profile)
+	for _, want := range []string{"cpu", "nanoseconds", "github.com/gopherjs/gopherjs/tests.burnCPU", "pprof_test.go"} {
+		if !strings.Contains(data, want) {
+			t.Errorf("Got a CPU profile without %q. Want the sampled Go functions and their files.", want)
+		}
+	}
+}
+
+type heapObject struct {
+	next  *heapObject
+	value string
+}
+
+var heapSink *heapObject
+
+func allocateHeap() {
+	for i := 0; i < 100000; i++ {
+		heapSink = &heapObject{next: heapSink, value: "object"}
+	}
+}
+
+func TestHeapProfile(t *testing.T) {
+	allocateHeap()
+	defer func() { heapSink = nil }()
+
+	if p := pprof.Lookup("heap"); p == nil || p.Name() != "heap" || p.Count() == 0 {
+		t.Errorf("Got: Lookup(\"heap\") = %v. Want: the heap profile with samples.", p)
+	}
+	profile := &bytes.Buffer{}
+	if err := pprof.WriteHeapProfile(profile); err != nil {
+		t.Fatalf("Got: WriteHeapProfile() returned error: %s. Want: no error.", err)
+	}
+	data := readProfile(t, profile)
+	for _, want := range []string{"inuse_space", "github.com/gopherjs/gopherjs/tests.allocateHeap"} {
+		if !strings.Contains(data, want) {
+			t.Errorf("Got a heap profile without %q. Want the sampled allocations of Go functions.", want)
+		}
+	}
+}
@@ -4,7 +4,10 @@ package tests
 
 import (
 	"fmt"
+	"os"
 	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
 	"strconv"
 	"strings"
 	"testing"
@@ -138,6 +141,16 @@ func Test_parseCallFrame(t *testing.T) {
 			input: "at r.github.com/gopherjs/gopherjs/tests.callStack.capture (runtime.go:42:3)",
 			want:  callFrame{FuncName: "github.com/gopherjs/gopherjs/tests.callStack.capture", File: "runtime.go", Line: 42, Col: 3},
 		},
+		{
+			name:  "Chrome with @ in file path",
+			input: "    at fmt.Sprintf (/go/pkg/mod/golang.org/toolchain@v0.0.1/src/fmt/print.go:240:2)",
+			want:  callFrame{FuncName: "fmt.Sprintf", File: "/go/pkg/mod/golang.org/toolchain@v0.0.1/src/fmt/print.go", Line: 240, Col: 2},
+		},
+		{
+			name:  "Chrome async caller",
+			input: "    at async main.main (/src/main.go:5:2)",
+			want:  callFrame{FuncName: "main.main", File: "/src/main.go", Line: 5, Col: 2},
+		},
 	}
 
 	for _, tt := range tests {
@@ -157,6 +170,143 @@ func Test_parseCallFrame(t *testing.T) {
 	}
 }
 
+func Test_resolveCallFrame(t *testing.T) {
+	// The script test.js consists of two lines. The first one starts with the
+	// function main.foo, declared on line 1 of foo.go, followed by code from line
+	// 2, and the second one has code from line 3.
+	sm := js.Global.Call("$decodeMappings", "AAAAA,IACA;AACA")
+	sm.Set("sources", []string{"/src/foo.go"})
+	sm.Set("names", []string{"main.foo"})
+	js.Global.Get("$sourceMaps").Call("set", "test.js", sm)
+	defer js.Global.Get("$sourceMaps").Call("delete", "test.js")
+
+	tests := []struct {
+		name  string
+		input string
+		want  callFrame
+	}{
+		{
+			name:  "Named segment",
+			input: "    at foo (test.js:1:2)",
+			want:  callFrame{FuncName: "main.foo", File: "/src/foo.go", Line: 1, Col: 1},
+		},
+		{
+			name:  "Enclosing function",
+			input: "    at foo (test.js:1:10)",
+			want:  callFrame{FuncName: "main.foo", File: "/src/foo.go", Line: 2, Col: 1},
+		},
+		{
+			name:  "Next line",
+			input: "foo@test.js:2:3",
+			want:  callFrame{FuncName: "main.foo", File: "/src/foo.go", Line: 3, Col: 1},
+		},
+		{
+			name:  "Beyond the source map",
+			input: "    at bar (test.js:5:1)",
+			want:  callFrame{FuncName: "bar", File: "test.js", Line: 5, Col: 1},
+		},
+		{
+			name:  "Resolved already",
+			input: "    at main.bar (/src/bar.go:7:3)",
+			want:  callFrame{FuncName: "main.bar", File: "/src/bar.go", Line: 7, Col: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame := js.Global.Call("$resolveCallFrame", tt.input)
+			got := callFrame{
+				FuncName: frame.Index(0).String(),
+				File:     frame.Index(1).String(),
+				Line:     frame.Index(2).Int(),
+				Col:      frame.Index(3).Int(),
+			}
+			if got != tt.want {
+				t.Errorf("Unexpected result:\n\tgot:  %+v\n\twant: %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Traceback", func(t *testing.T) {
+		stack := strings.Join([]string{
+			"Error: boom",
+			"    at $panic (goroutines.js:150:5)",
+			"    at foo (test.js:2:3)",
+			"    at runtime.throw (/src/runtime.go:10:3)",
+			"    at main.main (/src/main.go:5:2)",
+			"    at main.init (/src/main.go:4:6)", // The JavaScript code calling main.main.
+			"    at $goroutine (goroutines.js:210:13)",
+			"    at main.after (/src/main.go:9:2)",
+		}, "\n")
+		got := js.Global.Call("$goTraceback", stack).String()
+		want := "main.foo(...)\n\t/src/foo.go:3\nmain.main(...)\n\t/src/main.go:5\n"
+		if got != want {
+			t.Errorf("Got traceback:\n%s\nWant:\n%s", got, want)
+		}
+	})
+}
+
+func TestCallerPosition(t *testing.T) {
+	_, _, before, _ := runtime.Caller(0)
+	_, file, line, ok := runtime.Caller(0)
+	if !ok || !strings.HasSuffix(file, "runtime_test.go") || line != before+1 {
+		t.Errorf("Got runtime.Caller(0) = %q, %d, %v. Want runtime_test.go:%d.", file, line, ok, before+1)
+	}
+}
+
+func blockOnReceive(c chan int) { <-c }
+
+func TestStackAll(t *testing.T) {
+	debug.SetTraceback("all")
+	defer debug.SetTraceback("single")
+
+	c := make(chan int)
+	defer close(c)
+	go blockOnReceive(c)
+	runtime.Gosched()
+
+	buf := make([]byte, 1<<16)
+	own := string(buf[:runtime.Stack(buf, false)])
+	if !strings.HasPrefix(own, "goroutine ") || !strings.Contains(own, " [running]:\n") {
+		t.Errorf("Got runtime.Stack(buf, false):\n%s\nWant it to start with the header of the running goroutine.", own)
+	}
+	if !strings.Contains(own, ".TestStackAll(...)\n") || strings.Contains(own, "runtime.Stack") {
+		t.Errorf("Got runtime.Stack(buf, false):\n%s\nWant the caller of runtime.Stack on top.", own)
+	}
+
+	all := string(buf[:runtime.Stack(buf, true)])
+	if !strings.HasPrefix(all, own[:strings.Index(own, "\n")]) {
+		t.Errorf("Got runtime.Stack(buf, true):\n%s\nWant the current goroutine first.", all)
+	}
+	var blocked string
+	for _, trace := range strings.Split(all, "\n\n") {
+		if strings.Contains(trace, ".blockOnReceive(...)") {
+			blocked = trace
+		}
+	}
+	if !strings.Contains(blocked, " [chan receive]:\n") || !strings.Contains(blocked, "created by github.com/gopherjs/gopherjs/tests.TestStackAll in goroutine ") {
+		t.Errorf("Got runtime.Stack(buf, true):\n%s\nWant the goroutine blocked in blockOnReceive with its state and creator.", all)
+	}
+}
+
+func TestStackAllUnrecorded(t *testing.T) {
+	if os.Getenv("GOTRACEBACK") != "" {
+		t.Skip("GOTRACEBACK may make goroutines record their stacks")
+	}
+	// Recording the stacks of goroutines would make every blocking operation
+	// and go statement an order of magnitude slower.
+	c := make(chan int)
+	defer close(c)
+	go blockOnReceive(c)
+	runtime.Gosched()
+
+	buf := make([]byte, 1<<16)
+	all := string(buf[:runtime.Stack(buf, true)])
+	if strings.Contains(all, ".blockOnReceive(...)") || strings.Contains(all, "created by ") || !strings.Contains(all, " [chan receive]:\n\t(stack not recorded, set GOTRACEBACK=all to record it)\n") {
+		t.Errorf("Got runtime.Stack(buf, true):\n%s\nWant the goroutine blocked in blockOnReceive without its stack and creator.", all)
+	}
+}
+
 func TestBuildPlatform(t *testing.T) {
 	if runtime.GOOS != "js" {
 		t.Errorf("Got runtime.GOOS=%q. Want: %q.", runtime.GOOS, "js")
@@ -166,6 +316,50 @@ func TestBuildPlatform(t *testing.T) {
 	}
 }
 
+type memStatsPoint struct{ X, Y int }
+
+var memStatsSink any
+
+func TestReadMemStats(t *testing.T) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	memStatsSink = &memStatsPoint{1, 2}
+	memStatsSink = make([]int32, 100)
+	memStatsSink = map[string]int{}
+	runtime.ReadMemStats(&after)
+
+	if got := after.Mallocs - before.Mallocs; got != 3 {
+		t.Errorf("Got %d allocations counted by runtime.ReadMemStats. Want: 3.", got)
+	}
+	// 8 bytes for the struct, 400 bytes for the slice and 48 bytes for the map.
+	if got := after.TotalAlloc - before.TotalAlloc; got != 456 {
+		t.Errorf("Got %d allocated bytes counted by runtime.ReadMemStats. Want: 456.", got)
+	}
+	if after.HeapAlloc == 0 || after.HeapSys < after.HeapInuse || after.Sys < after.HeapSys {
+		t.Errorf("Got MemStats{HeapAlloc: %d, HeapInuse: %d, HeapSys: %d, Sys: %d}. Want heap sizes reported by Node.js.",
+			after.HeapAlloc, after.HeapInuse, after.HeapSys, after.Sys)
+	}
+}
+
+func TestReadMetrics(t *testing.T) {
+	samples := []metrics.Sample{
+		{Name: "/gc/heap/allocs:objects"},
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/sched/goroutines:goroutines"},
+		{Name: "/gc/pauses:seconds"},
+	}
+	metrics.Read(samples)
+
+	for _, s := range samples[:3] {
+		if s.Value.Kind() != metrics.KindUint64 || s.Value.Uint64() == 0 {
+			t.Errorf("Got metric %s of kind %v and value %v. Want a non-zero uint64.", s.Name, s.Value.Kind(), s.Value)
+		}
+	}
+	if got := samples[3].Value.Kind(); got != metrics.KindBad {
+		t.Errorf("Got unsupported metric %s of kind %v. Want: %v.", samples[3].Name, got, metrics.KindBad)
+	}
+}
+
 type funcName string
 
 func masked(_ funcName) funcName { return "<MASKED>" }
@@ -281,8 +475,10 @@ func TestCallers(t *testing.T) {
 			masked("Array.TestCallers·func5·func1"),
 			"github.com/gopherjs/gopherjs/tests.TestCallers.func5.func1.func1",
 			masked("Array.TestCallers·func5·func1"),
-			// Only 6 frames came back because we requested 8 and
-			// 2 were the hidden $callDefered frames.
+			"github.com/gopherjs/gopherjs/tests.TestCallers.func5.func1.func1",
+			masked("Array.TestCallers·func5·func1"),
+			// The hidden frames running the deferred calls don't count towards
+			// the 8 requested frames.
 		}
 		if diff := cmp.Diff(want, got, opts); diff != "" {
 			t.Errorf("runtime.Callers() returned a diff (-want,+got):\n%s", diff)
@@ -0,0 +1,95 @@
+//go:build js && gopherjs
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/gopherjs/gopherjs/scheduler"
+)
+
+type timeoutDriver struct {
+	requests int
+}
+
+func (d *timeoutDriver) Request(drain func()) {
+	d.requests++
+	js.Global.Call("setTimeout", func() { drain() })
+}
+
+func (d *timeoutDriver) Budget() time.Duration {
+	return time.Millisecond
+}
+
+func TestSchedulerDriver(t *testing.T) {
+	d := &timeoutDriver{}
+	scheduler.SetDriver(d)
+	defer scheduler.SetDriver(nil)
+
+	const workers = 4
+	done := make(chan int)
+	for i := 0; i < workers; i++ {
+		go func(i int) {
+			time.Sleep(time.Millisecond)
+			// Keep the goroutine runnable for longer than the budget.
+			for start := time.Now(); time.Since(start) < 5*time.Millisecond; {
+			}
+			done <- i
+		}(i)
+	}
+	sum := 0
+	for i := 0; i < workers; i++ {
+		sum += <-done
+	}
+	if want := 0 + 1 + 2 + 3; sum != want {
+		t.Errorf("Got: sum of goroutine results %d. Want: %d.", sum, want)
+	}
+	if d.requests == 0 {
+		t.Errorf("Got: no calls to Driver.Request. Want: the driver to run the goroutines.")
+	}
+}
+
+// stalledDriver never calls drain, e.g. like AnimationFrame while the page is
+// hidden.
+type stalledDriver struct {
+	requests int
+}
+
+func (d *stalledDriver) Request(drain func()) {
+	d.requests++
+}
+
+func (d *stalledDriver) Budget() time.Duration {
+	return time.Millisecond
+}
+
+func TestSchedulerDriverReplaced(t *testing.T) {
+	stalled := &stalledDriver{}
+	scheduler.SetDriver(stalled)
+	defer scheduler.SetDriver(nil)
+
+	// The timer calls its function outside of any goroutine, so the goroutine
+	// it starts makes the scheduler ask the stalled driver for a drain. The
+	// driver is replaced from JavaScript while that drain is pending.
+	d := &timeoutDriver{}
+	fired := make(chan bool)
+	time.AfterFunc(time.Millisecond, func() { fired <- true })
+	js.Global.Call("setTimeout", func() { scheduler.SetDriver(d) }, 20)
+	// If the goroutines never run, nothing is left in the event loop and the
+	// program would exit without reporting a failure, so a JavaScript timer
+	// reports it instead.
+	stall := js.Global.Call("setTimeout", func() {
+		panic("goroutines didn't run after the scheduler driver was replaced")
+	}, 2000)
+	<-fired
+	js.Global.Call("clearTimeout", stall)
+
+	if stalled.requests == 0 {
+		t.Errorf("Got: no calls to Driver.Request of the replaced driver. Want: a pending drain when it was replaced.")
+	}
+	if d.requests == 0 {
+		t.Errorf("Got: no calls to Driver.Request of the new driver. Want: the new driver to run the goroutines.")
+	}
+}
@@ -0,0 +1,49 @@
+//go:build js && gopherjs
+
+package tests
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+func TestSignal(t *testing.T) {
+	process := js.Global.Get("process")
+	if process == js.Undefined || process.Get("kill") == js.Undefined {
+		t.Skip("signals are only supported in Node.js")
+	}
+	raise := func() { process.Call("kill", process.Get("pid"), "SIGHUP") }
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	raise()
+	select {
+	case sig := <-c:
+		if sig != syscall.SIGHUP {
+			t.Errorf("Got: signal %v. Want: %v.", sig, syscall.SIGHUP)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Got: no signal. Want: %v to be delivered.", syscall.SIGHUP)
+	}
+	signal.Stop(c)
+
+	// The process must survive an ignored signal, whose default action in
+	// Node.js is to terminate it.
+	signal.Ignore(syscall.SIGHUP)
+	defer signal.Reset(syscall.SIGHUP)
+	if !signal.Ignored(syscall.SIGHUP) {
+		t.Errorf("Got: signal.Ignored(%v) = false. Want: true.", syscall.SIGHUP)
+	}
+	raise()
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case sig := <-c:
+		t.Errorf("Got: signal %v after Stop. Want: no signal.", sig)
+	default:
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+type shape interface {
+	Area() int
+	Name() string
+}
+
+type rect struct{ W, H int }
+
+func (r rect) Area() int    { return r.W * r.H }
+func (r rect) Name() string { return "rect" }
+func (r *rect) Scale(f int) { r.W, r.H = r.W*f, r.H*f }
+
+type square struct {
+	rect
+	Label string
+}
+
+func (s square) Name() string { return "square " + s.Label }
+
+type counter map[string]int
+
+func (c counter) Keys() []string {
+	keys := []string{}
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func main() {
+	// Anonymous types of various kinds.
+	pairs := []struct{ K, V string }{{"a", "1"}, {"b", "2"}}
+	byPoint := map[[2]int]bool{{1, 2}: true}
+	ch := make(chan []int, 1)
+	ch <- []int{1, 2, 3}
+	fn := func(xs ...float64) (sum float64) {
+		for _, x := range xs {
+			sum += x
+		}
+		return sum
+	}
+	fmt.Println(pairs, byPoint[[2]int{1, 2}], <-ch, fn(1.5, 2.5))
+
+	// Method lists of named types, including promoted methods.
+	shapes := []shape{rect{2, 3}, square{rect{2, 2}, "small"}}
+	for _, s := range shapes {
+		fmt.Println(s.Name(), s.Area())
+	}
+	sq := &square{rect{1, 1}, "unit"}
+	sq.Scale(3)
+	fmt.Println(sq.Area())
+
+	var v any = counter{"x": 1, "y": 2}
+	if k, ok := v.(interface{ Keys() []string }); ok {
+		fmt.Println(k.Keys())
+	}
+	_, ok := v.(shape)
+	fmt.Println(ok)
+
+	// Reflection on method sets and anonymous types.
+	for _, t := range []reflect.Type{reflect.TypeOf(rect{}), reflect.TypeOf(&rect{}), reflect.TypeOf(square{})} {
+		names := []string{}
+		for i := 0; i < t.NumMethod(); i++ {
+			names = append(names, t.Method(i).Name)
+		}
+		fmt.Println(t, names)
+	}
+	fmt.Println(reflect.TypeOf(pairs), reflect.TypeOf(byPoint), reflect.TypeOf(fn))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+type counter struct {
+	mu    int
+	buf   []string
+	Total int
+}
+
+func (c *counter) add(s string) {
+	c.mu++
+	c.buf = append(c.buf, s)
+	c.Total += len(s)
+}
+
+func (c counter) joined() string { return strings.Join(c.buf, ",") }
+
+type adder interface{ add(s string) }
+
+type wrapper struct {
+	*counter
+	name string
+}
+
+type jsObject struct {
+	*js.Object
+	value int `js:"value"`
+}
+
+type namer interface{ foo() string }
+
+type named struct{}
+
+func (named) foo() string { return "foo" }
+
+// embedsIface gets promoted methods from the embedded interface.
+type embedsIface struct{ namer }
+
+type keyed struct {
+	id   int
+	Name string `json:"name"`
+}
+
+func main() {
+	c := &counter{}
+	c.add("a")
+	c.add("bc")
+	fmt.Println(c.mu, c.joined(), c.Total)
+
+	// Unexported interface methods and promotion through embedding.
+	var a adder = &wrapper{counter: c, name: "w"}
+	a.add("def")
+	fmt.Println(c.joined(), c.Total)
+
+	// Method values and expressions.
+	f := c.add
+	f("g")
+	g := (*counter).add
+	g(c, "h")
+	h := adder.add
+	h(c, "i")
+	fmt.Println(c.joined())
+
+	// Reflection sees original names.
+	t := reflect.TypeOf(counter{})
+	for i := 0; i < t.NumField(); i++ {
+		fmt.Println(t.Field(i).Name, reflect.ValueOf(*c).Field(i))
+	}
+	fmt.Printf("%+v\n", keyed{id: 1, Name: "x"})
+
+	// Composite values are compared and copied field by field.
+	k1, k2 := keyed{1, "x"}, keyed{1, "x"}
+	k3 := k1
+	k3.id = 2
+	fmt.Println(k1 == k2, k1 == k3, k1.id)
+
+	m := map[keyed]bool{k1: true}
+	fmt.Println(m[k2], m[k3])
+
+	b, err := json.Marshal(keyed{id: 2, Name: "y"})
+	fmt.Println(string(b), err)
+
+	// Fields with js tags keep their names.
+	o := &jsObject{Object: js.Global.Get("Object").New()}
+	o.value = 42
+	fmt.Println(o.Get("value").Int())
+
+	// Methods promoted from embedded interfaces.
+	var n namer = embedsIface{named{}}
+	fmt.Println(n.foo())
+
+	// Calls of unexported methods on nil interfaces.
+	func() {
+		defer func() { fmt.Println(recover()) }()
+		var nilNamer namer
+		nilNamer.foo()
+	}()
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+var stopped, started bool
+
+func main() {
+	// A timer fires while the main goroutine spins in a loop without calls.
+	time.AfterFunc(time.Millisecond, func() { stopped = true })
+	for !stopped {
+	}
+	fmt.Println("timer fired")
+
+	// Another goroutine runs while the main goroutine spins.
+	go func() { started = true }()
+	for !started {
+	}
+	fmt.Println("goroutine ran")
+}
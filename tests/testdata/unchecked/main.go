@@ -0,0 +1,66 @@
+package main
+
+import "fmt"
+
+type point struct{ X, Y int }
+
+type grid [3]int
+
+func sum(s []int) int {
+	total := 0
+	for i := 0; i < len(s); i++ {
+		total += s[i]
+	}
+	return total
+}
+
+// divide64 returns the error x / y panics with. The division by zero check of
+// 64-bit integers is kept even if checks are omitted, since it's cheap compared
+// to the division itself, which the runtime does in software.
+func divide64(x, y int64) (err any) {
+	defer func() { err = recover() }()
+	_ = x / y
+	return nil
+}
+
+func main() {
+	// Slice and array indexing.
+	s := []int{1, 2, 3, 4, 5}
+	sub := s[1:4]
+	sub[2] = 40
+	a := [4]string{"a", "b", "c", "d"}
+	i := 2
+	a[i] = "C"
+	fmt.Println(sum(s), sub[0], s[3], a[i], a)
+
+	// Pointers to elements, fields and arrays.
+	e := &s[i]
+	*e = 30
+	pa := &a[i+1]
+	*pa = "D"
+	p := &point{X: 1, Y: 2}
+	py := &p.Y
+	*py = 20
+	g := &grid{7, 8, 9}
+	g[i] = 90
+	cp := *p
+	cp.X = 10
+	cg := *g
+	cg[0] = 70
+	fmt.Println(s, a, *p, cp, *g, cg)
+
+	// Integer division and remainder.
+	x, y := -7, 2
+	var u8, v8 uint8 = 250, 7
+	var i32, m32 int32 = -2147483648, -1
+	var u, v uint = 4000000000, 3
+	fmt.Println(x/y, x%y, -x/y, x%-y, u8/v8, u8%v8, i32/m32, i32%m32, u/v, u%v)
+
+	// 64-bit integer division is always checked, see divide64.
+	var x64, y64, zero64 int64 = 1 << 40, 3, 0
+	fmt.Println(x64/y64, x64%y64, divide64(x64, zero64))
+
+	// Float division is never checked.
+	f := 1.0
+	fmt.Println(f/0 > 1e308, -f/0 < -1e308)
+}
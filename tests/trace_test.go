@@ -0,0 +1,63 @@
+//go:build js && gopherjs
+
+package tests
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"runtime/trace"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func traceWorkers(ctx context.Context) {
+	var wg sync.WaitGroup
+	ch := make(chan int)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range ch {
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+	trace.WithRegion(ctx, "traceSend", func() {
+		for i := 0; i < 4; i++ {
+			ch <- i
+		}
+	})
+	close(ch)
+	wg.Wait()
+}
+
+func TestTrace(t *testing.T) {
+	if trace.IsEnabled() {
+		t.Skip("tracing is already enabled by the -trace flag")
+	}
+	buf := &bytes.Buffer{}
+	if err := trace.Start(buf); err != nil {
+		t.Fatalf("Got: trace.Start() returned error: %s. Want: no error.", err)
+	}
+	if err := trace.Start(io.Discard); err == nil {
+		t.Errorf("Got: trace.Start() succeeded while tracing. Want: an error.")
+	}
+	ctx, task := trace.NewTask(context.Background(), "traceTask")
+	traceWorkers(ctx)
+	trace.Log(ctx, "traceCategory", "traceMessage")
+	task.End()
+	trace.Stop()
+
+	data := buf.String()
+	if !strings.HasPrefix(data, "go 1.21 trace\x00\x00\x00") {
+		t.Fatalf("Got a trace starting with %q. Want: the header of the Go 1.21 trace format.", data[:min(len(data), 16)])
+	}
+	for _, want := range []string{"traceTask", "traceSend", "traceCategory", "traceMessage", "github.com/gopherjs/gopherjs/tests.traceWorkers", "trace_test.go"} {
+		if !strings.Contains(data, want) {
+			t.Errorf("Got a trace without %q. Want the user annotations and the stacks of blocked goroutines.", want)
+		}
+	}
+}
@@ -83,6 +83,17 @@ func main() {
 	compilerFlags.BoolVar(&options.MapToLocalDisk, "localmap", false, "use local paths for sourcemap")
 	compilerFlags.BoolVarP(&options.NoCache, "no_cache", "a", false, "rebuild all packages from scratch")
 	compilerFlags.BoolVarP(&options.CreateMapFile, "source_map", "s", true, "enable generation of source maps")
+	compilerFlags.BoolVar(&options.NoInline, "no_inline", false, "disable inlining of small functions")
+	compilerFlags.BoolVar(&options.SharedGenerics, "shared_generics", false, "compile generic functions once for instances that don't depend on type arguments")
+	compilerFlags.BoolVar(&options.Generators, "generators", false, "compile blocking functions into JavaScript generator functions, which work like async functions, instead of resumable state machines")
+	compilerFlags.BoolVar(&options.Preempt, "preempt", false, "let long-running loops outside of the standard library yield to other goroutines and the event loop")
+	compilerFlags.DurationVar(&options.PreemptBudget, "preempt_budget", 10*time.Millisecond, "time a goroutine may run before its preempted loops yield")
+	compilerFlags.BoolVar(&options.Unchecked, "unchecked", false, "omit index bounds, nil pointer and division by zero checks (unsafe)")
+	compilerFlags.BoolVar(&options.StripReflection, "strip_reflection", false, "emit minimal reflection metadata for types that are never reflected upon")
+	compilerFlags.BoolVar(&options.LazyTypes, "lazy_types", false, "construct anonymous types and method lists on first use instead of at startup")
+	compilerFlags.BoolVar(&options.MangleProps, "mangle_props", false, "rename unexported fields and methods to short names in packages not augmented by GopherJS")
+	compilerFlags.BoolVar(&options.Optimize, "optimize", false, "run the linked program through esbuild's minifier and report its size before and after")
+	compilerFlags.BoolVar(&options.StableNames, "stable_names", false, "derive minified package-level names from symbol names, so that they don't change between builds")
 
 	flagWatch := pflag.NewFlagSet("", 0)
 	flagWatch.BoolVarP(&options.Watch, "watch", "w", false, "watch for changes to the source files")
@@ -313,6 +324,7 @@ func main() {
 	}
 	bench := cmdTest.Flags().String("bench", "", "Run benchmarks matching the regular expression. By default, no benchmarks run. To run all benchmarks, use '--bench=.'.")
 	benchtime := cmdTest.Flags().String("benchtime", "", "Run enough iterations of each benchmark to take t, specified as a time.Duration (for example, -benchtime 1h30s). The default is 1 second (1s).")
+	benchmem := cmdTest.Flags().Bool("benchmem", false, "Print memory allocation statistics for benchmarks. Allocations of structs, slices and maps are counted by the GopherJS runtime.")
 	count := cmdTest.Flags().String("count", "", "Run each test and benchmark n times (default 1). Examples are always run once.")
 	run := cmdTest.Flags().String("run", "", "Run only those tests and examples matching the regular expression.")
 	short := cmdTest.Flags().Bool("short", false, "Tell long-running tests to shorten their run time.")
@@ -320,6 +332,10 @@ func main() {
 	compileOnly := cmdTest.Flags().BoolP("compileonly", "c", false, "Compile the test binary to pkg.test.js but do not run it (where pkg is the last element of the package's import path). The file name can be changed with the -o flag.")
 	outputFilename := cmdTest.Flags().StringP("output", "o", "", "Compile the test binary to the named file. The test still runs (unless -c is specified).")
 	parallelTests := cmdTest.Flags().IntP("parallel", "p", runtime.NumCPU(), "Allow running tests in parallel for up to -p packages. Tests within the same package are still executed sequentially.")
+	cpuProfileTest := cmdTest.Flags().String("cpuprofile", "", "Write a CPU profile of the tests to the specified file before exiting. Requires Node.js.")
+	memProfileTest := cmdTest.Flags().String("memprofile", "", "Write a heap profile of the objects in use to the specified file after all tests have passed. Requires Node.js.")
+	cmdTest.Flags().BoolVar(&options.DetectLeaks, "detect-leaks", false, "Fail tests that leave goroutines they created blocked after they return, and print where the goroutines were created.")
+	traceTest := cmdTest.Flags().String("trace", "", "Write an execution trace of the goroutine scheduler to the specified file before exiting. The trace can be viewed with 'go tool trace'.")
 	cmdTest.Flags().AddFlagSet(compilerFlags)
 	cmdTest.RunE = func(cmd *cobra.Command, args []string) error {
 		options.BuildTags = strings.Fields(tags)
@@ -337,6 +353,15 @@ func main() {
 		if *outputFilename != "" && len(matches) > 1 {
 			return errors.New("cannot use -o flag with multiple packages")
 		}
+		if *cpuProfileTest != "" && len(matches) > 1 {
+			return errors.New("cannot use --cpuprofile flag with multiple packages")
+		}
+		if *memProfileTest != "" && len(matches) > 1 {
+			return errors.New("cannot use --memprofile flag with multiple packages")
+		}
+		if *traceTest != "" && len(matches) > 1 {
+			return errors.New("cannot use --trace flag with multiple packages")
+		}
 		if *parallelTests < 1 {
 			return errors.New("--parallel cannot be less than 1")
 		}
@@ -420,6 +445,9 @@ func main() {
 			if *benchtime != "" {
 				args = append(args, "-test.benchtime", *benchtime)
 			}
+			if *benchmem {
+				args = append(args, "-test.benchmem")
+			}
 			if *count != "" {
 				args = append(args, "-test.count", *count)
 			}
@@ -432,6 +460,18 @@ func main() {
 			if *verbose {
 				args = append(args, "-test.v")
 			}
+			// Tests run in the package directory, so profile paths are made
+			// absolute to be relative to the current directory.
+			for _, profile := range []struct{ flag, path string }{{"-test.cpuprofile", *cpuProfileTest}, {"-test.memprofile", *memProfileTest}, {"-test.trace", *traceTest}} {
+				if profile.path == "" {
+					continue
+				}
+				path, err := filepath.Abs(profile.path)
+				if err != nil {
+					return err
+				}
+				args = append(args, profile.flag, path)
+			}
 			executions.Go(func() error {
 				parallelSlots <- true              // Acquire slot
 				defer func() { <-parallelSlots }() // Release slot
@@ -927,6 +967,9 @@ func runNode(script string, args []string, dir string, quiet bool, out io.Writer
 		allArgs = append(allArgs, fmt.Sprintf("--stack_size=%v", cur))
 	}
 
+	// Let runtime.GC() run a garbage collection, so that finalizers can run.
+	allArgs = append(allArgs, "--expose-gc")
+
 	allArgs = append(allArgs, script)
 	allArgs = append(allArgs, args...)
 